@@ -0,0 +1,28 @@
+package main
+
+import (
+	"log"
+
+	"github.com/z-riley/go-2048-battle/common/comms"
+	"github.com/z-riley/go-2048-battle/common/host"
+)
+
+// updateGame advances the match. The lobby opens again once there aren't enough
+// players left for another match.
+func (s *server) updateGame() {
+	s.game.Update()
+
+	standing := s.game.Standing()
+	if standing == 0 || (s.game.Match().Over() && standing < 2) {
+		s.endGame()
+	}
+}
+
+// endGame opens the lobby again after a match.
+func (s *server) endGame() {
+	log.Println("Match finished, opening the lobby")
+	s.game.Match().Close()
+	s.game = nil
+	s.lobby = host.NewLobby(s.net, s.serverPlayerData(), s.opts.rules)
+	s.freeSlots.Store(comms.MaxPlayers)
+}
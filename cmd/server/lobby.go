@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/z-riley/go-2048-battle/common/comms"
+	"github.com/z-riley/go-2048-battle/common/host"
+)
+
+// handlePlayerData handles incoming player data in the lobby. The match is
+// rescheduled whenever a player joins.
+func (s *server) handlePlayerData(conn int, data comms.PlayerData) error {
+	joined, err := s.lobby.Join(conn, data)
+	if errors.Is(err, comms.ErrIncompatible) || errors.Is(err, host.ErrFull) {
+		// The guest has been told why it can't join
+		log.Printf("Turned away %s: %v", data.Username, err)
+		return nil
+	}
+	if joined {
+		log.Println(data.Username, "joined the lobby")
+		s.freeSlots.Store(int32(s.lobby.FreeSlots()))
+		s.scheduleStart()
+	}
+	return err
+}
+
+// handleChatData relays a message from a client to everyone, with the name of the
+// player or spectator who wrote it.
+func (s *server) handleChatData(conn int, data comms.ChatData) error {
+	name, ok := s.lobby.Name(conn)
+	if !ok {
+		return fmt.Errorf("chat from unknown connection %d", conn)
+	}
+	_, err := s.lobby.SendChat(name, data.Text)
+	return err
+}
+
+// leaveLobby removes the player or spectator on a connection from the lobby.
+func (s *server) leaveLobby(conn int) {
+	left, ok, err := s.lobby.Leave(conn)
+	if err != nil {
+		log.Println("Failed to tell guests that a player left:", err)
+	}
+	switch {
+	case !ok:
+		return
+	case left.Spectator:
+		log.Println(left.Username, "stopped watching")
+		return
+	}
+
+	log.Println(left.Username, "left the lobby")
+	s.freeSlots.Store(int32(s.lobby.FreeSlots()))
+	s.scheduleStart()
+}
+
+// scheduleStart decides when the match starts. It starts straight away once the
+// lobby is full, or after the start delay once enough players have joined, to give
+// others time to join.
+func (s *server) scheduleStart() {
+	switch players := s.lobby.Guests(); {
+	case players < s.opts.minPlayers:
+		s.startAt = time.Time{}
+
+	case players == comms.MaxPlayers:
+		s.startAt = time.Now()
+
+	case s.startAt.IsZero():
+		s.startAt = time.Now().Add(s.opts.startDelay)
+		text := fmt.Sprintf("The match starts in %s", s.opts.startDelay)
+		if _, err := s.lobby.SendChat(s.opts.name, text); err != nil {
+			log.Println("Failed to announce the start of the match:", err)
+		}
+	}
+}
+
+// updateLobby starts the match once it's time.
+func (s *server) updateLobby() {
+	if s.startAt.IsZero() || time.Now().Before(s.startAt) {
+		return
+	}
+	if err := s.startGame(); err != nil {
+		log.Println("Failed to start match:", err)
+	}
+}
+
+// startGame starts the match between everybody in the lobby.
+func (s *server) startGame() error {
+	s.startAt = time.Time{}
+	players := s.lobby.Players()
+	s.game = host.NewMatch(s.newMatch(players), s.lobby).SetLogger(log.Printf)
+	s.freeSlots.Store(0)
+
+	names := make([]string, 0, len(players))
+	for _, p := range players {
+		names = append(names, p.Username)
+	}
+	log.Println("Starting match between", names)
+
+	return s.lobby.Start()
+}
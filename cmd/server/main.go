@@ -0,0 +1,109 @@
+// Command server hosts versus matches without a window, so every player joins as
+// a guest. It runs one lobby at a time: the match starts once enough players have
+// joined, and the lobby opens again once they have all left.
+//
+// Usage:
+//
+//	go run ./cmd/server -name "Match box" -mode attack -target 1024 -rounds 3
+//
+// The server announces itself on the LAN, so it is listed on the join screen like
+// any other host.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/z-riley/go-2048-battle/common/comms"
+	"github.com/z-riley/servesyouright"
+)
+
+// serverOpts contains the configuration of the server.
+type serverOpts struct {
+	name       string
+	port       uint16
+	rules      comms.RulesData
+	minPlayers int
+	startDelay time.Duration
+}
+
+func main() {
+	var opts serverOpts
+	flag.StringVar(&opts.name, "name", "go-2048-battle server", "name shown to players on the LAN")
+	port := flag.Uint("port", 8080, "port to listen on")
+	mode := flag.String("mode", string(comms.ModeRace), "match mode: race or attack")
+	flag.IntVar(&opts.rules.Target, "target", 2048, "tile which wins a round")
+	flag.DurationVar(&opts.rules.TimeLimit, "time-limit", 0, "length of each round, won by the highest score. Zero for no limit")
+	flag.IntVar(&opts.rules.Rounds, "rounds", 1, "number of rounds in a match")
+	flag.IntVar(&opts.minPlayers, "min-players", 2, "number of players needed to start a match")
+	flag.DurationVar(&opts.startDelay, "start-delay", 10*time.Second, "time to wait for more players once enough have joined")
+	flag.Parse()
+	opts.port = uint16(*port)
+	opts.rules.Mode = comms.Mode(*mode)
+
+	if err := validate(opts, *port); err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid options:", err)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(opts); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+// validate returns an error if the server can't run with the options.
+func validate(opts serverOpts, port uint) error {
+	switch {
+	case port == 0 || port > 65535:
+		return fmt.Errorf("invalid port %d", port)
+	case opts.minPlayers < 2 || opts.minPlayers > comms.MaxPlayers:
+		return fmt.Errorf("min-players must be between 2 and %d", comms.MaxPlayers)
+	case opts.startDelay < 0:
+		return fmt.Errorf("negative start delay")
+	}
+	return opts.rules.Validate()
+}
+
+// run hosts matches until the process is interrupted.
+func run(opts serverOpts) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	srv := servesyouright.NewServer(maxClients)
+	s := newServer(opts, srv)
+	srv.SetCallback(func(conn int, b []byte) {
+		s.events <- func() {
+			if err := s.handleData(conn, b); err != nil {
+				log.Println("Failed to handle data from client:", err)
+			}
+		}
+	}).SetDisconnectCallback(func(conn int) {
+		s.events <- func() { s.handleDisconnect(conn) }
+	})
+
+	errCh := make(chan error)
+	go func() {
+		for err := range errCh {
+			log.Println("Server error:", err)
+		}
+	}()
+	if err := srv.Start("0.0.0.0", opts.port, errCh); err != nil {
+		return fmt.Errorf("failed to start server: %w", err)
+	}
+	defer srv.Destroy()
+
+	if err := comms.Announce(ctx, s.announcement); err != nil {
+		log.Println("Failed to announce server on the LAN:", err)
+	}
+
+	log.Printf("Hosting \"%s\" on port %d", opts.name, opts.port)
+	s.run(ctx)
+	return nil
+}
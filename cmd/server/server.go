@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/z-riley/go-2048-battle/common/comms"
+	"github.com/z-riley/go-2048-battle/common/host"
+	"github.com/z-riley/go-2048-battle/common/match"
+	"github.com/z-riley/go-2048-battle/config"
+)
+
+const (
+	maxSpectators = 8
+	maxClients    = comms.MaxPlayers + maxSpectators
+
+	// tickInterval is how often the server checks the timers of the lobby and
+	// match.
+	tickInterval = 50 * time.Millisecond
+)
+
+// server hosts a lobby, then the match between the players in it. It takes the
+// place of the host's screens, without playing itself. Everything happens on the
+// goroutine which calls run, so the state needs no locks.
+type server struct {
+	opts   serverOpts
+	net    host.Transport
+	events chan func() // handled in order by run
+
+	// freeSlots is the number of players who can still join, for announcing on the
+	// LAN from another goroutine
+	freeSlots atomic.Int32
+
+	// lobby is everyone waiting for the next match, or who has joined the current one
+	lobby *host.Lobby
+	// startAt is when the lobby starts the match, or zero whilst there aren't
+	// enough players
+	startAt time.Time
+
+	// game is the match being played, or nil whilst in the lobby
+	game *host.Match
+}
+
+// newServer constructs a server with an empty lobby, which sends messages with net.
+func newServer(opts serverOpts, net host.Transport) *server {
+	s := &server{
+		opts:   opts,
+		net:    net,
+		events: make(chan func(), 64),
+	}
+	s.lobby = host.NewLobby(net, s.serverPlayerData(), opts.rules)
+	s.freeSlots.Store(comms.MaxPlayers)
+	return s
+}
+
+// run handles events and timers until the context is cancelled.
+func (s *server) run(ctx context.Context) {
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case f := <-s.events:
+			f()
+		case <-ticker.C:
+			s.update()
+		}
+	}
+}
+
+// update advances the lobby or match.
+func (s *server) update() {
+	if s.game != nil {
+		s.updateGame()
+	} else {
+		s.updateLobby()
+	}
+}
+
+// announcement returns the current details of the server, for guests on the LAN.
+func (s *server) announcement() comms.Announcement {
+	return comms.Announcement{
+		Name:        s.opts.name,
+		Version:     config.Version,
+		MinProtocol: comms.MinProtocol,
+		MaxProtocol: comms.MaxProtocol,
+		Port:        s.opts.port,
+		FreeSlots:   int(s.freeSlots.Load()),
+	}
+}
+
+// serverPlayerData returns the player data of the server. It is sent as the host,
+// but isn't a player.
+func (s *server) serverPlayerData() comms.PlayerData {
+	return comms.PlayerData{
+		Version:   config.Version,
+		Handshake: comms.LocalHandshake(),
+		Username:  s.opts.name,
+		ID:        comms.HostID,
+		Dedicated: true,
+	}
+}
+
+// handleData handles all data received from a client.
+func (s *server) handleData(conn int, data []byte) error {
+	msg, err := comms.ParseMessage(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse message: %w", err)
+	}
+	if s.game != nil {
+		if handled, err := s.game.HandleHeartbeat(conn, msg); handled {
+			return err
+		}
+		return s.game.HandleMessage(conn, msg)
+	}
+
+	switch msg.Type {
+	case comms.TypePlayerData:
+		playerData, err := comms.ParsePlayerData(msg.Content)
+		if err != nil {
+			return fmt.Errorf("failed to parse player data: %w", err)
+		}
+		return s.handlePlayerData(conn, playerData)
+
+	case comms.TypeChatData:
+		chatData, err := comms.ParseChatData(msg.Content)
+		if err != nil {
+			return fmt.Errorf("failed to parse chat data: %w", err)
+		}
+		return s.handleChatData(conn, chatData)
+
+	default:
+		// Everything else is about a match, so it's ignored in the lobby
+		return nil
+	}
+}
+
+// handleDisconnect handles a client disconnecting from the server. Players in a
+// match keep their place until the grace period for reconnecting has passed.
+func (s *server) handleDisconnect(conn int) {
+	if s.game != nil {
+		s.game.Disconnect(conn)
+		return
+	}
+	s.leaveLobby(conn)
+}
+
+// newMatch constructs the match between the players in the lobby.
+func (s *server) newMatch(players []comms.PlayerData) *match.Match {
+	ids := make([]comms.PlayerID, 0, len(players))
+	for _, p := range players {
+		ids = append(ids, p.ID)
+	}
+	m := match.New(ids, 0)
+	m.SetRules(s.opts.rules)
+	return m
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+	"github.com/z-riley/go-2048-battle/common/comms"
+	"github.com/z-riley/go-2048-battle/common/host/hosttest"
+)
+
+func testOpts() serverOpts {
+	return serverOpts{
+		name:       "test server",
+		rules:      comms.DefaultRules(),
+		minPlayers: 2,
+		startDelay: time.Minute,
+	}
+}
+
+// join sends the player data of a guest from a connection.
+func join(t *testing.T, s *server, conn int, data comms.PlayerData) {
+	t.Helper()
+	b, err := data.Serialise()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.handleData(conn, b); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLobby(t *testing.T) {
+	net := hosttest.NewTransport(0, 1, 2, 3, 4, 5)
+	s := newServer(testOpts(), net)
+
+	// Players are numbered after the server, which doesn't play
+	for conn := range 2 {
+		join(t, s, conn, comms.PlayerData{Username: "guest", Handshake: comms.LocalHandshake()})
+	}
+	msgs := net.Received(1, comms.TypeWelcomeData)
+	if len(msgs) != 1 {
+		t.Fatalf("Expected:\n<%v>\nGot:\n<%v>", 1, len(msgs))
+	}
+	welcome, err := comms.ParseWelcomeData(msgs[0].Content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := comms.HostID + 2; welcome.ID != want {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", want, welcome.ID)
+	}
+	if s.startAt.IsZero() {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", "match to be scheduled", s.startAt)
+	}
+
+	// The server is sent as the host
+	msgs = net.Received(0, comms.TypePlayerData)
+	host, err := comms.ParsePlayerData(msgs[0].Content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host.ID != comms.HostID || !host.Dedicated {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", "dedicated host", host)
+	}
+
+	// Games which can't play together are turned away with a reason
+	join(t, s, 2, comms.PlayerData{Username: "old guest"})
+	msgs = net.Received(2, comms.TypeEventData)
+	if len(msgs) != 1 {
+		t.Fatalf("Expected:\n<%v>\nGot:\n<%v>", 1, len(msgs))
+	}
+	event, err := comms.ParseEventData(msgs[0].Content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Event != comms.EventIncompatible || event.Reason == "" {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", comms.EventIncompatible, event)
+	}
+
+	// The match starts as soon as the lobby is full
+	for conn := 2; conn < 4; conn++ {
+		join(t, s, conn, comms.PlayerData{Username: "guest", Handshake: comms.LocalHandshake()})
+	}
+	if s.startAt.After(time.Now()) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", "match to start now", s.startAt)
+	}
+	join(t, s, 4, comms.PlayerData{Username: "late guest", Handshake: comms.LocalHandshake()})
+	msgs = net.Received(4, comms.TypeEventData)
+	if len(msgs) != 1 {
+		t.Fatalf("Expected:\n<%v>\nGot:\n<%v>", 1, len(msgs))
+	}
+	if event, _ := comms.ParseEventData(msgs[0].Content); event.Event != comms.EventLobbyFull {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", comms.EventLobbyFull, event.Event)
+	}
+
+	// Spectators can still watch
+	join(t, s, 5, comms.PlayerData{Username: "fan", Handshake: comms.LocalHandshake(), Spectator: true})
+	if got := s.lobby.Spectators(); got != 1 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 1, got)
+	}
+
+	// Somebody leaving frees up their place
+	s.handleDisconnect(0)
+	if got := s.lobby.Guests(); got != comms.MaxPlayers-1 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", comms.MaxPlayers-1, got)
+	}
+	if got := s.announcement().FreeSlots; got != 1 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 1, got)
+	}
+}
+
+func TestGame(t *testing.T) {
+	net := hosttest.NewTransport(0, 1)
+	s := newServer(testOpts(), net)
+	for conn := range 2 {
+		join(t, s, conn, comms.PlayerData{Username: "guest", Handshake: comms.LocalHandshake()})
+	}
+	if err := s.startGame(); err != nil {
+		t.Fatal(err)
+	}
+	if len(net.Received(0, comms.TypeEventData)) == 0 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", "start event", "nothing")
+	}
+
+	send := func(conn int, msg interface{ Serialise() ([]byte, error) }) {
+		t.Helper()
+		b, err := msg.Serialise()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.handleData(conn, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	move := func(conn, seq int) {
+		t.Helper()
+		send(conn, comms.InputData{Seq: seq, Action: comms.ActionMove, Dir: grid.DirLeft})
+	}
+
+	// Nobody can play before the countdown
+	move(0, 1)
+	if msgs := net.Received(1, comms.TypeDeltaData); len(msgs) != 0 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 0, len(msgs))
+	}
+
+	// The countdown starts once everybody is ready
+	for conn := range 2 {
+		send(conn, comms.EventData{Event: comms.EventReady})
+	}
+	s.update()
+	if msgs := net.Received(1, comms.TypeStartData); len(msgs) != 1 {
+		t.Fatalf("Expected:\n<%v>\nGot:\n<%v>", 1, len(msgs))
+	}
+
+	// The lobby opens again once everybody has left
+	for _, id := range s.game.Match().Players() {
+		if _, err := s.game.Match().Leave(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.update()
+	if s.game != nil || s.lobby.Guests() != 0 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", "empty lobby", s.lobby.Players())
+	}
+}
@@ -0,0 +1,185 @@
+// Command sim plays batches of games without a window, so that changes to the
+// rules can be evaluated before they ship.
+//
+// Usage:
+//
+//	go run ./cmd/sim -games 1000 -strategy corner -format json
+//
+// JSON output summarises the whole batch, whereas CSV output lists every game.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/z-riley/go-2048-battle/common/backend"
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+)
+
+// simOpts contains the configuration for a batch of games.
+type simOpts struct {
+	games    int
+	strategy string
+	depth    int
+	width    int
+	height   int
+	seed     uint64
+	target   int
+	maxMoves int
+	workers  int
+}
+
+func main() {
+	var opts simOpts
+	flag.IntVar(&opts.games, "games", 1000, "number of games to play")
+	flag.StringVar(&opts.strategy, "strategy", "corner", "strategy for choosing moves: "+strings.Join(strategies, ", "))
+	flag.IntVar(&opts.depth, "depth", 2, "number of moves searched ahead by the ai strategy")
+	flag.IntVar(&opts.width, "width", grid.DefaultSize, "number of grid columns")
+	flag.IntVar(&opts.height, "height", grid.DefaultSize, "number of grid rows")
+	flag.Uint64Var(&opts.seed, "seed", 0, "seed of the first game, incremented for each game. Zero uses a random seed")
+	flag.IntVar(&opts.target, "target", 2048, "tile which counts as a win")
+	flag.IntVar(&opts.maxMoves, "max-moves", 0, "maximum number of moves per game. Zero for unlimited")
+	flag.IntVar(&opts.workers, "workers", runtime.NumCPU(), "number of games played at once")
+	format := flag.String("format", "json", "output format: json (summary) or csv (every game)")
+	out := flag.String("out", "", "file to write to. Empty writes to stdout")
+	flag.Parse()
+
+	if err := validate(opts, *format); err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid options:", err)
+		flag.Usage()
+		os.Exit(2)
+	}
+	if opts.seed == 0 {
+		opts.seed = grid.NewSeed()
+	}
+
+	results, err := simulate(opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to simulate games:", err)
+		os.Exit(1)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to create output file:", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	switch *format {
+	case "json":
+		err = writeJSON(w, summarise(results, opts))
+	case "csv":
+		err = writeCSV(w, results)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to write results:", err)
+		os.Exit(1)
+	}
+}
+
+// validate returns an error if the options can't be simulated.
+func validate(opts simOpts, format string) error {
+	switch {
+	case opts.games < 1:
+		return fmt.Errorf("games must be positive, got %d", opts.games)
+	case !slices.Contains(strategies, opts.strategy):
+		return fmt.Errorf("unknown strategy \"%s\"", opts.strategy)
+	case !grid.ValidSize(opts.width, opts.height):
+		return fmt.Errorf("grid size %dx%d is outside of %d and %d", opts.width, opts.height, grid.MinSize, grid.MaxSize)
+	case opts.workers < 1:
+		return fmt.Errorf("workers must be positive, got %d", opts.workers)
+	case format != "json" && format != "csv":
+		return fmt.Errorf("unknown format \"%s\"", format)
+	default:
+		return nil
+	}
+}
+
+// simulate plays a batch of games across several workers. Game i spawns tiles from
+// opts.seed+i, so a batch with the same options always has the same results.
+func simulate(opts simOpts) ([]Result, error) {
+	results := make([]Result, opts.games)
+	jobs := make(chan int)
+	errCh := make(chan error, opts.workers)
+
+	var wg sync.WaitGroup
+	for range opts.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				r, err := play(i, opts.seed+uint64(i), opts)
+				if err != nil {
+					errCh <- err
+					return
+				}
+				results[i] = r
+			}
+		}()
+	}
+
+	for i := range opts.games {
+		select {
+		case jobs <- i:
+		case err := <-errCh:
+			close(jobs)
+			wg.Wait()
+			return nil, err
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	select {
+	case err := <-errCh:
+		return nil, err
+	default:
+		return results, nil
+	}
+}
+
+// play plays a single game until no move is possible, or the move limit is reached.
+func play(game int, seed uint64, opts simOpts) (Result, error) {
+	strategy, err := newStrategy(opts.strategy, seed, opts.depth)
+	if err != nil {
+		return Result{}, err
+	}
+
+	g := backend.NewGame(&backend.Opts{
+		SaveToDisk: false,
+		Width:      opts.width,
+		Height:     opts.height,
+		Seed:       seed,
+	})
+	defer g.Timer.Stop()
+
+	moves := 0
+	for opts.maxMoves == 0 || moves < opts.maxMoves {
+		dir, ok := strategy.Move(g.Grid)
+		if !ok {
+			break
+		}
+		g.ExecuteMove(dir)
+		moves++
+	}
+
+	return Result{
+		Game:        game,
+		Seed:        seed,
+		Score:       g.Score,
+		HighestTile: g.Grid.HighestTile(),
+		Moves:       moves,
+		Won:         g.Grid.HighestTile() >= opts.target,
+	}, nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"slices"
+	"strconv"
+)
+
+// Result contains the outcome of a single simulated game.
+type Result struct {
+	Game        int    `json:"game"`        // index of the game in the batch
+	Seed        uint64 `json:"seed"`        // seed that the game's tiles spawned from
+	Score       int    `json:"score"`       // final score
+	HighestTile int    `json:"highestTile"` // value of the highest tile reached
+	Moves       int    `json:"moves"`       // number of moves made
+	Won         bool   `json:"won"`         // whether the target tile was reached
+}
+
+// Summary contains the combined results of a batch of games.
+type Summary struct {
+	Strategy     string      `json:"strategy"`
+	Games        int         `json:"games"`
+	Width        int         `json:"width"`
+	Height       int         `json:"height"`
+	Seed         uint64      `json:"seed"`         // seed of the first game
+	Target       int         `json:"target"`       // tile which counts as a win
+	WinRate      float64     `json:"winRate"`      // proportion of games which reached the target
+	Scores       Stats       `json:"scores"`       // distribution of final scores
+	Moves        Stats       `json:"moves"`        // distribution of the number of moves made
+	HighestTiles map[int]int `json:"highestTiles"` // number of games which finished on each highest tile
+}
+
+// Stats describes the distribution of a set of values.
+type Stats struct {
+	Min    int     `json:"min"`
+	P10    int     `json:"p10"`
+	P25    int     `json:"p25"`
+	Median int     `json:"median"`
+	P75    int     `json:"p75"`
+	P90    int     `json:"p90"`
+	Max    int     `json:"max"`
+	Mean   float64 `json:"mean"`
+}
+
+// summarise combines the results of a batch of games.
+func summarise(results []Result, opts simOpts) Summary {
+	s := Summary{
+		Strategy:     opts.strategy,
+		Games:        len(results),
+		Width:        opts.width,
+		Height:       opts.height,
+		Seed:         opts.seed,
+		Target:       opts.target,
+		HighestTiles: map[int]int{},
+	}
+	if len(results) == 0 {
+		return s
+	}
+
+	var scores, moves []int
+	wins := 0
+	for _, r := range results {
+		scores = append(scores, r.Score)
+		moves = append(moves, r.Moves)
+		s.HighestTiles[r.HighestTile]++
+		if r.Won {
+			wins++
+		}
+	}
+	s.WinRate = float64(wins) / float64(len(results))
+	s.Scores = newStats(scores)
+	s.Moves = newStats(moves)
+
+	return s
+}
+
+// newStats calculates the distribution of a non-empty set of values.
+func newStats(values []int) Stats {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+
+	// percentile returns the value below which the given percentage of values fall
+	percentile := func(p int) int {
+		return sorted[(len(sorted)-1)*p/100]
+	}
+
+	total := 0
+	for _, v := range sorted {
+		total += v
+	}
+
+	return Stats{
+		Min:    sorted[0],
+		P10:    percentile(10),
+		P25:    percentile(25),
+		Median: percentile(50),
+		P75:    percentile(75),
+		P90:    percentile(90),
+		Max:    sorted[len(sorted)-1],
+		Mean:   float64(total) / float64(len(sorted)),
+	}
+}
+
+// writeJSON writes the summary of a batch of games as JSON.
+func writeJSON(w io.Writer, s Summary) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// writeCSV writes the result of every game in a batch as CSV, one game per row.
+func writeCSV(w io.Writer, results []Result) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"game", "seed", "score", "highestTile", "moves", "won"}); err != nil {
+		return err
+	}
+	for _, r := range results {
+		if err := cw.Write([]string{
+			strconv.Itoa(r.Game),
+			strconv.FormatUint(r.Seed, 10),
+			strconv.Itoa(r.Score),
+			strconv.Itoa(r.HighestTile),
+			strconv.Itoa(r.Moves),
+			strconv.FormatBool(r.Won),
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
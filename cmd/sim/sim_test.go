@@ -0,0 +1,61 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSimulate(t *testing.T) {
+	for _, strategy := range strategies {
+		opts := simOpts{
+			games:    6,
+			strategy: strategy,
+			depth:    1,
+			width:    4,
+			height:   4,
+			seed:     99,
+			target:   2048,
+			workers:  3,
+		}
+		first, err := simulate(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The same options should always give the same results
+		second, err := simulate(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(first, second) {
+			t.Errorf("Expected:\n<%v>\nGot:\n<%v>", first, second)
+		}
+
+		for i, r := range first {
+			if r.Game != i || r.Seed != opts.seed+uint64(i) || r.Moves == 0 {
+				t.Errorf("Unexpected result for %s game %d: %+v", strategy, i, r)
+			}
+		}
+	}
+}
+
+func TestSummarise(t *testing.T) {
+	results := []Result{
+		{Score: 100, HighestTile: 128, Moves: 10},
+		{Score: 300, HighestTile: 2048, Moves: 30, Won: true},
+		{Score: 200, HighestTile: 256, Moves: 20},
+		{Score: 400, HighestTile: 2048, Moves: 40, Won: true},
+	}
+	got := summarise(results, simOpts{strategy: "corner", width: 4, height: 4, target: 2048})
+
+	if got.WinRate != 0.5 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 0.5, got.WinRate)
+	}
+	want := Stats{Min: 100, P10: 100, P25: 100, Median: 200, P75: 300, P90: 300, Max: 400, Mean: 250}
+	if got.Scores != want {
+		t.Errorf("Expected:\n<%+v>\nGot:\n<%+v>", want, got.Scores)
+	}
+	if !reflect.DeepEqual(got.HighestTiles, map[int]int{128: 1, 256: 1, 2048: 2}) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", map[int]int{128: 1, 256: 1, 2048: 2}, got.HighestTiles)
+	}
+}
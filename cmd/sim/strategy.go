@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+	"github.com/z-riley/go-2048-battle/common/backend/grid/ai"
+)
+
+// Strategy chooses moves for a game.
+type Strategy interface {
+	// Move returns the direction to move the grid in, or false if no move is possible.
+	Move(g *grid.Grid) (grid.Direction, bool)
+}
+
+// strategies are the names of every strategy.
+var strategies = []string{"random", "greedy", "corner", "ai"}
+
+// newStrategy constructs the strategy with the given name. Strategies made from the
+// same seed choose the same moves for the same grids. depth is only used by the ai
+// strategy.
+func newStrategy(name string, seed uint64, depth int) (Strategy, error) {
+	switch name {
+	case "random":
+		return &randomStrategy{rng: grid.NewRNG(seed)}, nil
+	case "greedy":
+		return greedyStrategy{}, nil
+	case "corner":
+		return cornerStrategy{}, nil
+	case "ai":
+		return aiStrategy{solver: ai.NewSolver(depth, seed)}, nil
+	default:
+		return nil, fmt.Errorf("unknown strategy \"%s\"", name)
+	}
+}
+
+// cornerOrder is the order of preference for moves which keeps the highest tiles in
+// the bottom left corner.
+var cornerOrder = []grid.Direction{grid.DirDown, grid.DirLeft, grid.DirRight, grid.DirUp}
+
+// move is a possible move and the points it would gain.
+type move struct {
+	dir    grid.Direction
+	points int
+}
+
+// possibleMoves returns every move which changes the grid, in the order of cornerOrder.
+func possibleMoves(g *grid.Grid) []move {
+	var moves []move
+	for _, dir := range cornerOrder {
+		// Moving a clone leaves the grid's random number generator untouched
+		if points, moved := g.Clone().Move(dir); moved {
+			moves = append(moves, move{dir: dir, points: points})
+		}
+	}
+	return moves
+}
+
+// randomStrategy makes a random possible move.
+type randomStrategy struct {
+	rng *grid.RNG
+}
+
+// Move satisfies the Strategy interface.
+func (s *randomStrategy) Move(g *grid.Grid) (grid.Direction, bool) {
+	moves := possibleMoves(g)
+	if len(moves) == 0 {
+		return "", false
+	}
+	return moves[s.rng.IntN(len(moves))].dir, true
+}
+
+// greedyStrategy makes the move which gains the most points straight away.
+type greedyStrategy struct{}
+
+// Move satisfies the Strategy interface.
+func (greedyStrategy) Move(g *grid.Grid) (grid.Direction, bool) {
+	moves := possibleMoves(g)
+	if len(moves) == 0 {
+		return "", false
+	}
+	best := moves[0]
+	for _, m := range moves[1:] {
+		if m.points > best.points {
+			best = m
+		}
+	}
+	return best.dir, true
+}
+
+// cornerStrategy makes the first possible move in the order of cornerOrder.
+type cornerStrategy struct{}
+
+// Move satisfies the Strategy interface.
+func (cornerStrategy) Move(g *grid.Grid) (grid.Direction, bool) {
+	moves := possibleMoves(g)
+	if len(moves) == 0 {
+		return "", false
+	}
+	return moves[0].dir, true
+}
+
+// aiStrategy makes the move chosen by the expectimax solver.
+type aiStrategy struct {
+	solver *ai.Solver
+}
+
+// Move satisfies the Strategy interface.
+func (s aiStrategy) Move(g *grid.Grid) (grid.Direction, bool) {
+	dir, _, err := s.solver.Best(g)
+	if err != nil {
+		return "", false
+	}
+	return dir, true
+}
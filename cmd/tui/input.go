@@ -0,0 +1,82 @@
+package main
+
+import (
+	"io"
+)
+
+// action is something that the player can do by pressing a key.
+type action int
+
+const (
+	actionUp action = iota
+	actionDown
+	actionLeft
+	actionRight
+	actionUndo
+	actionRedo
+	actionNewGame
+	actionQuit
+)
+
+// keyActions maps single character keys to their actions.
+var keyActions = map[byte]action{
+	'w': actionUp, 'W': actionUp,
+	's': actionDown, 'S': actionDown,
+	'a': actionLeft, 'A': actionLeft,
+	'd': actionRight, 'D': actionRight,
+	'z': actionUndo, 'Z': actionUndo,
+	'y': actionRedo, 'Y': actionRedo,
+	'r': actionNewGame, 'R': actionNewGame,
+	'q': actionQuit, 'Q': actionQuit,
+	0x03: actionQuit, // Ctrl+C, which doesn't send a signal in raw mode
+}
+
+// arrowActions maps the final byte of an arrow key's escape sequence to its action.
+var arrowActions = map[byte]action{
+	'A': actionUp,
+	'B': actionDown,
+	'C': actionRight,
+	'D': actionLeft,
+}
+
+// parseKeys converts raw terminal input into actions. Unrecognised keys are ignored.
+func parseKeys(b []byte) []action {
+	var actions []action
+	for i := 0; i < len(b); i++ {
+		if b[i] != 0x1b {
+			if a, ok := keyActions[b[i]]; ok {
+				actions = append(actions, a)
+			}
+			continue
+		}
+
+		// Arrow keys are sent as "ESC [ A" or "ESC O A"
+		if i+2 < len(b) && (b[i+1] == '[' || b[i+1] == 'O') {
+			if a, ok := arrowActions[b[i+2]]; ok {
+				actions = append(actions, a)
+			}
+			i += 2
+			continue
+		}
+
+		// A lone escape key
+		actions = append(actions, actionQuit)
+	}
+	return actions
+}
+
+// readKeys sends everything read from r down a channel until reading fails, then
+// closes the channel.
+func readKeys(r io.Reader, ch chan<- []byte) {
+	defer close(ch)
+	buf := make([]byte, 64)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			ch <- append([]byte{}, buf[:n]...)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
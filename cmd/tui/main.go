@@ -0,0 +1,110 @@
+// Command tui is a terminal frontend for singleplayer games, for machines without
+// SDL2. It shares its save file and high score with the SDL client when run from
+// the same directory.
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/z-riley/go-2048-battle/common/backend"
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+	"github.com/z-riley/go-2048-battle/common/profile"
+	"github.com/z-riley/go-2048-battle/config"
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+// run plays a game until the player quits.
+func run() error {
+	game := backend.NewGame(&backend.Opts{
+		SaveToDisk:   true,
+		UndoHistory:  config.UndoHistory,
+		UndoLimit:    config.UndoLimit,
+		RecordReplay: true,
+		PlayerName:   playerName(),
+	})
+
+	restore, err := makeRaw()
+	if err != nil {
+		return fmt.Errorf("failed to put terminal into raw mode: %w", err)
+	}
+	fmt.Print(enterAltScreen)
+	defer func() {
+		fmt.Print(exitAltScreen)
+		if err := restore(); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to restore terminal:", err)
+		}
+	}()
+
+	keys := make(chan []byte)
+	go readKeys(os.Stdin, keys)
+
+	// Redraw every second so the timer keeps counting
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	draw := func() { fmt.Print(clearScreen + render(game)) }
+	draw()
+	for {
+		select {
+		case b, ok := <-keys:
+			if !ok {
+				return quit(game)
+			}
+			for _, a := range parseKeys(b) {
+				if a == actionQuit {
+					return quit(game)
+				}
+				handleAction(game, a)
+			}
+		case <-ticker.C:
+		}
+		draw()
+	}
+}
+
+// playerName returns the name in the player's profile, which is shared with the
+// SDL client, or nothing if they haven't got one yet.
+func playerName() string {
+	p, err := profile.Load(profile.NewStore())
+	if err != nil {
+		return ""
+	}
+	return p.Name
+}
+
+// handleAction carries out an action on the game.
+func handleAction(game *backend.Game, a action) {
+	switch a {
+	case actionUp:
+		game.ExecuteMove(grid.DirUp)
+	case actionDown:
+		game.ExecuteMove(grid.DirDown)
+	case actionLeft:
+		game.ExecuteMove(grid.DirLeft)
+	case actionRight:
+		game.ExecuteMove(grid.DirRight)
+	case actionUndo:
+		game.Undo()
+	case actionRedo:
+		game.Redo()
+	case actionNewGame:
+		game.Reset()
+	}
+}
+
+// quit saves the game so it can be continued by either client.
+func quit(game *backend.Game) error {
+	game.Timer.Pause()
+	if err := game.Save(); err != nil {
+		return fmt.Errorf("failed to save game: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+
+	"github.com/z-riley/go-2048-battle/common/backend"
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+	"github.com/z-riley/go-2048-battle/common/palette"
+)
+
+// Tile dimensions, in characters.
+const (
+	tileWidth  = 8
+	tileHeight = 3
+)
+
+// render draws the whole game as a string of text and ANSI escape codes. Lines
+// end with "\r\n" because the terminal is in raw mode.
+func render(g *backend.Game) string {
+	var sb strings.Builder
+	line := func(format string, a ...any) {
+		sb.WriteString(fmt.Sprintf(format, a...) + resetStyle + "\r\n")
+	}
+
+	line("%s2048 Battle", bold)
+	line("Score: %-8d Best: %-8d Time: %v", g.Score, g.HighScore, g.Timer.Duration())
+	line("")
+	sb.WriteString(renderGrid(g.Grid))
+	line("")
+
+	switch g.Grid.Outcome() {
+	case grid.Lose:
+		line("%sGame over!%s You earned %d points. Press R to play again.", bold, resetStyle, g.Score)
+	case grid.Win:
+		line("Your next goal is to get to the %d tile!", g.Grid.HighestTile()*2)
+	default:
+		line("Join the numbers and get to the 2048 tile!")
+	}
+	if left := g.UndosLeft(); left >= 0 {
+		line("Undos left: %d", left)
+	}
+	line("Arrows/WASD: move   Z: undo   Y: redo   R: new game   Q: quit")
+
+	return sb.String()
+}
+
+// renderGrid draws the tiles of a grid in the colours of the SDL client.
+func renderGrid(g *grid.Grid) string {
+	var sb strings.Builder
+	gap := background(palette.ArenaBackground) + " "
+	edge := background(palette.ArenaBackground) +
+		strings.Repeat(" ", g.Width()*(tileWidth+1)+1) + resetStyle + "\r\n"
+
+	sb.WriteString(edge)
+	for _, row := range g.Tiles {
+		for l := range tileHeight {
+			for _, t := range row {
+				sb.WriteString(gap)
+				sb.WriteString(renderTileLine(t.Val, l == tileHeight/2))
+			}
+			sb.WriteString(gap + resetStyle + "\r\n")
+		}
+		sb.WriteString(edge)
+	}
+
+	return sb.String()
+}
+
+// renderTileLine draws one line of a tile, which shows the value of the tile if
+// it's the middle line.
+func renderTileLine(val int, middle bool) string {
+	if val == 0 {
+		return background(palette.TileBackground) + strings.Repeat(" ", tileWidth)
+	}
+
+	text := ""
+	if middle {
+		text = strconv.Itoa(val)
+	}
+	left := (tileWidth - len(text)) / 2
+	right := tileWidth - len(text) - left
+	return background(palette.Tile(val)) + foreground(palette.TileText(val)) + bold +
+		strings.Repeat(" ", left) + text + strings.Repeat(" ", right) + resetStyle
+}
+
+// background returns the escape code for setting the background to a 24-bit colour.
+func background(c color.RGBA) string {
+	return fmt.Sprintf("\x1b[48;2;%d;%d;%dm", c.R, c.G, c.B)
+}
+
+// foreground returns the escape code for setting the text to a 24-bit colour.
+func foreground(c color.RGBA) string {
+	return fmt.Sprintf("\x1b[38;2;%d;%d;%dm", c.R, c.G, c.B)
+}
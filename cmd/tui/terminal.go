@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// ANSI escape codes for controlling the terminal.
+const (
+	enterAltScreen = "\x1b[?1049h\x1b[?25l" // switch to the alternate screen and hide the cursor
+	exitAltScreen  = "\x1b[?25h\x1b[?1049l" // show the cursor and return to the main screen
+	clearScreen    = "\x1b[H\x1b[2J"
+	resetStyle     = "\x1b[0m"
+	bold           = "\x1b[1m"
+)
+
+// makeRaw puts the terminal into raw mode, so key presses can be read one at a
+// time without being echoed. The returned function restores the previous mode.
+// stty is used rather than system calls so no extra dependencies are needed.
+func makeRaw() (func() error, error) {
+	state, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		_, err := stty(strings.TrimSpace(state))
+		return err
+	}, nil
+}
+
+// stty runs the stty command on the terminal attached to stdin.
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
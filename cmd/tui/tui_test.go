@@ -0,0 +1,55 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/z-riley/go-2048-battle/common/backend"
+	"github.com/z-riley/go-2048-battle/common/palette"
+)
+
+func TestParseKeys(t *testing.T) {
+	type tc struct {
+		input []byte
+		want  []action
+	}
+
+	for _, tc := range []tc{
+		{input: []byte("w"), want: []action{actionUp}},
+		{input: []byte("aSdz"), want: []action{actionLeft, actionDown, actionRight, actionUndo}},
+		{input: []byte("\x1b[A\x1b[D"), want: []action{actionUp, actionLeft}},
+		{input: []byte("\x1bOB"), want: []action{actionDown}},
+		{input: []byte("\x1b"), want: []action{actionQuit}},
+		{input: []byte{0x03}, want: []action{actionQuit}},
+		{input: []byte("x\x1b[H"), want: nil},
+	} {
+		got := parseKeys(tc.input)
+		if !reflect.DeepEqual(tc.want, got) {
+			t.Errorf("Expected:\n<%v>\nGot:\n<%v>", tc.want, got)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	game := backend.NewGame(&backend.Opts{SaveToDisk: false, Width: 3, Height: 5, Seed: 1})
+	game.Grid.Tiles[0][0].Val = 2048
+	out := render(game)
+
+	// Tiles should use the same colours as the SDL client
+	for _, want := range []string{
+		background(palette.Tile2048) + foreground(palette.TileText(2048)),
+		background(palette.TileBackground),
+		"2048",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q", want)
+		}
+	}
+
+	// Every row of tiles is drawn, with an edge above and below each
+	lines := strings.Count(renderGrid(game.Grid), "\r\n")
+	if want := 5*tileHeight + 6; lines != want {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", want, lines)
+	}
+}
@@ -0,0 +1,193 @@
+// Package achievements unlocks achievements from the events of singleplayer games,
+// and remembers which have been unlocked between sessions.
+package achievements
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/z-riley/go-2048-battle/common/backend"
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+	"github.com/z-riley/go-2048-battle/common/backend/store"
+	"github.com/z-riley/go-2048-battle/log"
+)
+
+// ID is the unique identifier for an achievement.
+type ID string
+
+// Achievement is something for the player to do in a game.
+type Achievement struct {
+	ID          ID
+	Name        string
+	Description string
+
+	// unlockedBy returns whether an event unlocks the achievement, given the
+	// progress of the game before it
+	unlockedBy func(p progress, e backend.Event) bool
+}
+
+// All contains every achievement, in the order they're listed.
+var All = []Achievement{
+	{
+		ID:          "reach2048",
+		Name:        "Winner",
+		Description: "Reach the 2048 tile",
+		unlockedBy: func(_ progress, e backend.Event) bool {
+			return e.Type == backend.EventWin
+		},
+	},
+	{
+		ID:          "fast2048",
+		Name:        "Speed run",
+		Description: "Reach the 2048 tile in under 5 minutes",
+		unlockedBy: func(_ progress, e backend.Event) bool {
+			return e.Type == backend.EventWin && e.Time < 5*time.Minute
+		},
+	},
+	{
+		ID:          "noUp",
+		Name:        "Grounded",
+		Description: "Reach the 2048 tile without moving up",
+		unlockedBy: func(p progress, e backend.Event) bool {
+			return e.Type == backend.EventWin && !p.movedUp
+		},
+	},
+	{
+		ID:          "merge1024",
+		Name:        "Big merge",
+		Description: "Merge two 1024 tiles",
+		unlockedBy: func(_ progress, e backend.Event) bool {
+			return e.Type == backend.EventMerge && e.Value == 2048
+		},
+	},
+	{
+		ID:          "reach4096",
+		Name:        "Beyond",
+		Description: "Make a 4096 tile",
+		unlockedBy: func(_ progress, e backend.Event) bool {
+			return e.Type == backend.EventMerge && e.Value >= 4096
+		},
+	},
+	{
+		ID:          "score20000",
+		Name:        "High roller",
+		Description: "Score 20,000 points in one game",
+		unlockedBy: func(_ progress, e backend.Event) bool {
+			return e.Score >= 20000
+		},
+	},
+}
+
+// progress is what has happened in the current game, for achievements which
+// depend on more than one event.
+type progress struct {
+	movedUp bool
+}
+
+// Unlocks are the achievements which have been unlocked, and when.
+type Unlocks map[ID]time.Time
+
+// Evaluator unlocks achievements from the events of the games it watches.
+type Evaluator struct {
+	unlocks  Unlocks
+	progress progress
+	new      []Achievement // unlocked since Unlocked was last called
+	store    *store.Store
+}
+
+// NewEvaluator constructs an evaluator which saves unlocks to a store, starting
+// from the unlocks already in it.
+func NewEvaluator(s *store.Store) *Evaluator {
+	unlocks, err := LoadUnlocks(s)
+	if err != nil {
+		log.Println("No achievements file found. Creating new one")
+		unlocks = Unlocks{}
+	}
+	return &Evaluator{unlocks: unlocks, store: s}
+}
+
+// Watch evaluates the events of a game until the returned function is called. A
+// game which has already started may have moved up, so it can't unlock
+// achievements which need it not to have.
+func (ev *Evaluator) Watch(g *backend.Game) (stop func()) {
+	ev.progress = progress{movedUp: g.Moves > 0}
+	return g.Subscribe(ev.handle)
+}
+
+// handle updates the progress of the game and unlocks any achievements from an
+// event.
+func (ev *Evaluator) handle(e backend.Event) {
+	for _, a := range All {
+		if !ev.Unlocked(a.ID) && a.unlockedBy(ev.progress, e) {
+			ev.unlock(a)
+		}
+	}
+
+	switch e.Type {
+	case backend.EventReset:
+		ev.progress = progress{}
+	case backend.EventMove:
+		if e.Dir == grid.DirUp {
+			ev.progress.movedUp = true
+		}
+	}
+}
+
+// unlock unlocks an achievement and saves it.
+func (ev *Evaluator) unlock(a Achievement) {
+	ev.unlocks[a.ID] = time.Now()
+	ev.new = append(ev.new, a)
+	if err := ev.unlocks.Save(ev.store); err != nil {
+		log.Println("Failed to save achievements:", err)
+	}
+}
+
+// Unlocked returns whether an achievement has been unlocked.
+func (ev *Evaluator) Unlocked(id ID) bool {
+	_, ok := ev.unlocks[id]
+	return ok
+}
+
+// New returns the achievements which have been unlocked since it was last called.
+func (ev *Evaluator) New() []Achievement {
+	a := ev.new
+	ev.new = nil
+	return a
+}
+
+// Serialise converts the unlocks into JSON.
+func (u Unlocks) Serialise() ([]byte, error) {
+	return json.Marshal(u)
+}
+
+// Deserialise loads the JSON representation into memory.
+func (u *Unlocks) Deserialise(j []byte) error {
+	return json.Unmarshal(j, u)
+}
+
+// Save saves the unlocks to a store.
+func (u Unlocks) Save(s *store.Store) error {
+	j, err := u.Serialise()
+	if err != nil {
+		return err
+	}
+	return s.SaveBytes(j)
+}
+
+// LoadUnlocks loads unlocks from a store.
+func LoadUnlocks(s *store.Store) (Unlocks, error) {
+	b, err := s.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	u := Unlocks{}
+	if err := u.Deserialise(b); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// NewStore returns the store which unlocked achievements are saved to.
+func NewStore() *store.Store {
+	return store.NewStore(".achievements.bruh")
+}
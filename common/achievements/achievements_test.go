@@ -0,0 +1,105 @@
+package achievements
+
+import (
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/z-riley/go-2048-battle/common/backend"
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+	"github.com/z-riley/go-2048-battle/common/backend/store"
+)
+
+func TestEvaluator(t *testing.T) {
+	const filename = ".test_achievements.bruh"
+	s := store.NewStore(filename)
+	defer func() {
+		_ = os.Remove(filename)
+	}()
+
+	type tc struct {
+		name   string
+		events []backend.Event
+		want   []ID
+	}
+
+	for _, c := range []tc{
+		{
+			name: "slow win after moving up",
+			events: []backend.Event{
+				{Type: backend.EventMove, Dir: grid.DirUp},
+				{Type: backend.EventMerge, Value: 2048},
+				{Type: backend.EventWin, Time: 10 * time.Minute},
+			},
+			want: []ID{"merge1024", "reach2048"},
+		},
+		{
+			name: "fast win without moving up",
+			events: []backend.Event{
+				{Type: backend.EventReset},
+				{Type: backend.EventMove, Dir: grid.DirLeft},
+				{Type: backend.EventWin, Time: time.Minute},
+			},
+			want: []ID{"fast2048", "noUp"},
+		},
+		{
+			name: "already unlocked",
+			events: []backend.Event{
+				{Type: backend.EventWin, Time: time.Minute},
+				{Type: backend.EventMerge, Value: 4096, Score: 30000},
+			},
+			want: []ID{"reach4096", "score20000"},
+		},
+	} {
+		ev := NewEvaluator(s)
+		for _, e := range c.events {
+			ev.handle(e)
+		}
+
+		var got []ID
+		for _, a := range ev.New() {
+			got = append(got, a.ID)
+		}
+		if !slices.Equal(got, c.want) {
+			t.Errorf("%s: Expected:\n<%v>\nGot:\n<%v>", c.name, c.want, got)
+		}
+	}
+
+	// Every unlock should have been saved
+	unlocks, err := LoadUnlocks(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unlocks) != len(All) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", len(All), len(unlocks))
+	}
+}
+
+func TestWatch(t *testing.T) {
+	const filename = ".test_achievements_watch.bruh"
+	s := store.NewStore(filename)
+	defer func() {
+		_ = os.Remove(filename)
+	}()
+
+	game := backend.NewGame(&backend.Opts{SaveToDisk: false, Width: 4, Height: 4, Seed: 7})
+	game.Grid.Tiles = grid.NewTiles(4, 4)
+	game.Grid.Tiles[0][2].Val = 1024
+	game.Grid.Tiles[0][3].Val = 1024
+	game.Timer.Set(time.Minute)
+
+	ev := NewEvaluator(s)
+	stop := ev.Watch(game)
+	game.ExecuteMove(grid.DirLeft)
+	stop()
+
+	for _, id := range []ID{"reach2048", "fast2048", "noUp", "merge1024"} {
+		if !ev.Unlocked(id) {
+			t.Errorf("Expected:\n<%v>\nGot:\n<%v>", id+" unlocked", false)
+		}
+	}
+	if ev.Unlocked("reach4096") {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", "reach4096 locked", true)
+	}
+}
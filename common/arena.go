@@ -12,22 +12,20 @@ import (
 	"github.com/z-riley/go-2048-battle/common/backend/grid"
 	"github.com/z-riley/go-2048-battle/log"
 	"github.com/z-riley/gogl"
-	"golang.org/x/exp/constraints"
 )
 
 // Adjustable settings.
 const (
-	TileSizePx        float64 = 72   // the width and height of a tile, in pixels
+	TileSizePx        float64 = 72   // the width and height of a tile on a default size grid, in pixels
 	TileCornerRadius  float64 = 3    // the radius, in pixels, of the rounded corners of the tiles
 	TileBoundryFactor float64 = 0.15 // the gap between tiles as a proportion of the tile size
 )
 
 // Derived constants.
 const (
-	ArenaSizePx   = tileSpacingPx*4 + TileSizePx*TileBoundryFactor // the width and height of arena, in pixels
+	ArenaSizePx   = tileSpacingPx*grid.DefaultSize + TileSizePx*TileBoundryFactor // the maximum width and height of arena, in pixels
 	tileSpacingPx = TileSizePx * (1 + TileBoundryFactor)
 	tileFont      = FontPathBold
-	numTiles      = grid.GridSize
 )
 
 // tile is a visual representation of a game tile.
@@ -37,13 +35,17 @@ type tile struct {
 	destroy bool  // flag for self-destruction
 }
 
-// newTile constructs a new tile with the correct style.
-func newTile(sizePx float64, pos gogl.Vec, val int, posIdx coord) *tile {
+// newTile constructs a new tile with the correct style for the arena.
+func (a *Arena) newTile(sizePx float64, pos gogl.Vec, val int, posIdx coord) *tile {
+	text := strconv.Itoa(val)
+	if val == grid.Obstacle {
+		text = ""
+	}
 	return &tile{
 		tb: gogl.NewTextBox(gogl.NewCurvedRect(
 			sizePx, sizePx, TileCornerRadius, pos,
-		).SetStyle(gogl.Style{Colour: tileColour(val)}), strconv.Itoa(val), tileFont).
-			SetTextSize(tileFontSize(val)).
+		).SetStyle(gogl.Style{Colour: tileColour(val)}), text, tileFont).
+			SetTextSize(tileFontSize(val) * a.tileSize / TileSizePx).
 			SetTextColour(tileTextColour(val)),
 		pos: posIdx,
 	}
@@ -53,54 +55,44 @@ func newTile(sizePx float64, pos gogl.Vec, val int, posIdx coord) *tile {
 type animationState struct {
 	animations []animation
 	gameState  backend.Game
+	reload     bool // redraw the game state instead of animating
 }
 
 // Arena displays the grid of a game.
 type Arena struct {
-	pos         gogl.Vec                             // pixel position of the arena anchor
-	tiles       []*tile                              // every non-zero tile
-	bgTiles     [numTiles][numTiles]*gogl.CurvedRect // every grid space
-	background  *gogl.CurvedRect                     // the background of the arena
-	latestState backend.Game                         // used to detect changes in game state (for animations etc...)
-	animationCh chan animationState                  // for sending animations to animator goroutine
-}
-
-// NewArena constructs a new arena widget. pos is the top-left pixel of the
-// top-left tile (excluding the arena background).
-func NewArena(pos gogl.Vec) *Arena {
-	// Generate background tiles
-	bgTiles := [numTiles][numTiles]*gogl.CurvedRect{}
-	for i := range numTiles {
-		for j := range numTiles {
-			bgTiles[j][i] = gogl.NewCurvedRect(
-				TileSizePx, TileSizePx, TileCornerRadius,
-				gogl.Vec{
-					X: pos.X + float64(j)*tileSpacingPx,
-					Y: pos.Y + float64(i)*tileSpacingPx,
-				},
-			)
-			bgTiles[j][i].SetStyle(gogl.Style{Colour: TileBackgroundColour})
-		}
-	}
-
-	arenaBG := gogl.NewCurvedRect(
-		ArenaSizePx, ArenaSizePx,
-		TileCornerRadius,
-		gogl.Vec{
-			X: pos.X - TileSizePx*TileBoundryFactor,
-			Y: pos.Y - TileSizePx*TileBoundryFactor,
-		},
-	)
-	arenaBG.SetStyle(gogl.Style{Colour: ArenaBackgroundColour})
-
+	origin      gogl.Vec             // pixel position of the top-left tile of a default size arena
+	scale       float64              // the size of the arena relative to ArenaSizePx
+	pos         gogl.Vec             // pixel position of the arena anchor
+	cols, rows  int                  // the dimensions of the grid
+	tileSize    float64              // the width and height of a tile, in pixels
+	spacing     float64              // the distance between neighbouring tiles' anchors, in pixels
+	tiles       []*tile              // every non-zero tile
+	bgTiles     [][]*gogl.CurvedRect // every grid space
+	background  *gogl.CurvedRect     // the background of the arena
+	hint        *gogl.Triangle       // arrow for a suggested move. Nil if there is no hint
+	incoming    int                  // the number of obstacles waiting to land in the grid
+	meter       *gogl.CurvedRect     // shows the incoming obstacles. Nil if there are none
+	latestState backend.Game         // used to detect changes in game state (for animations etc...)
+	animationCh chan animationState  // for sending animations to animator goroutine
+}
+
+// NewArena constructs a new arena widget for a grid with the given number of
+// columns and rows. pos is the top-left pixel of the top-left tile (excluding
+// the arena background) of a default size arena. Tiles are scaled so the arena
+// never exceeds ArenaSizePx, and smaller dimensions are centred within it.
+func NewArena(pos gogl.Vec, cols, rows int) *Arena {
+	return NewScaledArena(pos, cols, rows, 1)
+}
+
+// NewScaledArena constructs a new arena widget which is scale times the size of
+// a normal arena, for showing several games at once.
+func NewScaledArena(pos gogl.Vec, cols, rows int, scale float64) *Arena {
 	a := Arena{
-		pos:         pos,
-		tiles:       make([]*tile, 0, numTiles*numTiles),
-		bgTiles:     bgTiles,
-		background:  arenaBG,
-		latestState: backend.Game{Grid: &grid.Grid{Tiles: [4][4]grid.Tile{}}},
+		origin:      pos,
+		scale:       scale,
 		animationCh: make(chan animationState, 50),
 	}
+	a.setSize(cols, rows)
 
 	// Begin listening to animation channel
 	go a.handleAnimations()
@@ -108,6 +100,45 @@ func NewArena(pos gogl.Vec) *Arena {
 	return &a
 }
 
+// setSize lays out the arena for a grid with the given number of columns and rows.
+func (a *Arena) setSize(cols, rows int) {
+	a.cols, a.rows = cols, rows
+	a.tileSize = a.scale * TileSizePx * grid.DefaultSize / float64(max(cols, rows))
+	a.spacing = a.tileSize * (1 + TileBoundryFactor)
+
+	// Centre the arena within the space taken by a default size arena
+	width := a.spacing*float64(cols) + a.tileSize*TileBoundryFactor
+	height := a.spacing*float64(rows) + a.tileSize*TileBoundryFactor
+	bgPos := gogl.Vec{
+		X: a.origin.X - a.scale*TileSizePx*TileBoundryFactor + (a.scale*ArenaSizePx-width)/2,
+		Y: a.origin.Y - a.scale*TileSizePx*TileBoundryFactor + (a.scale*ArenaSizePx-height)/2,
+	}
+	a.pos = gogl.Vec{
+		X: bgPos.X + a.tileSize*TileBoundryFactor,
+		Y: bgPos.Y + a.tileSize*TileBoundryFactor,
+	}
+
+	a.background = gogl.NewCurvedRect(width, height, TileCornerRadius, bgPos)
+	a.background.SetStyle(gogl.Style{Colour: ArenaBackgroundColour})
+
+	// Generate background tiles
+	a.bgTiles = make([][]*gogl.CurvedRect, rows)
+	for i := range rows {
+		a.bgTiles[i] = make([]*gogl.CurvedRect, cols)
+		for j := range cols {
+			a.bgTiles[i][j] = gogl.NewCurvedRect(
+				a.tileSize, a.tileSize, TileCornerRadius,
+				a.tilePos(coord{j, i}),
+			)
+			a.bgTiles[i][j].SetStyle(gogl.Style{Colour: TileBackgroundColour})
+		}
+	}
+
+	a.tiles = make([]*tile, 0, cols*rows)
+	a.incoming, a.meter = 0, nil
+	a.latestState = backend.Game{Grid: &grid.Grid{Tiles: grid.NewTiles(cols, rows)}}
+}
+
 // Destroy tears down the arena.
 func (a *Arena) Destroy() {}
 
@@ -115,15 +146,23 @@ func (a *Arena) Destroy() {}
 func (a *Arena) Draw(buf *gogl.FrameBuffer) {
 	a.background.Draw(buf)
 
-	for i := range numTiles {
-		for j := range numTiles {
-			a.bgTiles[j][i].Draw(buf)
+	for i := range a.bgTiles {
+		for j := range a.bgTiles[i] {
+			a.bgTiles[i][j].Draw(buf)
 		}
 	}
 
 	for _, t := range a.tiles {
 		t.tb.Draw(buf)
 	}
+
+	if a.hint != nil {
+		a.hint.Draw(buf)
+	}
+
+	if a.meter != nil {
+		a.meter.Draw(buf)
+	}
 }
 
 // Pos returns the top left pixel coordinate of the whole arena.
@@ -141,23 +180,21 @@ func (a *Arena) Height() float64 {
 	return a.background.Height()
 }
 
-// Load updates the arena to match the backend game data.
+// Load updates the arena to match the backend game data. The arena is resized
+// if the game's grid has different dimensions.
 func (a *Arena) Load(g backend.Game) {
+	if g.Grid.Width() != a.cols || g.Grid.Height() != a.rows {
+		a.setSize(g.Grid.Width(), g.Grid.Height())
+	}
+
 	var newTiles []*tile
-	for i := range numTiles {
-		for j := range numTiles {
+	for i := range g.Grid.Tiles {
+		for j := range g.Grid.Tiles[i] {
 			val := g.Grid.Tiles[i][j].Val
 			if val != 0 {
 				newTiles = append(newTiles,
-					newTile(
-						TileSizePx,
-						gogl.Vec{
-							X: a.pos.X + float64(j)*tileSpacingPx,
-							Y: a.pos.Y + float64(i)*tileSpacingPx,
-						},
-						val,
-						coord{j, i},
-					))
+					a.newTile(a.tileSize, a.tilePos(coord{j, i}), val, coord{j, i}),
+				)
 			}
 		}
 	}
@@ -166,10 +203,75 @@ func (a *Arena) Load(g backend.Game) {
 
 // Reset clears the current game data from the arena.
 func (a *Arena) Reset() {
-	a.tiles = make([]*tile, 0, numTiles*numTiles)
+	a.tiles = make([]*tile, 0, a.cols*a.rows)
+	a.ClearHint()
 	a.SetNormal()
 }
 
+// SetHint highlights a suggested move with an arrow beside the edge of the arena
+// that the tiles would move towards. The hint is cleared when the grid changes.
+func (a *Arena) SetHint(dir grid.Direction) {
+	const (
+		gap    = TileSizePx * 0.1 // distance between the arena and the arrow
+		length = TileSizePx * 0.2 // distance from the base to the tip of the arrow
+	)
+	pos, width, height := a.Pos(), a.Width(), a.Height()
+	centre := gogl.Vec{X: pos.X + width/2, Y: pos.Y + height/2}
+
+	var v1, v2, v3 gogl.Vec
+	switch dir {
+	case grid.DirUp:
+		base := pos.Y - gap
+		v1, v2, v3 = gogl.Vec{X: centre.X - length, Y: base}, gogl.Vec{X: centre.X + length, Y: base}, gogl.Vec{X: centre.X, Y: base - length}
+	case grid.DirDown:
+		base := pos.Y + height + gap
+		v1, v2, v3 = gogl.Vec{X: centre.X - length, Y: base}, gogl.Vec{X: centre.X + length, Y: base}, gogl.Vec{X: centre.X, Y: base + length}
+	case grid.DirLeft:
+		base := pos.X - gap
+		v1, v2, v3 = gogl.Vec{X: base, Y: centre.Y - length}, gogl.Vec{X: base, Y: centre.Y + length}, gogl.Vec{X: base - length, Y: centre.Y}
+	case grid.DirRight:
+		base := pos.X + width + gap
+		v1, v2, v3 = gogl.Vec{X: base, Y: centre.Y - length}, gogl.Vec{X: base, Y: centre.Y + length}, gogl.Vec{X: base + length, Y: centre.Y}
+	default:
+		a.ClearHint()
+		return
+	}
+
+	a.hint = gogl.NewTriangle(v1, v2, v3).SetStyle(gogl.Style{
+		Colour: ButtonOrangeColour,
+		Bloom:  6,
+	})
+}
+
+// ClearHint removes the suggested move, if there is one.
+func (a *Arena) ClearHint() {
+	a.hint = nil
+}
+
+// SetIncoming shows a meter beside the left edge of the arena for obstacles which
+// are waiting to land in the grid. Each obstacle fills the height of one tile.
+func (a *Arena) SetIncoming(n int) {
+	if n == a.incoming {
+		return
+	}
+	a.incoming = n
+	if n <= 0 {
+		a.meter = nil
+		return
+	}
+
+	pos, height := a.Pos(), a.Height()
+	width := a.tileSize * 0.12
+	fill := min(height, float64(n)*a.spacing)
+	a.meter = gogl.NewCurvedRect(
+		width, fill, width/2,
+		gogl.Vec{X: pos.X - 2*width, Y: pos.Y + height - fill},
+	).SetStyle(gogl.Style{
+		Colour: gogl.DarkRed,
+		Bloom:  6,
+	})
+}
+
 // SetNormal makes the arena show its losing state.
 func (a *Arena) SetNormal() {
 	a.background.SetStyle(gogl.Style{Colour: ArenaBackgroundColour})
@@ -202,6 +304,13 @@ func (a *Arena) Update(game backend.Game) {
 	if grid.EqualGrid(a.latestState.Grid.Tiles, game.Grid.Tiles) {
 		return
 	}
+	a.ClearHint()
+
+	// A grid of a different size can't be animated, so redraw it instead
+	if game.Grid.Width() != a.cols || game.Grid.Height() != a.rows {
+		a.Load(game)
+		return
+	}
 
 	// Calculate the movement of each tile
 	tileAnimations := generateAnimations(a.latestState.Grid.Tiles, game.Grid.Tiles, game.Grid.LastMove)
@@ -210,14 +319,37 @@ func (a *Arena) Update(game backend.Game) {
 	}
 
 	// Send the animations for the turn down the animation channel
-	a.animationCh <- animationState{tileAnimations, game}
+	a.animationCh <- animationState{animations: tileAnimations, gameState: game}
+}
+
+// Reload redraws the arena to match the given game state without animating, once
+// any pending animations have finished. Use it when the game state has changed in
+// a way that isn't a move, such as an undo.
+func (a *Arena) Reload(game backend.Game) {
+	a.latestState.Grid.Tiles = game.Grid.Tiles
+	a.ClearHint()
+	a.animationCh <- animationState{gameState: game, reload: true}
 }
 
 // handleAnimations executes animations from the animation channel.
 func (a *Arena) handleAnimations() {
 	for animationState := range a.animationCh {
+		if animationState.reload {
+			a.Load(animationState.gameState)
+			continue
+		}
+
 		// Listen to errors being produced by animations
-		errCh := make(chan error, numTiles*numTiles)
+		errCh := make(chan error, a.cols*a.rows)
+
+		// Obstacles broken by a merge disappear straight away
+		for _, animation := range animationState.animations {
+			if animation, ok := animation.(breakAnimation); ok {
+				if t, err := a.tileAtIdx(animation.dest); err == nil {
+					t.destroy = true
+				}
+			}
+		}
 
 		// Animate stage 1: tiles moving and combining
 		var wg sync.WaitGroup
@@ -345,24 +477,22 @@ func (a *Arena) animateSpawn(animation spawnAnimation, errCh chan error, wg *syn
 	}
 
 	// Make a small new tile
-	const originalSize = TileSizePx / 6
-	newTile := newTile(
+	originalSize := a.tileSize / 6
+	newTile := a.newTile(
 		originalSize,
-		gogl.Vec{
-			X: a.pos.X + float64(dest.x)*tileSpacingPx + (TileSizePx-originalSize)/2,
-			Y: a.pos.Y + float64(dest.y)*tileSpacingPx + (TileSizePx-originalSize)/2,
-		},
+		gogl.Add(a.tilePos(dest), gogl.Vec{
+			X: (a.tileSize - originalSize) / 2,
+			Y: (a.tileSize - originalSize) / 2,
+		}),
 		newVal,
 		dest,
 	)
 	a.tiles = append(a.tiles, newTile)
 
 	// Animate tile growing to normal size
-	const (
-		growPx   = (TileSizePx - originalSize) / 2
-		steps    = 10
-		stepSize = growPx / steps
-	)
+	const steps = 10
+	growPx := (a.tileSize - originalSize) / 2
+	stepSize := growPx / steps
 	shape := newTile.tb.Shape.(*gogl.CurvedRect)
 	originalPos := shape.GetPos() // position of shape before animation starts
 	for i := float64(0); i <= growPx; i += stepSize {
@@ -386,15 +516,7 @@ func (a *Arena) animateNewFromCombine(animation newFromCombineAnimation, errCh c
 	}
 
 	// Make a new tile
-	newTile := newTile(
-		TileSizePx,
-		gogl.Vec{
-			X: a.pos.X + float64(dest.x)*tileSpacingPx,
-			Y: a.pos.Y + float64(dest.y)*tileSpacingPx,
-		},
-		newVal,
-		dest,
-	)
+	newTile := a.newTile(a.tileSize, a.tilePos(dest), newVal, dest)
 	a.tiles = append(a.tiles, newTile)
 
 	// Animate tile growing and shrinking back to normal size
@@ -403,15 +525,15 @@ func (a *Arena) animateNewFromCombine(animation newFromCombineAnimation, errCh c
 	originalPos := shape.GetPos() // position of shape before animation starts
 	for i := float64(1); i <= expandPx; i++ {
 		shape.SetPos(gogl.Sub(originalPos, gogl.Vec{X: i, Y: i}))
-		shape.SetHeight(TileSizePx + i*2)
-		shape.SetWidth(TileSizePx + i*2)
+		shape.SetHeight(a.tileSize + i*2)
+		shape.SetWidth(a.tileSize + i*2)
 		time.Sleep(10 * time.Millisecond)
 	}
 	time.Sleep(30 * time.Millisecond)
 	for i := float64(expandPx) - 1; i > 0; i-- {
 		shape.SetPos(gogl.Sub(originalPos, gogl.Vec{X: i, Y: i}))
-		shape.SetHeight(TileSizePx + i*2)
-		shape.SetWidth(TileSizePx + i*2)
+		shape.SetHeight(a.tileSize + i*2)
+		shape.SetWidth(a.tileSize + i*2)
 		time.Sleep(10 * time.Millisecond)
 	}
 }
@@ -430,8 +552,8 @@ func (a *Arena) tileAtIdx(pos coord) (*tile, error) {
 // tilePos generates the pixel position of a tile on the grid based on its x and y index.
 func (a *Arena) tilePos(pos coord) gogl.Vec {
 	return gogl.Vec{
-		X: a.pos.X + float64(pos.x)*tileSpacingPx,
-		Y: a.pos.Y + float64(pos.y)*tileSpacingPx,
+		X: a.pos.X + float64(pos.x)*a.spacing,
+		Y: a.pos.Y + float64(pos.y)*a.spacing,
 	}
 }
 
@@ -563,6 +685,32 @@ func (a moveToCombineAnimation) String() string {
 	return fmt.Sprint("move-to-combine from ", a.origin, " to ", a.dest)
 }
 
+// breakAnimation represents an obstacle being broken. Satisfies the animation
+// interface.
+type breakAnimation struct {
+	dest coord // tile index
+}
+
+// Origin satisfies the animation interface.
+func (a breakAnimation) Origin() (coord, error) {
+	return coord{-1, -1}, errFieldDoesNotExist
+}
+
+// Dest satisfies the animation interface.
+func (a breakAnimation) Dest() coord {
+	return a.dest
+}
+
+// NewVal satisfies the animation interface.
+func (a breakAnimation) NewVal() (int, error) {
+	return 0, errFieldDoesNotExist
+}
+
+// String satisfies the animation interface.
+func (a breakAnimation) String() string {
+	return fmt.Sprint("break at ", a.dest)
+}
+
 // newFromCombineAnimation represents a new tile being created from a combination.
 // Satisfies the animation interface.
 type newFromCombineAnimation struct {
@@ -591,7 +739,7 @@ func (a newFromCombineAnimation) String() string {
 }
 
 // generateAnimations generates animation data for transitioning between grid states.
-func generateAnimations(before, after [numTiles][numTiles]grid.Tile, dir grid.Direction) []animation {
+func generateAnimations(before, after [][]grid.Tile, dir grid.Direction) []animation {
 	var animations []animation
 
 	if dir == grid.DirLeft || dir == grid.DirRight {
@@ -626,12 +774,8 @@ func generateAnimations(before, after [numTiles][numTiles]grid.Tile, dir grid.Di
 		}
 	} else {
 		// Vertical move; evaluate column-by-column
-		for i := range before {
-			rowAnimations := generateRowAnimations(
-				[numTiles]grid.Tile{before[0][i], before[1][i], before[2][i], before[3][i]},
-				[numTiles]grid.Tile{after[0][i], after[1][i], after[2][i], after[3][i]},
-				dir,
-			)
+		for i := range before[0] {
+			rowAnimations := generateRowAnimations(column(before, i), column(after, i), dir)
 
 			for _, rowAnimation := range rowAnimations {
 				switch a := rowAnimation.(type) {
@@ -660,6 +804,15 @@ func generateAnimations(before, after [numTiles][numTiles]grid.Tile, dir grid.Di
 		}
 	}
 
+	// Obstacles never move, so one has been broken if it's been replaced
+	for y := range before {
+		for x := range before[y] {
+			if before[y][x].Val == grid.Obstacle && after[y][x].UUID != before[y][x].UUID {
+				animations = append(animations, breakAnimation{dest: coord{x, y}})
+			}
+		}
+	}
+
 	return animations
 }
 
@@ -781,15 +934,19 @@ func (a newFromCombineRowAnimation) String() string {
 }
 
 // generateAnimations generates animation data for a row of tiles.
-func generateRowAnimations(before, after [numTiles]grid.Tile, dir grid.Direction) []rowAnimation {
+func generateRowAnimations(before, after []grid.Tile, dir grid.Direction) []rowAnimation {
 	var rowAnimations []rowAnimation
 
 	// Build a map of each tile's "before" position, indexed by their UUID
-	beforeUUIDs := make(map[uuid.UUID]int, numTiles)
+	beforeUUIDs := make(map[uuid.UUID]int, len(before))
 	for x := range before {
 		beforeUUIDs[before[x].UUID] = x
 	}
 
+	// The tiles that combined in the last turn can be ascertained by replaying
+	// the move on the "before" row
+	combines := combineOrigins(before, dir)
+
 	for x := range after {
 		// Like UUIDs indicates that a tile has moved
 		beforePos, ok := beforeUUIDs[after[x].UUID]
@@ -808,100 +965,12 @@ func generateRowAnimations(before, after [numTiles]grid.Tile, dir grid.Direction
 				newVal: after[x].Val,
 			})
 
-			// The tiles that combined in the last turn can be ascertained from which tiles are
-			// missing, and the direction of movement
-
-			// Get the other tiles in the row that went missing after the move
-			missingTiles := make(map[uuid.UUID]grid.Tile)
-			for _, tile := range before {
-				if tile.Val != 0 {
-					missingTiles[tile.UUID] = tile
-				}
-			}
-			for _, tile := range after {
-				delete(missingTiles, tile.UUID)
-			}
-
-			// Count the number of combinations as a result of the move
-			var numCombines int
-			for _, tile := range after {
-				if tile.Cmb {
-					numCombines++
-				}
-			}
-
-			// Get the positions of the tiles that went missing after the move
-			for uuid := range missingTiles {
-				origin := beforeUUIDs[uuid]
-				dest := x
-
-				isLegalMove := func(origin, dest int, dir grid.Direction) bool {
-					if origin == dest {
-						return false
-					}
-
-					// If there 4 tiles before, the maximum travel distance cannot be
-					// more than 2 in the direction of travel
-					const maxTravelDist = 2
-					travelDist := abs(dest - origin)
-
-					isFourLikeTiles := numCombines == 2 &&
-						before[0].Val == before[1].Val &&
-						before[0].Val == before[2].Val &&
-						before[0].Val == before[3].Val
-
-					isTwoPairsOfTiles := !isFourLikeTiles && numCombines == 2 &&
-						before[0].Val == before[1].Val &&
-						before[2].Val == before[3].Val
-
-					switch dir {
-					case grid.DirLeft, grid.DirUp:
-						// Index should get smaller
-						if origin < dest {
-							return false
-						}
-						// Manual exclusion for 2,2,2,2 situations
-						if isFourLikeTiles {
-							if travelDist > maxTravelDist {
-								return false
-							}
-							if origin == 2 && dest == 0 {
-								return false
-							}
-						}
-						// Manual exclusion for 2,2,4,4 situations
-						if isTwoPairsOfTiles {
-							if origin == 2 && dest == 0 {
-								return false
-							}
-							if origin == 3 && dest == 0 {
-								return false
-							}
-						}
-
-					case grid.DirRight, grid.DirDown:
-						// Index value should get larger
-						if origin > dest {
-							return false
-						}
-						// Manual exclusion for 2,2,2,2 and 2,2,4,4 situations
-						if isFourLikeTiles || isTwoPairsOfTiles {
-							if travelDist > maxTravelDist {
-								return false
-							}
-							if origin == 1 && dest == 3 {
-								return false
-							}
-						}
-					}
-
-					return true
-				}(origin, dest, dir)
-
-				if isLegalMove {
+			// A tile which combines without moving doesn't need animating
+			for _, origin := range combines[x] {
+				if origin != x {
 					rowAnimations = append(rowAnimations, moveToCombineRowAnimation{
 						origin: origin,
-						dest:   dest,
+						dest:   x,
 					})
 				}
 			}
@@ -922,6 +991,51 @@ func generateRowAnimations(before, after [numTiles]grid.Tile, dir grid.Direction
 	return rowAnimations
 }
 
+// combineOrigins works out which tiles in a row combine when moved in the given
+// direction. Returns the original indices of each pair of combining tiles, keyed by
+// the index they combine into.
+func combineOrigins(before []grid.Tile, dir grid.Direction) map[int][]int {
+	// Iterate from the edge that the tiles are moving towards
+	start, step := 0, 1
+	if dir == grid.DirRight || dir == grid.DirDown {
+		start, step = len(before)-1, -1
+	}
+
+	origins := make(map[int][]int)
+	dest := start - step // where the previous tile ended up
+	prev := -1           // index of the previous tile, or -1 if it can't be combined with
+	for i := start; i >= 0 && i < len(before); i += step {
+		if before[i].Val == 0 {
+			continue
+		}
+		if before[i].Val == grid.Obstacle {
+			// Tiles can't move past an obstacle
+			dest, prev = i, -1
+			continue
+		}
+
+		if prev != -1 && before[prev].Val == before[i].Val {
+			origins[dest] = []int{prev, i}
+			prev = -1
+			continue
+		}
+
+		dest += step
+		prev = i
+	}
+
+	return origins
+}
+
+// column returns a column of tiles from a grid.
+func column(tiles [][]grid.Tile, col int) []grid.Tile {
+	out := make([]grid.Tile, len(tiles))
+	for row := range tiles {
+		out[row] = tiles[row][col]
+	}
+	return out
+}
+
 // must panics if err is not nil.
 func must[T any](val T, err error) T {
 	if err != nil {
@@ -929,11 +1043,3 @@ func must[T any](val T, err error) T {
 	}
 	return val
 }
-
-// abs returns the absolute value of a signed integer.
-func abs[T constraints.Signed](a T) T {
-	if a >= 0 {
-		return a
-	}
-	return -a
-}
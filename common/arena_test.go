@@ -11,7 +11,7 @@ import (
 func TestGenerateRowAnimations(t *testing.T) {
 	type tc struct {
 		name          string
-		before, after [numTiles]grid.Tile
+		before, after []grid.Tile
 		dir           grid.Direction
 		want          []rowAnimation
 	}
@@ -25,13 +25,13 @@ func TestGenerateRowAnimations(t *testing.T) {
 	for _, tc := range []tc{
 		{
 			name: "Moving tiles",
-			before: [4]grid.Tile{
+			before: []grid.Tile{
 				{Val: 0, Cmb: false, UUID: id[0]},
 				{Val: 2, Cmb: false, UUID: id[1]},
 				{Val: 0, Cmb: false, UUID: id[2]},
 				{Val: 4, Cmb: false, UUID: id[3]},
 			},
-			after: [4]grid.Tile{
+			after: []grid.Tile{
 				{Val: 2, Cmb: false, UUID: id[1]},
 				{Val: 4, Cmb: false, UUID: id[3]},
 				{Val: 0, Cmb: false, UUID: id[6]},
@@ -51,13 +51,13 @@ func TestGenerateRowAnimations(t *testing.T) {
 		},
 		{
 			name: "Combining 2 tiles with spawn",
-			before: [4]grid.Tile{
+			before: []grid.Tile{
 				{Val: 0, Cmb: false, UUID: id[0]},
 				{Val: 2, Cmb: false, UUID: id[1]},
 				{Val: 0, Cmb: false, UUID: id[2]},
 				{Val: 2, Cmb: false, UUID: id[3]},
 			},
-			after: [4]grid.Tile{
+			after: []grid.Tile{
 				{Val: 4, Cmb: true, UUID: id[4]},
 				{Val: 0, Cmb: false, UUID: id[5]},
 				{Val: 0, Cmb: false, UUID: id[6]},
@@ -85,13 +85,13 @@ func TestGenerateRowAnimations(t *testing.T) {
 		},
 		{
 			name: "Combining 2 sets of 2 tiles",
-			before: [4]grid.Tile{
+			before: []grid.Tile{
 				{Val: 2, Cmb: false, UUID: id[0]},
 				{Val: 2, Cmb: false, UUID: id[1]},
 				{Val: 4, Cmb: false, UUID: id[2]},
 				{Val: 4, Cmb: false, UUID: id[3]},
 			},
-			after: [4]grid.Tile{
+			after: []grid.Tile{
 				{Val: 0, Cmb: false, UUID: id[4]},
 				{Val: 0, Cmb: false, UUID: id[5]},
 				{Val: 4, Cmb: true, UUID: id[6]},
@@ -121,15 +121,41 @@ func TestGenerateRowAnimations(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "Combining beside an obstacle",
+			before: []grid.Tile{
+				{Val: 2, Cmb: false, UUID: id[0]},
+				{Val: grid.Obstacle, Cmb: false, UUID: id[1]},
+				{Val: 2, Cmb: false, UUID: id[2]},
+				{Val: 2, Cmb: false, UUID: id[3]},
+			},
+			after: []grid.Tile{
+				{Val: 2, Cmb: false, UUID: id[0]},
+				{Val: grid.Obstacle, Cmb: false, UUID: id[1]},
+				{Val: 4, Cmb: true, UUID: id[4]},
+				{Val: 0, Cmb: false, UUID: id[5]},
+			},
+			dir: grid.DirLeft,
+			want: []rowAnimation{
+				newFromCombineRowAnimation{
+					dest:   2,
+					newVal: 4,
+				},
+				moveToCombineRowAnimation{
+					origin: 3,
+					dest:   2,
+				},
+			},
+		},
 		{
 			name: "Combining 4 similar tiles",
-			before: [4]grid.Tile{
+			before: []grid.Tile{
 				{Val: 2, Cmb: false, UUID: id[0]},
 				{Val: 2, Cmb: false, UUID: id[1]},
 				{Val: 2, Cmb: false, UUID: id[2]},
 				{Val: 2, Cmb: false, UUID: id[3]},
 			},
-			after: [4]grid.Tile{
+			after: []grid.Tile{
 				{Val: 0, Cmb: false, UUID: id[4]},
 				{Val: 0, Cmb: false, UUID: id[5]},
 				{Val: 4, Cmb: true, UUID: id[6]},
@@ -159,6 +185,50 @@ func TestGenerateRowAnimations(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "Combining 5 similar tiles",
+			before: []grid.Tile{
+				{Val: 2, Cmb: false, UUID: id[0]},
+				{Val: 2, Cmb: false, UUID: id[1]},
+				{Val: 2, Cmb: false, UUID: id[2]},
+				{Val: 2, Cmb: false, UUID: id[3]},
+				{Val: 2, Cmb: false, UUID: id[4]},
+			},
+			after: []grid.Tile{
+				{Val: 4, Cmb: true, UUID: id[5]},
+				{Val: 4, Cmb: true, UUID: id[6]},
+				{Val: 2, Cmb: false, UUID: id[4]},
+				{Val: 0, Cmb: false, UUID: id[7]},
+				{Val: 0, Cmb: false, UUID: id[8]},
+			},
+			dir: grid.DirLeft,
+			want: []rowAnimation{
+				newFromCombineRowAnimation{
+					dest:   0,
+					newVal: 4,
+				},
+				moveToCombineRowAnimation{
+					origin: 1,
+					dest:   0,
+				},
+				newFromCombineRowAnimation{
+					dest:   1,
+					newVal: 4,
+				},
+				moveToCombineRowAnimation{
+					origin: 2,
+					dest:   1,
+				},
+				moveToCombineRowAnimation{
+					origin: 3,
+					dest:   1,
+				},
+				moveRowAnimation{
+					origin: 4,
+					dest:   2,
+				},
+			},
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			got := generateRowAnimations(tc.before, tc.after, tc.dir)
@@ -2,6 +2,7 @@ package backend
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/z-riley/go-2048-battle/common/backend/grid"
 	"github.com/z-riley/go-2048-battle/common/backend/store"
@@ -14,31 +15,71 @@ type Game struct {
 	Score     int        `json:"score"`
 	HighScore int        `json:"highScore"`
 	Timer     *Timer     `json:"time"`
+	History   *History   `json:"history"`
+	Replay    *Replay    `json:"-"` // only recorded if enabled in the options
 
-	store *store.Store
-	opts  *Opts
+	// Moves is the number of moves made in the current game
+	Moves int `json:"moves"`
+	// WonAt is the game time when the winning tile was first reached, or zero if it
+	// hasn't been
+	WonAt time.Duration `json:"wonAt,omitempty"`
+	// Recorded is set once the current game has finished and been added to the
+	// stats and leaderboard, so undoing the last move and losing again doesn't count
+	// it twice
+	Recorded bool `json:"recorded,omitempty"`
+
+	store            *store.Store
+	replayStore      *store.Store
+	statsStore       *store.Store
+	leaderboardStore *store.Store
+	opts             *Opts
+
+	subscriptions    []subscription
+	nextSubscription int
 }
 
 // Opts contains the configuration for the backend game.
 type Opts struct {
-	SaveToDisk bool
+	SaveToDisk   bool
+	Width        int    // number of grid columns. Zero uses grid.DefaultSize
+	Height       int    // number of grid rows. Zero uses grid.DefaultSize
+	Seed         uint64 // seed for spawning tiles. Zero uses a random seed
+	UndoHistory  int    // maximum number of moves which can be undone. Zero disables undo
+	UndoLimit    int    // maximum number of undos per game. Zero for unlimited
+	RecordReplay bool   // whether to record a replay of the current game
+	PlayerName   string // the name which finished games are put on the leaderboard under
 }
 
 // NewGame returns the top-level struct for the game. If opts are nil, the
-// default is used.
-func NewGame(opts *Opts) *Game {
-	if opts == nil {
-		opts = &Opts{
-			SaveToDisk: true,
-		}
+// default is used. The caller's opts aren't changed.
+func NewGame(o *Opts) *Game {
+	opts := &Opts{
+		SaveToDisk: true,
+	}
+	if o != nil {
+		// Copy the options so the defaults aren't written back to the caller's
+		*opts = *o
+	}
+	if opts.Width == 0 {
+		opts.Width = grid.DefaultSize
+	}
+	if opts.Height == 0 {
+		opts.Height = grid.DefaultSize
+	}
+	if opts.Seed == 0 {
+		opts.Seed = grid.NewSeed()
 	}
 
 	g := &Game{
-		Grid:  grid.NewGrid(),
-		Score: 0,
-		Timer: NewTimer(),
-		store: store.NewStore(".save.bruh"),
-		opts:  opts,
+		Grid:             grid.NewSeededGrid(opts.Width, opts.Height, opts.Seed),
+		Score:            0,
+		Timer:            NewTimer(),
+		History:          NewHistory(),
+		store:            store.NewStore(".save.bruh"),
+		replayStore:      store.NewStore(".replay.bruh"),
+		statsStore:       NewStatsStore(),
+		leaderboardStore: NewLeaderboardStore(),
+		opts:             opts,
 	}
 
 	if g.opts.SaveToDisk {
@@ -50,28 +91,69 @@ func NewGame(opts *Opts) *Game {
 			}
 		}
 	}
+	if g.opts.RecordReplay && g.Replay == nil {
+		g.Replay = NewReplay(g.Grid)
+	}
 
 	return g
 }
 
 // Reset resets the game.
 func (g *Game) Reset() *Game {
+	g.recordUnfinished()
 	g.Grid.Reset()
 	g.Score = 0
 	g.Timer.Reset().Pause()
+	g.History.Clear()
+	g.clearProgress()
+	g.restartReplay()
+	return g
+}
+
+// ResetWithSeed resets the game, spawning tiles from the given seed.
+func (g *Game) ResetWithSeed(seed uint64) *Game {
+	g.recordUnfinished()
+	g.Grid.ResetWithSeed(seed)
+	g.Score = 0
+	g.Timer.Reset().Pause()
+	g.History.Clear()
+	g.clearProgress()
+	g.restartReplay()
 	return g
 }
 
 // Reset resets the game whilst preserving the current timer state.
 func (g *Game) ResetKeepTimer() *Game {
-	g.Grid.Reset()
+	return g.ResetKeepTimerWithSeed(grid.NewSeed())
+}
+
+// ResetKeepTimerWithSeed resets the game whilst preserving the current timer
+// state, spawning tiles from the given seed.
+func (g *Game) ResetKeepTimerWithSeed(seed uint64) *Game {
+	g.recordUnfinished()
+	g.Grid.ResetWithSeed(seed)
 	g.Score = 0
+	g.History.Clear()
+	g.clearProgress()
+	g.restartReplay()
 	return g
 }
 
 // ExecuteMove carries out a move in the given direction.
 func (g *Game) ExecuteMove(dir grid.Direction) {
-	pointsGained := g.Grid.Move(dir)
+	var before Snapshot
+	if g.opts.UndoHistory > 0 {
+		// Only copy the grid when undo is enabled, as this runs on every move
+		before = g.snapshot()
+	}
+	pointsGained, moved := g.Grid.Move(dir)
+	if moved && g.opts.UndoHistory > 0 {
+		g.History.record(before, g.opts.UndoHistory)
+	}
+	if moved {
+		g.Moves++
+		g.recordStep(Step{Action: ActionMove, Dir: dir, Spawn: g.Grid.LastSpawn})
+	}
 
 	// Update score
 	g.Score += pointsGained
@@ -79,13 +161,106 @@ func (g *Game) ExecuteMove(dir grid.Direction) {
 		g.HighScore = g.Score
 	}
 
+	if moved {
+		g.emitMove(dir)
+	}
+
+	if g.WonAt == 0 && g.Grid.HighestTile() >= winningTile {
+		g.WonAt = g.Timer.Duration()
+		g.emit(Event{Type: EventWin})
+	}
+
 	if g.Grid.Outcome() == grid.Lose {
 		g.Timer.Pause()
+		if moved {
+			g.emit(Event{Type: EventLose})
+		}
+		g.recordGame()
 	} else {
 		g.Timer.Resume()
 	}
 
 	// Note: The game should save on exit anyway but save after move just in case
+	g.saveAsync()
+}
+
+// clearProgress forgets the progress of the previous game, and tells the
+// subscribers that a new one has started.
+func (g *Game) clearProgress() {
+	g.Moves, g.WonAt, g.Recorded = 0, 0, false
+	g.emit(Event{Type: EventReset})
+}
+
+// recordGame adds the game to the stats and leaderboard now that it has finished,
+// if saving is enabled.
+func (g *Game) recordGame() {
+	if !g.opts.SaveToDisk || g.Recorded {
+		return
+	}
+	g.Recorded = true
+
+	stats, err := LoadStats(g.statsStore)
+	if err != nil {
+		log.Println("No stats file found. Creating new one")
+		stats = NewStats()
+	}
+	stats.Record(g)
+	if err := stats.Save(g.statsStore); err != nil {
+		log.Println("Failed to save stats:", err)
+	}
+
+	board, err := LoadLeaderboard(g.leaderboardStore)
+	if err != nil {
+		log.Println("No leaderboard file found. Creating new one")
+		board = NewLeaderboard()
+	}
+	if board.Add(g.run()) {
+		if err := board.Save(g.leaderboardStore); err != nil {
+			log.Println("Failed to save leaderboard:", err)
+		}
+	}
+}
+
+// recordUnfinished records a game which is being reset before it was lost, such as
+// one which has been won and carried on with, unless no moves have been made.
+func (g *Game) recordUnfinished() {
+	if g.Moves > 0 {
+		g.recordGame()
+	}
+}
+
+// run returns the game as a run for the leaderboard.
+func (g *Game) run() Run {
+	return Run{
+		Name:        g.opts.PlayerName,
+		Score:       g.Score,
+		HighestTile: g.Grid.HighestTile(),
+		Duration:    g.Timer.Duration(),
+		Moves:       g.Moves,
+		WonAt:       g.WonAt,
+		At:          time.Now(),
+		Replay:      g.Replay,
+	}
+}
+
+// restartReplay starts a new replay from the current grid, if replays are
+// being recorded.
+func (g *Game) restartReplay() {
+	if g.opts != nil && g.opts.RecordReplay {
+		g.Replay = NewReplay(g.Grid)
+	}
+}
+
+// recordStep adds a step to the replay, if one is being recorded.
+func (g *Game) recordStep(step Step) {
+	if g.Replay != nil {
+		step.Time = g.Timer.Duration()
+		g.Replay.record(step)
+	}
+}
+
+// saveAsync saves the game in the background, if saving is enabled.
+func (g *Game) saveAsync() {
 	if g.opts.SaveToDisk {
 		go func() {
 			if err := g.Save(); err != nil {
@@ -111,6 +286,11 @@ func (g Game) Save() error {
 	if err != nil {
 		return err
 	}
+	if g.Replay != nil {
+		if err := g.Replay.Save(g.replayStore); err != nil {
+			return err
+		}
+	}
 	return g.store.SaveBytes(j)
 }
 
@@ -126,5 +306,20 @@ func (g *Game) Load() error {
 	}
 	// Cmb flags are required to be unset for the animations to work correctly
 	g.Grid.ClearCmbFlags()
+	// Save files from before undo was supported have no history, and ones from
+	// before it was compact have snapshots which can't be restored
+	if g.History == nil {
+		g.History = NewHistory()
+	} else if !g.History.fits(g.Grid.Width(), g.Grid.Height()) {
+		g.History.Past, g.History.Future = []Snapshot{}, []Snapshot{}
+	}
+	// Continue the saved replay if it belongs to the loaded game
+	if g.opts.RecordReplay {
+		r, err := LoadReplay(g.replayStore)
+		if err != nil || r.Seed != g.Grid.Seed {
+			r = NewReplay(g.Grid)
+		}
+		g.Replay = r
+	}
 	return nil
 }
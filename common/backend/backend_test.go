@@ -1,8 +1,15 @@
 package backend
 
 import (
+	"errors"
+	"path/filepath"
+	"runtime"
+	"slices"
 	"testing"
 	"time"
+
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+	"github.com/z-riley/go-2048-battle/common/backend/store"
 )
 
 func TestSerialiseDeserialise(t *testing.T) {
@@ -34,3 +41,421 @@ func TestSerialiseDeserialise(t *testing.T) {
 		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", expected, got)
 	}
 }
+
+func TestSeededGameIsReproducible(t *testing.T) {
+	opts := &Opts{SaveToDisk: false, Seed: 1234}
+	game := NewGame(opts)
+	replay := NewGame(opts)
+
+	moves := []grid.Direction{
+		grid.DirLeft, grid.DirUp, grid.DirLeft, grid.DirDown,
+		grid.DirRight, grid.DirUp, grid.DirLeft, grid.DirUp,
+	}
+	for _, dir := range moves {
+		game.ExecuteMove(dir)
+		replay.ExecuteMove(dir)
+	}
+
+	// A game restored from a save should carry on spawning the same tiles
+	b, err := game.Serialise()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := NewGame(&Opts{SaveToDisk: false})
+	if err := restored.Deserialise(b); err != nil {
+		t.Fatal(err)
+	}
+	if restored.Grid.Seed != opts.Seed {
+		t.Errorf("Expected seed:\n<%v>\nGot:\n<%v>", opts.Seed, restored.Grid.Seed)
+	}
+
+	for _, dir := range moves {
+		replay.ExecuteMove(dir)
+		restored.ExecuteMove(dir)
+	}
+
+	if replay.Score != restored.Score {
+		t.Errorf("Expected score:\n<%v>\nGot:\n<%v>", replay.Score, restored.Score)
+	}
+	for i := range replay.Grid.Tiles {
+		for j := range replay.Grid.Tiles[i] {
+			if replay.Grid.Tiles[i][j].Val != restored.Grid.Tiles[i][j].Val {
+				t.Fatalf("Expected:\n<%v>\nGot:\n<%v>", replay.Grid.Debug(), restored.Grid.Debug())
+			}
+		}
+	}
+}
+
+func TestNewGameKeepsOpts(t *testing.T) {
+	opts := &Opts{SaveToDisk: false, UndoHistory: 10}
+	want := *opts
+	NewGame(opts)
+	if *opts != want {
+		t.Errorf("Expected:\n<%+v>\nGot:\n<%+v>", want, *opts)
+	}
+}
+
+func TestUndoRedo(t *testing.T) {
+	game := NewGame(&Opts{SaveToDisk: false, Seed: 99, UndoHistory: 10})
+
+	// Record the state after each move which changes the grid
+	type state struct {
+		tiles string
+		score int
+		moves int
+	}
+	states := []state{{game.Grid.Debug(), game.Score, game.Moves}}
+	for _, dir := range []grid.Direction{grid.DirLeft, grid.DirUp, grid.DirRight, grid.DirDown} {
+		before := game.Grid.Debug()
+		game.ExecuteMove(dir)
+		if game.Grid.Debug() != before {
+			states = append(states, state{game.Grid.Debug(), game.Score, game.Moves})
+		}
+	}
+	if len(states) < 3 {
+		t.Fatal("Expected at least 2 moves to change the grid")
+	}
+
+	// Undo every move
+	for i := len(states) - 2; i >= 0; i-- {
+		if !game.Undo() {
+			t.Fatalf("Undo failed with %d moves remaining", i+1)
+		}
+		if got := (state{game.Grid.Debug(), game.Score, game.Moves}); got != states[i] {
+			t.Fatalf("[%d] \nExpected:\n<%v>\nGot:\n<%v>", i, states[i], got)
+		}
+	}
+	if game.Undo() {
+		t.Error("Expected nothing to undo")
+	}
+
+	// Redo every move
+	for i := 1; i < len(states); i++ {
+		if !game.Redo() {
+			t.Fatalf("Redo failed at move %d", i)
+		}
+		if got := (state{game.Grid.Debug(), game.Score, game.Moves}); got != states[i] {
+			t.Fatalf("[%d] \nExpected:\n<%v>\nGot:\n<%v>", i, states[i], got)
+		}
+	}
+	if game.Redo() {
+		t.Error("Expected nothing to redo")
+	}
+
+	// A new move discards the undone moves
+	game.Undo()
+	for _, dir := range []grid.Direction{grid.DirLeft, grid.DirUp, grid.DirRight, grid.DirDown} {
+		if game.ExecuteMove(dir); !game.CanRedo() {
+			break
+		}
+	}
+	if game.CanRedo() {
+		t.Error("Expected redo history to be cleared by a new move")
+	}
+}
+
+func TestUndoWin(t *testing.T) {
+	game := NewGame(&Opts{SaveToDisk: false, Seed: 99, UndoHistory: 10})
+	game.Grid.SetValues([][]int{
+		{1024, 1024, 0, 0},
+		{0, 0, 0, 0},
+		{0, 0, 0, 0},
+		{0, 0, 0, 0},
+	})
+	game.Timer.Set(time.Minute)
+	game.ExecuteMove(grid.DirLeft)
+	if game.WonAt != time.Minute {
+		t.Fatalf("Expected:\n<%v>\nGot:\n<%v>", time.Minute, game.WonAt)
+	}
+
+	// Undoing the winning move means the game hasn't been won yet
+	game.Undo()
+	if game.WonAt != 0 || game.Moves != 0 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", []any{time.Duration(0), 0}, []any{game.WonAt, game.Moves})
+	}
+	game.Redo()
+	if game.WonAt != time.Minute || game.Moves != 1 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", []any{time.Minute, 1}, []any{game.WonAt, game.Moves})
+	}
+}
+
+func TestUndoLimit(t *testing.T) {
+	game := NewGame(&Opts{SaveToDisk: false, Seed: 99, UndoHistory: 10, UndoLimit: 1})
+	for _, dir := range []grid.Direction{grid.DirLeft, grid.DirUp, grid.DirRight, grid.DirDown} {
+		game.ExecuteMove(dir)
+	}
+
+	if !game.Undo() {
+		t.Fatal("Expected first undo to succeed")
+	}
+	if game.Undo() {
+		t.Error("Expected second undo to be refused")
+	}
+	if game.UndosLeft() != 0 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 0, game.UndosLeft())
+	}
+
+	// Resetting the game allows undos again
+	game.Reset()
+	game.ExecuteMove(grid.DirLeft)
+	game.ExecuteMove(grid.DirRight)
+	if game.UndosLeft() != 1 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 1, game.UndosLeft())
+	}
+}
+
+func TestSavedHistory(t *testing.T) {
+	game := NewGame(&Opts{SaveToDisk: false, Seed: 7, UndoHistory: 100})
+	dirs := []grid.Direction{grid.DirLeft, grid.DirUp, grid.DirRight, grid.DirDown}
+	for i := 0; len(game.History.Past) <= savedHistory+1 && game.Grid.Outcome() != grid.Lose; i++ {
+		game.ExecuteMove(dirs[i%len(dirs)])
+	}
+	game.Undo()
+	if len(game.History.Past) <= savedHistory {
+		t.Fatal("Expected more moves than are saved")
+	}
+
+	b, err := game.Serialise()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := NewGame(&Opts{SaveToDisk: false, UndoHistory: 100})
+	if err := loaded.Deserialise(b); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the most recent moves are saved
+	if len(loaded.History.Past) != savedHistory {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", savedHistory, len(loaded.History.Past))
+	}
+	if !loaded.History.fits(loaded.Grid.Width(), loaded.Grid.Height()) {
+		t.Error("Expected saved snapshots to fit the grid")
+	}
+
+	// The saved moves can still be undone and redone, spawning the same tiles
+	if !game.Undo() || !loaded.Undo() {
+		t.Fatal("Expected undo to succeed")
+	}
+	if !game.Redo() || !loaded.Redo() {
+		t.Fatal("Expected redo to succeed")
+	}
+	if !game.Redo() || !loaded.Redo() {
+		t.Fatal("Expected redo to succeed")
+	}
+	game.ExecuteMove(grid.DirLeft)
+	loaded.ExecuteMove(grid.DirLeft)
+	if loaded.Grid.Debug() != game.Grid.Debug() || loaded.Score != game.Score {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", game.Grid.Debug(), loaded.Grid.Debug())
+	}
+}
+
+func TestReplay(t *testing.T) {
+	game := NewGame(&Opts{SaveToDisk: false, Seed: 42, UndoHistory: 10, RecordReplay: true})
+	for _, dir := range []grid.Direction{grid.DirLeft, grid.DirUp, grid.DirRight, grid.DirDown, grid.DirLeft} {
+		game.ExecuteMove(dir)
+	}
+	game.Undo()
+	game.Undo()
+	game.Redo()
+	game.ExecuteMove(grid.DirUp)
+
+	// The replay should survive being saved
+	b, err := game.Replay.Serialise()
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay := &Replay{}
+	if err := replay.Deserialise(b); err != nil {
+		t.Fatal(err)
+	}
+
+	player, err := NewReplayPlayer(replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for !player.Done() {
+		if _, err := player.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := player.Step(); err != ErrReplayFinished {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", ErrReplayFinished, err)
+	}
+
+	got := player.Game()
+	if got.Grid.Debug() != game.Grid.Debug() {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", game.Grid.Debug(), got.Grid.Debug())
+	}
+	if got.Score != game.Score {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", game.Score, got.Score)
+	}
+
+	// Restarting plays back from the beginning
+	player.Restart()
+	if done, total := player.Position(); done != 0 || total != len(replay.Steps) {
+		t.Errorf("Expected:\n<%v/%v>\nGot:\n<%v/%v>", 0, len(replay.Steps), done, total)
+	}
+
+	// Restarting stops the timer of the previous game
+	before := runtime.NumGoroutine()
+	for range 10 {
+		player.Restart()
+	}
+	player.Stop()
+	for deadline := time.Now().Add(time.Second); runtime.NumGoroutine() >= before && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n >= before {
+		t.Errorf("Expected:\n<fewer than %v goroutines>\nGot:\n<%v>", before, n)
+	}
+}
+
+func TestInvalidReplay(t *testing.T) {
+	ragged := grid.NewTiles(4, 4)
+	ragged[2] = ragged[2][:3]
+	for _, replay := range []*Replay{
+		{},
+		{Tiles: grid.NewTiles(2, 2)},
+		{Tiles: ragged},
+	} {
+		if _, err := NewReplayPlayer(replay); !errors.Is(err, ErrInvalidReplay) {
+			t.Errorf("Expected:\n<%v>\nGot:\n<%v>", ErrInvalidReplay, err)
+		}
+	}
+
+	// A saved replay is checked when it's loaded
+	s := store.NewStore(filepath.Join(t.TempDir(), "replay"))
+	if err := (&Replay{Tiles: grid.NewTiles(9, 4)}).Save(s); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadReplay(s); !errors.Is(err, ErrInvalidReplay) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", ErrInvalidReplay, err)
+	}
+}
+
+func TestStats(t *testing.T) {
+	game := NewGame(&Opts{SaveToDisk: false, Width: 4, Height: 4, Seed: 7})
+	game.Grid.Tiles[0][0].Val = 1024
+	game.Grid.Tiles[0][1].Val = 1024
+	game.Timer.Set(time.Minute)
+	game.ExecuteMove(grid.DirLeft)
+	if game.Moves != 1 || game.WonAt != time.Minute {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", "1 move and winning time", game)
+	}
+
+	stats := NewStats()
+	stats.Record(game)
+	game.Reset()
+	game.Score = 5000
+	stats.Record(game)
+
+	if stats.Played != 2 || stats.Wins != 1 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", "2 played, 1 win", stats)
+	}
+	if stats.HighestTile != 2048 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 2048, stats.HighestTile)
+	}
+	if stats.BestTime != time.Minute {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", time.Minute, stats.BestTime)
+	}
+	if want := (2048 + 5000) / 2; stats.AverageScore() != want {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", want, stats.AverageScore())
+	}
+	if want := []int{0, 0, 1, 1, 0, 0, 0, 0}; !slices.Equal(stats.Histogram, want) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", want, stats.Histogram)
+	}
+}
+
+func TestResetRecordsGame(t *testing.T) {
+	game := NewGame(&Opts{SaveToDisk: false, Seed: 7})
+	game.opts.SaveToDisk = true
+	dir := t.TempDir()
+	game.statsStore = store.NewStore(filepath.Join(dir, "stats"))
+	game.leaderboardStore = store.NewStore(filepath.Join(dir, "leaderboard"))
+
+	// A game which was won and carried on with is recorded when it's reset
+	game.Moves, game.WonAt = 10, time.Minute
+	game.Reset()
+
+	// Games without any moves, or which have already been recorded, aren't
+	game.ResetWithSeed(7)
+	game.Moves, game.Recorded = 5, true
+	game.ResetKeepTimer()
+
+	stats, err := LoadStats(game.statsStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Played != 1 || stats.Wins != 1 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", "1 played, 1 win", stats)
+	}
+	board, err := LoadLeaderboard(game.leaderboardStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(board.Runs) != 1 || board.Runs[0].WonAt != time.Minute {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", "1 run won in a minute", board.Runs)
+	}
+}
+
+func TestLeaderboard(t *testing.T) {
+	board := NewLeaderboard()
+	for score := 1; score <= LeaderboardSize; score++ {
+		board.Add(Run{Score: score * 1000})
+	}
+
+	// A run with a low score stays on the leaderboard if it was fast
+	if !board.Add(Run{Score: 500, WonAt: time.Minute}) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", "fast run to be added", false)
+	}
+	if board.Add(Run{Score: 100}) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", "slow run not to be added", true)
+	}
+	board.Add(Run{Score: 20000, WonAt: 2 * time.Minute})
+
+	if len(board.Runs) != LeaderboardSize+1 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", LeaderboardSize+1, len(board.Runs))
+	}
+
+	byScore := board.ByScore()
+	if len(byScore) != LeaderboardSize || byScore[0].Score != 20000 || byScore[len(byScore)-1].Score != 2000 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", "top scores, highest first", byScore)
+	}
+
+	byTime := board.ByTime()
+	want := []time.Duration{time.Minute, 2 * time.Minute}
+	if len(byTime) != len(want) || byTime[0].WonAt != want[0] || byTime[1].WonAt != want[1] {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", want, byTime)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	game := NewGame(&Opts{SaveToDisk: false, Width: 4, Height: 4, Seed: 7})
+	game.Grid.Tiles = grid.NewTiles(4, 4)
+	game.Grid.Tiles[0][2].Val = 1024
+	game.Grid.Tiles[0][3].Val = 1024
+	game.Timer.Set(time.Minute)
+
+	var got []EventType
+	unsubscribe := game.Subscribe(func(e Event) {
+		got = append(got, e.Type)
+		if e.Type == EventMerge && e.Value != 2048 {
+			t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 2048, e.Value)
+		}
+	})
+
+	// Copies are made for the frontend whilst there are subscribers
+	if c := game.Copy(); c.subscriptions != nil {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", nil, c.subscriptions)
+	}
+
+	game.ExecuteMove(grid.DirLeft)
+	game.Reset()
+	unsubscribe()
+	game.ExecuteMove(grid.DirRight)
+
+	want := []EventType{EventMove, EventMerge, EventSpawn, EventWin, EventReset}
+	if !slices.Equal(got, want) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", want, got)
+	}
+}
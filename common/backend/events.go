@@ -0,0 +1,85 @@
+package backend
+
+import (
+	"slices"
+	"time"
+
+	"github.com/brunoga/deep"
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+)
+
+// EventType is the kind of thing which happened in a game.
+type EventType string
+
+const (
+	EventMove  EventType = "move"  // a move changed the grid
+	EventMerge EventType = "merge" // two tiles were merged by a move
+	EventSpawn EventType = "spawn" // a tile was spawned after a move
+	EventWin   EventType = "win"   // the winning tile was reached for the first time in the game
+	EventLose  EventType = "lose"  // a move left no more moves to make
+	EventReset EventType = "reset" // a new game was started
+)
+
+// Event is something which happened in a game.
+type Event struct {
+	Type  EventType      `json:"type"`
+	Dir   grid.Direction `json:"dir,omitempty"`   // the direction of a move
+	Value int            `json:"value,omitempty"` // the tile made by a merge, or the tile spawned
+	Score int            `json:"score"`           // the score after the event
+	Time  time.Duration  `json:"time"`            // the game time when the event happened
+}
+
+// subscription is a function which is called with the events of a game.
+type subscription struct {
+	id int
+	f  func(Event)
+}
+
+// Subscribe calls a function with every event in the game from now on, on the
+// goroutine which changes the game. It returns a function which unsubscribes it.
+func (g *Game) Subscribe(f func(Event)) (unsubscribe func()) {
+	g.nextSubscription++
+	id := g.nextSubscription
+	g.subscriptions = append(g.subscriptions, subscription{id: id, f: f})
+
+	return func() {
+		g.subscriptions = slices.DeleteFunc(g.subscriptions, func(s subscription) bool {
+			return s.id == id
+		})
+	}
+}
+
+// emit sends an event to every subscriber.
+func (g *Game) emit(e Event) {
+	e.Score, e.Time = g.Score, g.Timer.Duration()
+	for _, s := range g.subscriptions {
+		s.f(e)
+	}
+}
+
+// emitMove sends the events of a move which has just been made: the move itself,
+// then every merge, then the tile which was spawned.
+func (g *Game) emitMove(dir grid.Direction) {
+	g.emit(Event{Type: EventMove, Dir: dir})
+	for _, row := range g.Grid.Tiles {
+		for _, t := range row {
+			if t.Cmb {
+				g.emit(Event{Type: EventMerge, Value: t.Val})
+			}
+		}
+	}
+	if g.Grid.LastSpawn != nil {
+		g.emit(Event{Type: EventSpawn, Value: g.Grid.LastSpawn.Val})
+	}
+}
+
+// Copy returns a deep copy of the game without its subscribers, so that the
+// frontend can animate it whilst the game carries on.
+func (g *Game) Copy() Game {
+	c, err := deep.CopySkipUnsupported(*g)
+	if err != nil {
+		panic(err)
+	}
+	c.subscriptions = nil
+	return c
+}
@@ -0,0 +1,213 @@
+// Package ai chooses moves for a 2048 grid using expectimax search.
+package ai
+
+import (
+	"errors"
+	"math"
+
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+)
+
+// Heuristic weights for evaluating a board.
+const (
+	emptyWeight        = 2.7 // reward for each empty space
+	monotonicityWeight = 1.0 // penalty for rows and columns which aren't in order
+	smoothnessWeight   = 0.1 // penalty for differences between neighbouring tiles
+	cornerWeight       = 1.0 // reward for keeping the highest tile in a corner
+)
+
+// maxSpawnSamples is the maximum number of empty spaces considered for a spawn at
+// each chance node. Boards with more empty spaces are sampled, which keeps the
+// search fast when there is plenty of room and little risk.
+const maxSpawnSamples = 6
+
+// directions are the moves considered by the solver, in order of preference when
+// they're equally good.
+var directions = []grid.Direction{grid.DirUp, grid.DirLeft, grid.DirRight, grid.DirDown}
+
+// ErrNoMoves is returned when there is no move which changes the grid.
+var ErrNoMoves = errors.New("no possible moves")
+
+// Solver finds the best move for a grid.
+type Solver struct {
+	depth int
+	rng   *grid.RNG
+}
+
+// NewSolver constructs a solver which searches the given number of moves ahead.
+// Solvers made from the same seed return the same moves for the same grids.
+func NewSolver(depth int, seed uint64) *Solver {
+	return &Solver{
+		depth: max(depth, 1),
+		rng:   grid.NewRNG(seed),
+	}
+}
+
+// Depth returns the number of moves which the solver searches ahead.
+func (s *Solver) Depth() int {
+	return s.depth
+}
+
+// Best returns the best direction to move the grid in, and the expected score of
+// the resulting position. ErrNoMoves is returned if the grid can't move.
+func (s *Solver) Best(g *grid.Grid) (grid.Direction, float64, error) {
+	b := newBoard(g)
+
+	var best grid.Direction
+	bestScore := math.Inf(-1)
+	for _, dir := range directions {
+		next, _, moved := b.move(dir)
+		if !moved {
+			continue
+		}
+		if score := s.chanceNode(next, s.depth-1); score > bestScore {
+			best, bestScore = dir, score
+		}
+	}
+
+	if best == "" {
+		return "", 0, ErrNoMoves
+	}
+	return best, bestScore, nil
+}
+
+// maxNode returns the expected score of the best move from a board.
+func (s *Solver) maxNode(b board, depth int) float64 {
+	best := math.Inf(-1)
+	for _, dir := range directions {
+		next, _, moved := b.move(dir)
+		if !moved {
+			continue
+		}
+		best = math.Max(best, s.chanceNode(next, depth-1))
+	}
+
+	if math.IsInf(best, -1) {
+		// Losing is the worst possible outcome
+		return lossScore(b)
+	}
+	return best
+}
+
+// chanceNode returns the expected score of a board after a tile spawns on it.
+func (s *Solver) chanceNode(b board, depth int) float64 {
+	if depth <= 0 {
+		return evaluate(b)
+	}
+
+	empty := b.empty()
+	if len(empty) == 0 {
+		return s.maxNode(b, depth)
+	}
+	if len(empty) > maxSpawnSamples {
+		// Sample a random subset of the empty spaces
+		for i := range maxSpawnSamples {
+			j := i + s.rng.IntN(len(empty)-i)
+			empty[i], empty[j] = empty[j], empty[i]
+		}
+		empty = empty[:maxSpawnSamples]
+	}
+
+	total := 0.0
+	for _, pos := range empty {
+		for _, spawn := range []struct {
+			val  int
+			prob float64
+		}{{1, 0.9}, {2, 0.1}} {
+			b[pos[0]][pos[1]] = spawn.val
+			total += spawn.prob * s.maxNode(b, depth)
+		}
+		b[pos[0]][pos[1]] = 0
+	}
+	return total / float64(len(empty))
+}
+
+// evaluate returns the heuristic score of a board. Higher is better.
+func evaluate(b board) float64 {
+	return emptyWeight*float64(len(b.empty())) -
+		monotonicityWeight*monotonicity(b) -
+		smoothnessWeight*smoothness(b) +
+		cornerWeight*corner(b)
+}
+
+// lossScore returns the score of a board which can't move.
+func lossScore(b board) float64 {
+	return evaluate(b) - 1000
+}
+
+// monotonicity returns how far the rows and columns of a board are from being in
+// ascending or descending order.
+func monotonicity(b board) float64 {
+	penalty := 0.0
+	for _, lines := range []board{b, transpose(b)} {
+		for _, line := range lines {
+			inc, dec := 0, 0
+			for k := range len(line) - 1 {
+				if line[k] > line[k+1] {
+					dec += line[k] - line[k+1]
+				} else {
+					inc += line[k+1] - line[k]
+				}
+			}
+			penalty += float64(min(inc, dec))
+		}
+	}
+	return penalty
+}
+
+// smoothness returns the total difference between every pair of neighbouring tiles.
+func smoothness(b board) float64 {
+	penalty := 0
+	for i := range b {
+		for j := range b[i] {
+			if b[i][j] == 0 {
+				continue
+			}
+			if j+1 < len(b[i]) && b[i][j+1] != 0 {
+				penalty += abs(b[i][j] - b[i][j+1])
+			}
+			if i+1 < len(b) && b[i+1][j] != 0 {
+				penalty += abs(b[i][j] - b[i+1][j])
+			}
+		}
+	}
+	return float64(penalty)
+}
+
+// corner returns the value of the highest tile if it's in a corner, otherwise zero.
+func corner(b board) float64 {
+	highest := 0
+	for i := range b {
+		for j := range b[i] {
+			highest = max(highest, b[i][j])
+		}
+	}
+
+	right, bottom := b.width()-1, b.height()-1
+	for _, c := range []int{b[0][0], b[0][right], b[bottom][0], b[bottom][right]} {
+		if c == highest {
+			return float64(highest)
+		}
+	}
+	return 0
+}
+
+// transpose returns a transposed copy of the board.
+func transpose(b board) board {
+	t := make(board, b.width())
+	for j := range t {
+		t[j] = make([]int, b.height())
+		for i := range b {
+			t[j][i] = b[i][j]
+		}
+	}
+	return t
+}
+
+// abs returns the absolute value of x.
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,143 @@
+package ai
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+)
+
+func TestBoardMove(t *testing.T) {
+	// Moving a board should give the same tiles as moving a grid, apart from the
+	// tile which the grid spawns
+	for _, size := range []struct{ width, height int }{{4, 4}, {3, 5}, {6, 6}} {
+		g := grid.NewSeededGrid(size.width, size.height, 7)
+		for i := 0; g.Outcome() != grid.Lose; i++ {
+			dir := directions[i%len(directions)]
+			got, _, gotMoved := newBoard(g).move(dir)
+
+			_, moved := g.Move(dir)
+			if gotMoved != moved {
+				t.Fatalf("Expected:\n<%v>\nGot:\n<%v>", moved, gotMoved)
+			}
+
+			want := newBoard(g)
+			if spawn := g.LastSpawn; spawn != nil {
+				want[spawn.Y][spawn.X] = 0
+			}
+			if !reflect.DeepEqual(want, got) {
+				t.Fatalf("Expected:\n<%v>\nGot:\n<%v>", want, got)
+			}
+		}
+	}
+}
+
+func TestSlide(t *testing.T) {
+	type tc struct {
+		input  []int
+		want   []int
+		points int
+		moved  bool
+	}
+
+	for _, tc := range []tc{
+		{input: []int{0, 0, 0, 0}, want: []int{0, 0, 0, 0}, points: 0, moved: false},
+		{input: []int{1, 2, 3, 0}, want: []int{1, 2, 3, 0}, points: 0, moved: false},
+		{input: []int{0, 1, 0, 2}, want: []int{1, 2, 0, 0}, points: 0, moved: true},
+		{input: []int{1, 1, 1, 0}, want: []int{2, 1, 0, 0}, points: 4, moved: true},
+		{input: []int{1, 1, 2, 2}, want: []int{2, 3, 0, 0}, points: 12, moved: true},
+		{input: []int{2, 2, 3, 0, 0}, want: []int{3, 3, 0, 0, 0}, points: 8, moved: true},
+	} {
+		got := append([]int{}, tc.input...)
+		points, moved := slide(got)
+		if !reflect.DeepEqual(tc.want, got) || points != tc.points || moved != tc.moved {
+			t.Errorf("Expected:\n<%v %v %v>\nGot:\n<%v %v %v>",
+				tc.want, tc.points, tc.moved, got, points, moved)
+		}
+	}
+}
+
+func TestBest(t *testing.T) {
+	// Only moving left or right can combine the tiles
+	g := grid.Grid{
+		Tiles: [][]grid.Tile{
+			{{Val: 2}, {Val: 4}, {Val: 8}, {Val: 16}},
+			{{Val: 16}, {Val: 8}, {Val: 4}, {Val: 2}},
+			{{Val: 2}, {Val: 4}, {Val: 8}, {Val: 16}},
+			{{Val: 32}, {Val: 32}, {Val: 4}, {Val: 2}},
+		},
+	}
+	dir, _, err := NewSolver(2, 1).Best(&g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir != grid.DirLeft && dir != grid.DirRight {
+		t.Errorf("Expected:\n<%v or %v>\nGot:\n<%v>", grid.DirLeft, grid.DirRight, dir)
+	}
+
+	// No move is possible
+	g.Tiles[3][0].Val = 64
+	if _, _, err := NewSolver(2, 1).Best(&g); !errors.Is(err, ErrNoMoves) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", ErrNoMoves, err)
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	play := func() []grid.Direction {
+		g := grid.NewSeededGrid(4, 4, 42)
+		solver := NewSolver(2, 42)
+		var moves []grid.Direction
+		for range 100 {
+			dir, _, err := solver.Best(g)
+			if err != nil {
+				break
+			}
+			g.Move(dir)
+			moves = append(moves, dir)
+		}
+		return moves
+	}
+
+	first, second := play(), play()
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", first, second)
+	}
+}
+
+func TestSolverPlaysWell(t *testing.T) {
+	g := grid.NewSeededGrid(4, 4, 2048)
+	solver := NewSolver(2, 2048)
+	for {
+		dir, _, err := solver.Best(g)
+		if err != nil {
+			break
+		}
+		g.Move(dir)
+		if g.HighestTile() >= 512 {
+			break
+		}
+	}
+	if g.HighestTile() < 512 {
+		t.Errorf("Expected at least:\n<%v>\nGot:\n<%v>", 512, g.HighestTile())
+	}
+}
+
+func TestNewBot(t *testing.T) {
+	// Harder bots should search deeper and move faster
+	easy, medium, hard := NewBot(Easy, 1), NewBot(Medium, 1), NewBot(Hard, 1)
+	if !(easy.solver.Depth() < medium.solver.Depth() && medium.solver.Depth() < hard.solver.Depth()) {
+		t.Errorf("Expected increasing depths\nGot:\n<%v %v %v>",
+			easy.solver.Depth(), medium.solver.Depth(), hard.solver.Depth())
+	}
+	if !(easy.Delay() > medium.Delay() && medium.Delay() > hard.Delay()) {
+		t.Errorf("Expected decreasing delays\nGot:\n<%v %v %v>", easy.Delay(), medium.Delay(), hard.Delay())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic for invalid difficulty")
+		}
+	}()
+	NewBot("impossible", 1)
+}
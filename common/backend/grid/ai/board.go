@@ -0,0 +1,144 @@
+package ai
+
+import (
+	"math/bits"
+
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+)
+
+// board is a lightweight copy of a grid's tile values, which is much cheaper to
+// move than a grid.Grid because no tile UUIDs are generated. Values are stored as
+// their base 2 logarithm, so the empty tile is 0, '2' is 1, '4' is 2 and so on.
+type board [][]int
+
+// newBoard constructs a board from the tiles of a grid.
+func newBoard(g *grid.Grid) board {
+	b := make(board, len(g.Tiles))
+	for i := range g.Tiles {
+		b[i] = make([]int, len(g.Tiles[i]))
+		for j := range g.Tiles[i] {
+			if val := g.Tiles[i][j].Val; val > 0 {
+				b[i][j] = bits.Len(uint(val)) - 1
+			}
+		}
+	}
+	return b
+}
+
+// width returns the number of columns in the board.
+func (b board) width() int {
+	if len(b) == 0 {
+		return 0
+	}
+	return len(b[0])
+}
+
+// height returns the number of rows in the board.
+func (b board) height() int {
+	return len(b)
+}
+
+// clone returns a deep copy of the board.
+func (b board) clone() board {
+	c := make(board, len(b))
+	for i := range b {
+		c[i] = make([]int, len(b[i]))
+		copy(c[i], b[i])
+	}
+	return c
+}
+
+// empty returns the positions of every empty space, ordered by row then column.
+func (b board) empty() [][2]int {
+	var out [][2]int
+	for i := range b {
+		for j := range b[i] {
+			if b[i][j] == 0 {
+				out = append(out, [2]int{i, j})
+			}
+		}
+	}
+	return out
+}
+
+// move returns the board after moving in the given direction, following the
+// same rules as grid.Grid. Also returns the points gained, and whether any tiles
+// moved.
+func (b board) move(dir grid.Direction) (board, int, bool) {
+	out := b.clone()
+	points := 0
+	moved := false
+
+	// Every row or column is read into a line starting from the edge that the
+	// tiles are moving towards, then written back in the same order
+	vertical := dir == grid.DirUp || dir == grid.DirDown
+	reverse := dir == grid.DirRight || dir == grid.DirDown
+	lines, length := b.height(), b.width()
+	if vertical {
+		lines, length = length, lines
+	}
+
+	line := make([]int, length)
+	for l := range lines {
+		for k := range length {
+			i, j := l, k
+			if reverse {
+				j = length - 1 - k
+			}
+			if vertical {
+				i, j = j, i
+			}
+			line[k] = b[i][j]
+		}
+
+		p, lineMoved := slide(line)
+		points += p
+		moved = moved || lineMoved
+
+		for k := range length {
+			i, j := l, k
+			if reverse {
+				j = length - 1 - k
+			}
+			if vertical {
+				i, j = j, i
+			}
+			out[i][j] = line[k]
+		}
+	}
+
+	return out, points, moved
+}
+
+// slide moves the tiles of a line towards index 0 in place, combining each tile
+// at most once. Returns the points gained, and whether any tiles moved.
+func slide(line []int) (int, bool) {
+	points := 0
+	moved := false
+
+	dest := 0
+	canCombine := false // whether the tile before dest may still be combined
+	for i, v := range line {
+		if v == 0 {
+			continue
+		}
+		line[i] = 0
+
+		if canCombine && line[dest-1] == v {
+			line[dest-1]++
+			points += 1 << line[dest-1]
+			canCombine = false
+			moved = true
+			continue
+		}
+
+		line[dest] = v
+		if dest != i {
+			moved = true
+		}
+		dest++
+		canCombine = true
+	}
+
+	return points, moved
+}
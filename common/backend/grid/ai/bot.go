@@ -0,0 +1,69 @@
+package ai
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+)
+
+// Difficulty sets how well a bot plays.
+type Difficulty string
+
+const (
+	Easy   Difficulty = "easy"
+	Medium Difficulty = "medium"
+	Hard   Difficulty = "hard"
+)
+
+// level contains the settings for a difficulty.
+type level struct {
+	depth int           // number of moves searched ahead
+	delay time.Duration // time between moves
+}
+
+// levels contains the settings for every difficulty.
+var levels = map[Difficulty]level{
+	Easy:   {depth: 1, delay: 900 * time.Millisecond},
+	Medium: {depth: 2, delay: 550 * time.Millisecond},
+	Hard:   {depth: 3, delay: 300 * time.Millisecond},
+}
+
+// Bot is a computer opponent which plays at a steady pace.
+type Bot struct {
+	difficulty Difficulty
+	solver     *Solver
+	delay      time.Duration
+}
+
+// NewBot constructs a bot of the given difficulty. Bots made from the same seed
+// play the same moves for the same grids. It panics if the difficulty is invalid.
+func NewBot(difficulty Difficulty, seed uint64) *Bot {
+	l, ok := levels[difficulty]
+	if !ok {
+		panic(fmt.Sprintf("invalid difficulty \"%s\"", difficulty))
+	}
+
+	return &Bot{
+		difficulty: difficulty,
+		solver:     NewSolver(l.depth, seed),
+		delay:      l.delay,
+	}
+}
+
+// Difficulty returns the difficulty of the bot.
+func (b *Bot) Difficulty() Difficulty {
+	return b.difficulty
+}
+
+// Delay returns the time the bot waits between moves.
+func (b *Bot) Delay() time.Duration {
+	return b.delay
+}
+
+// Move returns the direction that the bot chooses to move the grid in.
+// ErrNoMoves is returned if the grid can't move.
+func (b *Bot) Move(g *grid.Grid) (grid.Direction, error) {
+	dir, _, err := b.solver.Best(g)
+	return dir, err
+}
@@ -1,39 +1,80 @@
 package grid
 
 import (
-	"math/rand"
+	"fmt"
 	"reflect"
+	"slices"
 	"strconv"
 	"sync"
 
 	"github.com/google/uuid"
 )
 
+// Grid dimension limits.
 const (
-	GridSize   = 4
-	gridWidth  = GridSize
-	gridHeight = GridSize
+	DefaultSize = 4 // the width and height of a standard grid
+	MinSize     = 3 // the smallest supported width or height
+	MaxSize     = 8 // the largest supported width or height
 )
 
+// Obstacle is the value of a tile which can't move or be merged. Obstacles are
+// broken by any merge next to them.
+const Obstacle = -1
+
 // Grid contains the tiles for the game. Position {0,0} is the top left square.
+// Tiles are indexed by row, then column.
 type Grid struct {
 	mu    sync.Mutex
-	Tiles [gridWidth][gridHeight]Tile `json:"tiles"`
+	Tiles [][]Tile `json:"tiles"`
+	Seed  uint64   `json:"seed"` // the seed that the current game started from
+	RNG   *RNG     `json:"rng"`  // generates the positions and values of new tiles
+
+	LastMove  Direction
+	LastSpawn *Spawn `json:"lastSpawn,omitempty"` // the tile spawned by the last move, if any
+}
 
-	LastMove Direction
+// NewGrid constructs a new grid with the given number of columns and rows, and a
+// random seed. It panics if either dimension is outside of MinSize and MaxSize.
+func NewGrid(width, height int) *Grid {
+	return NewSeededGrid(width, height, NewSeed())
 }
 
-// NewGrid constructs a new grid.
-func NewGrid() *Grid {
+// NewSeededGrid constructs a new grid with the given number of columns and rows.
+// Grids made from the same seed spawn the same tiles for the same moves. It panics
+// if either dimension is outside of MinSize and MaxSize.
+func NewSeededGrid(width, height int, seed uint64) *Grid {
+	if !ValidSize(width, height) {
+		panic(fmt.Sprintf("invalid grid size %dx%d", width, height))
+	}
+
 	g := Grid{
 		mu:    sync.Mutex{},
-		Tiles: NewTiles(),
+		Tiles: NewTiles(width, height),
 	}
-	g.Reset()
+	g.ResetWithSeed(seed)
 
 	return &g
 }
 
+// ValidSize returns whether a grid can be made with the given dimensions.
+func ValidSize(width, height int) bool {
+	return width >= MinSize && width <= MaxSize &&
+		height >= MinSize && height <= MaxSize
+}
+
+// Width returns the number of columns in the grid.
+func (g *Grid) Width() int {
+	if len(g.Tiles) == 0 {
+		return 0
+	}
+	return len(g.Tiles[0])
+}
+
+// Height returns the number of rows in the grid.
+func (g *Grid) Height() int {
+	return len(g.Tiles)
+}
+
 // Direction represents a direction that the player can move the tiles in.
 type Direction string
 
@@ -45,30 +86,45 @@ const (
 )
 
 // Move attempts to move in the specified direction, spawning a new tile if appropriate.
-func (g *Grid) Move(dir Direction) int {
+// Returns the points gained from the move, and whether any tiles moved.
+func (g *Grid) Move(dir Direction) (int, bool) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
+	g.LastSpawn = nil
 	didMove, pointsGained := g.move(dir)
 	if didMove {
+		g.breakObstacles()
 		g.spawnTile()
 	}
 	g.LastMove = dir
-	return pointsGained
+	return pointsGained, didMove
 }
 
-// Reset resets the grid to a start-of-game state, spawning two '2' tiles in random locations.
+// Reset resets the grid to a start-of-game state with a new random seed, spawning
+// two '2' tiles in random locations.
 func (g *Grid) Reset() {
-	g.Tiles = NewTiles()
+	g.ResetWithSeed(NewSeed())
+}
+
+// ResetWithSeed resets the grid to a start-of-game state using the given seed,
+// spawning two '2' tiles in random locations.
+func (g *Grid) ResetWithSeed(seed uint64) {
+	g.Seed = seed
+	g.RNG = NewRNG(seed)
+	g.LastSpawn = nil
+
+	width, height := g.Width(), g.Height()
+	g.Tiles = NewTiles(width, height)
 	// Place two '2' tiles in random positions
 	type pos struct{ x, y int }
-	tile1 := pos{rand.Intn(gridWidth), rand.Intn(gridHeight)}
-	tile2 := pos{rand.Intn(gridWidth), rand.Intn(gridHeight)}
+	tile1 := pos{g.RNG.IntN(width), g.RNG.IntN(height)}
+	tile2 := pos{g.RNG.IntN(width), g.RNG.IntN(height)}
 	for reflect.DeepEqual(tile1, tile2) {
 		// Try again until they're unique
-		tile2 = pos{rand.Intn(gridWidth), rand.Intn(gridHeight)}
+		tile2 = pos{g.RNG.IntN(width), g.RNG.IntN(height)}
 	}
-	g.Tiles[tile1.x][tile1.y].Val = newTileVal()
-	g.Tiles[tile2.x][tile2.y].Val = newTileVal()
+	g.Tiles[tile1.y][tile1.x].Val = g.newTileVal()
+	g.Tiles[tile2.y][tile2.x].Val = g.newTileVal()
 }
 
 // NumTiles returns the number of non zero tiles on the grid.
@@ -114,51 +170,124 @@ func (g *Grid) Outcome() Outcome {
 	}
 }
 
+// AddObstacles places up to n obstacles in random empty spaces, leaving at least
+// one space empty. Returns where each obstacle was placed.
+func (g *Grid) AddObstacles(n int) []Spawn {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	type pos struct{ x, y int }
+	var empty []pos
+	for y := range g.Tiles {
+		for x := range g.Tiles[y] {
+			if g.Tiles[y][x].Val == emptyTile {
+				empty = append(empty, pos{x, y})
+			}
+		}
+	}
+
+	var placed []Spawn
+	for range min(n, len(empty)-1) {
+		i := g.rng().IntN(len(empty))
+		p := empty[i]
+		empty = slices.Delete(empty, i, i+1)
+
+		g.Tiles[p.y][p.x] = Tile{Val: Obstacle, UUID: uuid.Must(uuid.NewV7())}
+		placed = append(placed, Spawn{X: p.x, Y: p.y, Val: Obstacle})
+	}
+	return placed
+}
+
+// NumObstacles returns the number of obstacles on the grid.
+func (g *Grid) NumObstacles() int {
+	n := 0
+	for i := range g.Tiles {
+		for j := range g.Tiles[i] {
+			if g.Tiles[i][j].Val == Obstacle {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// breakObstacles removes every obstacle next to a tile which was combined in the
+// last move.
+func (g *Grid) breakObstacles() {
+	for y := range g.Tiles {
+		for x := range g.Tiles[y] {
+			if !g.Tiles[y][x].Cmb {
+				continue
+			}
+			for _, n := range [][2]int{{x - 1, y}, {x + 1, y}, {x, y - 1}, {x, y + 1}} {
+				nx, ny := n[0], n[1]
+				if ny >= 0 && ny < len(g.Tiles) && nx >= 0 && nx < len(g.Tiles[ny]) &&
+					g.Tiles[ny][nx].Val == Obstacle {
+					g.Tiles[ny][nx] = Tile{UUID: uuid.Must(uuid.NewV7())}
+				}
+			}
+		}
+	}
+}
+
 // spawnTile spawns a single new tile in a random location on the grid. The value of the
 // tile is either 2 (90% chance) or 4 (10% chance).
 func (g *Grid) spawnTile() {
-	x, y := rand.Intn(gridWidth), rand.Intn(gridHeight)
-	for g.Tiles[x][y].Val != emptyTile {
+	width, height := g.Width(), g.Height()
+	x, y := g.rng().IntN(width), g.rng().IntN(height)
+	for g.Tiles[y][x].Val != emptyTile {
 		// Try again until they're unique
-		x, y = rand.Intn(gridWidth), rand.Intn(gridHeight)
+		x, y = g.rng().IntN(width), g.rng().IntN(height)
 	}
 
-	g.Tiles[x][y].Val = newTileVal()
-	g.Tiles[x][y].UUID = uuid.Must(uuid.NewV7())
+	g.Tiles[y][x].Val = g.newTileVal()
+	g.Tiles[y][x].UUID = uuid.Must(uuid.NewV7())
+	g.LastSpawn = &Spawn{X: x, Y: y, Val: g.Tiles[y][x].Val}
+}
+
+// Spawn describes a tile which was spawned on the grid.
+type Spawn struct {
+	X   int `json:"x"`   // column index
+	Y   int `json:"y"`   // row index
+	Val int `json:"val"` // value of the new tile
+}
+
+// EqualSpawn returns whether spawns s1 and s2 are the same. A nil spawn is only
+// equal to another nil spawn.
+func EqualSpawn(s1, s2 *Spawn) bool {
+	if s1 == nil || s2 == nil {
+		return s1 == s2
+	}
+	return *s1 == *s2
 }
 
 // move attempts to move all tiles in the specified direction, combining them if appropriate.
 // Returns true if any tiles were moved from the attempt, and the added score from any combinations.
 func (g *Grid) move(dir Direction) (bool, int) {
 	// Clear all of the "combined this turn" flags
-	for i := range gridWidth {
-		for j := range gridHeight {
-			g.Tiles[i][j].Cmb = false
-		}
-	}
+	g.ClearCmbFlags()
 
 	moved := false
 	pointsGained := 0
 
+	// The moveStep function only operates on a row, so to move vertically
+	// we must transpose the grid before and after the move operation.
+	tiles := g.Tiles
+	if dir == DirUp || dir == DirDown {
+		tiles = transpose(tiles)
+	}
+
 	// Execute moves until grid can no longer move
 	for {
 		movedThisTurn := false
-		for row := range gridHeight {
+		for row := range tiles {
 			var rowMoved bool
 			var points int
 
-			// The moveStep function only operates on a row, so to move vertically
-			// we must transpose the grid before and after the move operation.
-			if dir == DirUp || dir == DirDown {
-				g.Tiles = transpose(g.Tiles)
-			}
-			g.Tiles[row], rowMoved, points = moveStep(g.Tiles[row], dir)
+			tiles[row], rowMoved, points = moveStep(tiles[row], dir)
 			if points > 0 {
 				pointsGained = points
 			}
-			if dir == DirUp || dir == DirDown {
-				g.Tiles = transpose(g.Tiles)
-			}
 
 			if rowMoved {
 				movedThisTurn = true
@@ -170,13 +299,20 @@ func (g *Grid) move(dir Direction) (bool, int) {
 		}
 	}
 
+	if dir == DirUp || dir == DirDown {
+		tiles = transpose(tiles)
+	}
+	g.Tiles = tiles
+
 	return moved, pointsGained
 }
 
 // moveStep executes one part of the a move on a grid row. Call multiple times until false
 // is returned to complete a full move. Returns the row after move, whether any tiles moved,
 // and the number of points gained by the move.
-func moveStep(g [gridWidth]Tile, dir Direction) ([gridWidth]Tile, bool, int) {
+func moveStep(row []Tile, dir Direction) ([]Tile, bool, int) {
+	g := slices.Clone(row)
+
 	// Iterate in the same direction as the move
 	reverse := false
 	if dir == DirRight || dir == DirDown {
@@ -197,8 +333,8 @@ func moveStep(g [gridWidth]Tile, dir Direction) ([gridWidth]Tile, bool, int) {
 			continue
 		}
 
-		// Skip if source tile is empty
-		if g[i].Val == emptyTile {
+		// Skip if source tile is empty, or can't move
+		if g[i].Val == emptyTile || g[i].Val == Obstacle {
 			continue
 		}
 
@@ -231,8 +367,8 @@ func moveStep(g [gridWidth]Tile, dir Direction) ([gridWidth]Tile, bool, int) {
 // isLoss returns true if the grid is in a losing state (gridlocked).
 func (g *Grid) isLoss() bool {
 	// False if any empty spaces exist
-	for i := range gridHeight {
-		for j := range gridWidth {
+	for i := range g.Tiles {
+		for j := range g.Tiles[i] {
 			if g.Tiles[i][j].Val == emptyTile {
 				return false
 			}
@@ -240,18 +376,12 @@ func (g *Grid) isLoss() bool {
 	}
 
 	// False if any similar tiles exist next to each other
-	for i := range gridHeight {
-		for j := range gridWidth - 1 {
-			if g.Tiles[i][j].Val == g.Tiles[i][j+1].Val {
-				return false
-			}
-		}
-	}
-	t := transpose(g.Tiles)
-	for i := range gridHeight {
-		for j := range gridWidth - 1 {
-			if t[i][j].Val == t[i][j+1].Val {
-				return false
+	for _, tiles := range [][][]Tile{g.Tiles, transpose(g.Tiles)} {
+		for i := range tiles {
+			for j := range len(tiles[i]) - 1 {
+				if tiles[i][j].Val == tiles[i][j+1].Val && tiles[i][j].Val != Obstacle {
+					return false
+				}
 			}
 		}
 	}
@@ -262,8 +392,8 @@ func (g *Grid) isLoss() bool {
 // HighestTile returns the value of the highest tile on the grid.
 func (g *Grid) HighestTile() int {
 	highest := 0
-	for a := range gridHeight {
-		for b := range gridWidth {
+	for a := range g.Tiles {
+		for b := range g.Tiles[a] {
 			if g.Tiles[a][b].Val > highest {
 				highest = g.Tiles[a][b].Val
 			}
@@ -275,8 +405,8 @@ func (g *Grid) HighestTile() int {
 // Debug arranges the grid into a human readable Debug for debugging purposes.
 func (g *Grid) Debug() string {
 	var out string
-	for row := range gridHeight {
-		for col := range gridWidth {
+	for row := range g.Tiles {
+		for col := range g.Tiles[row] {
 			out += g.Tiles[row][col].paddedString() + "|"
 		}
 		out += "\n"
@@ -284,22 +414,56 @@ func (g *Grid) Debug() string {
 	return out
 }
 
-// clone returns a deep copy for debugging purposes.
-func (g *Grid) clone() *Grid {
-	newGrid := &Grid{}
-	for a := range gridHeight {
-		for b := range gridWidth {
-			newGrid.Tiles[a][b] = g.Tiles[a][b]
-		}
+// Clone returns a deep copy of the grid.
+func (g *Grid) Clone() *Grid {
+	newGrid := &Grid{
+		Tiles:     make([][]Tile, len(g.Tiles)),
+		Seed:      g.Seed,
+		LastMove:  g.LastMove,
+		LastSpawn: g.LastSpawn,
+	}
+	for a := range g.Tiles {
+		newGrid.Tiles[a] = slices.Clone(g.Tiles[a])
+	}
+	if g.RNG != nil {
+		newGrid.RNG = g.RNG.Clone()
 	}
 	return newGrid
 }
 
+// Values returns the value of every tile, indexed by row, then column.
+func (g *Grid) Values() [][]int {
+	vals := make([][]int, len(g.Tiles))
+	for i := range g.Tiles {
+		vals[i] = make([]int, len(g.Tiles[i]))
+		for j := range g.Tiles[i] {
+			vals[i][j] = g.Tiles[i][j].Val
+		}
+	}
+	return vals
+}
+
+// SetValues replaces the tiles with new ones of the given values, indexed by row,
+// then column. The new tiles can't be animated from the old ones.
+func (g *Grid) SetValues(vals [][]int) {
+	g.Tiles = make([][]Tile, len(vals))
+	for i := range vals {
+		g.Tiles[i] = make([]Tile, len(vals[i]))
+		for j := range vals[i] {
+			g.Tiles[i][j] = Tile{Val: vals[i][j], UUID: uuid.Must(uuid.NewV7())}
+		}
+	}
+}
+
 // transpose returns a transposed version of the grid.
-func transpose(matrix [gridWidth][gridHeight]Tile) [gridHeight][gridWidth]Tile {
-	var transposed [gridHeight][gridWidth]Tile
-	for i := range gridWidth {
-		for j := range gridHeight {
+func transpose(matrix [][]Tile) [][]Tile {
+	if len(matrix) == 0 {
+		return [][]Tile{}
+	}
+	transposed := make([][]Tile, len(matrix[0]))
+	for j := range transposed {
+		transposed[j] = make([]Tile, len(matrix))
+		for i := range matrix {
 			transposed[j][i] = matrix[i][j]
 		}
 	}
@@ -315,10 +479,11 @@ type Tile struct {
 	UUID uuid.UUID `json:"uuid"` // unique ID for each tile
 }
 
-// NewTiles generates a fresh set of tiles.
-func NewTiles() [gridHeight][gridWidth]Tile {
-	t := [gridHeight][gridWidth]Tile{}
+// NewTiles generates a fresh set of tiles with the given number of columns and rows.
+func NewTiles(width, height int) [][]Tile {
+	t := make([][]Tile, height)
 	for i := range t {
+		t[i] = make([]Tile, width)
 		for j := range t[i] {
 			t[i][j].UUID = uuid.Must(uuid.NewV7())
 		}
@@ -354,10 +519,17 @@ func (t *Tile) Equal(t2 Tile) bool {
 		t.UUID == t2.UUID
 }
 
-// EqualGrid returns whether grid g1 is equal to g2.
-func EqualGrid(g1, g2 [gridWidth][gridHeight]Tile) bool {
-	for i := range gridWidth {
-		for j := range gridHeight {
+// EqualGrid returns whether grid g1 is equal to g2. Grids of different sizes
+// are never equal.
+func EqualGrid(g1, g2 [][]Tile) bool {
+	if len(g1) != len(g2) {
+		return false
+	}
+	for i := range g1 {
+		if len(g1[i]) != len(g2[i]) {
+			return false
+		}
+		for j := range g1[i] {
 			if !g1[i][j].Equal(g2[i][j]) {
 				return false
 			}
@@ -367,9 +539,18 @@ func EqualGrid(g1, g2 [gridWidth][gridHeight]Tile) bool {
 }
 
 // newTileVal generates the value of a new tile.
-func newTileVal() int {
-	if rand.Float64() >= 0.9 {
+func (g *Grid) newTileVal() int {
+	if g.rng().Float64() >= 0.9 {
 		return 4
 	}
 	return 2
 }
+
+// rng returns the grid's random number generator. Grids without one, such as those
+// loaded from an old save file, carry on from their seed.
+func (g *Grid) rng() *RNG {
+	if g.RNG == nil {
+		g.RNG = NewRNG(g.Seed)
+	}
+	return g.RNG
+}
@@ -1,13 +1,14 @@
 package grid
 
 import (
+	"encoding/json"
 	"reflect"
 	"testing"
 )
 
 func TestMove(t *testing.T) {
 	input := Grid{
-		Tiles: [4][4]Tile{
+		Tiles: [][]Tile{
 			{{Val: 0}, {Val: 2}, {Val: 2}, {Val: 2}},
 			{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
 			{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
@@ -16,7 +17,7 @@ func TestMove(t *testing.T) {
 	}
 	dir := DirRight
 	expected := Grid{
-		Tiles: [4][4]Tile{
+		Tiles: [][]Tile{
 			{{Val: 0}, {Val: 0}, {Val: 2}, {Val: 4, Cmb: true}},
 			{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
 			{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
@@ -24,116 +25,178 @@ func TestMove(t *testing.T) {
 		},
 	}
 
-	got := input.clone()
+	got := input.Clone()
 	got.move(dir)
 	if !gridsAreEqual(expected.Tiles, got.Tiles) {
 		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", expected.Debug(), got.Debug())
 	}
 }
 
+func TestMoveNonSquare(t *testing.T) {
+	input := Grid{
+		Tiles: [][]Tile{
+			{{Val: 2}, {Val: 0}, {Val: 0}},
+			{{Val: 2}, {Val: 4}, {Val: 0}},
+			{{Val: 0}, {Val: 0}, {Val: 0}},
+			{{Val: 0}, {Val: 4}, {Val: 8}},
+			{{Val: 0}, {Val: 0}, {Val: 0}},
+		},
+	}
+	dir := DirDown
+	expected := Grid{
+		Tiles: [][]Tile{
+			{{Val: 0}, {Val: 0}, {Val: 0}},
+			{{Val: 0}, {Val: 0}, {Val: 0}},
+			{{Val: 0}, {Val: 0}, {Val: 0}},
+			{{Val: 0}, {Val: 0}, {Val: 0}},
+			{{Val: 4, Cmb: true}, {Val: 8, Cmb: true}, {Val: 8}},
+		},
+	}
+
+	got := input.Clone()
+	got.move(dir)
+	if !gridsAreEqual(expected.Tiles, got.Tiles) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", expected.Debug(), got.Debug())
+	}
+}
+
+func TestNewGrid(t *testing.T) {
+	for _, size := range []struct{ width, height int }{
+		{3, 3}, {4, 4}, {8, 8}, {5, 3}, {3, 7},
+	} {
+		g := NewGrid(size.width, size.height)
+		if g.Width() != size.width || g.Height() != size.height {
+			t.Errorf("Expected %dx%d, got %dx%d", size.width, size.height, g.Width(), g.Height())
+		}
+		if g.NumTiles() != 2 {
+			t.Errorf("Expected 2 starting tiles, got %d", g.NumTiles())
+		}
+	}
+}
+
 func TestMoveStep(t *testing.T) {
 	type tc struct {
-		input    [4]Tile
+		input    []Tile
 		dir      Direction
-		expected [4]Tile
+		expected []Tile
 		moved    bool
 	}
 
 	for n, tc := range []tc{
 		// 2 2 2 2 --[left]--> 4 4 0 0
 		{
-			input:    [4]Tile{{Val: 2}, {Val: 2}, {Val: 2}, {Val: 2}},
+			input:    []Tile{{Val: 2}, {Val: 2}, {Val: 2}, {Val: 2}},
 			dir:      DirLeft,
-			expected: [4]Tile{{Val: 4, Cmb: true}, {Val: 0}, {Val: 2}, {Val: 2}},
+			expected: []Tile{{Val: 4, Cmb: true}, {Val: 0}, {Val: 2}, {Val: 2}},
 			moved:    true,
 		},
 		{
-			input:    [4]Tile{{Val: 4, Cmb: true}, {Val: 0}, {Val: 2}, {Val: 2}},
+			input:    []Tile{{Val: 4, Cmb: true}, {Val: 0}, {Val: 2}, {Val: 2}},
 			dir:      DirLeft,
-			expected: [4]Tile{{Val: 4, Cmb: true}, {Val: 2}, {Val: 0}, {Val: 2}},
+			expected: []Tile{{Val: 4, Cmb: true}, {Val: 2}, {Val: 0}, {Val: 2}},
 			moved:    true,
 		},
 		{
-			input:    [4]Tile{{Val: 4, Cmb: true}, {Val: 2}, {Val: 0}, {Val: 2}},
+			input:    []Tile{{Val: 4, Cmb: true}, {Val: 2}, {Val: 0}, {Val: 2}},
 			dir:      DirLeft,
-			expected: [4]Tile{{Val: 4, Cmb: true}, {Val: 2}, {Val: 2}, {Val: 0}},
+			expected: []Tile{{Val: 4, Cmb: true}, {Val: 2}, {Val: 2}, {Val: 0}},
 			moved:    true,
 		},
 		{
-			input:    [4]Tile{{Val: 4, Cmb: true}, {Val: 2}, {Val: 2}, {Val: 0}},
+			input:    []Tile{{Val: 4, Cmb: true}, {Val: 2}, {Val: 2}, {Val: 0}},
 			dir:      DirLeft,
-			expected: [4]Tile{{Val: 4, Cmb: true}, {Val: 4, Cmb: true}, {Val: 0}, {Val: 0}},
+			expected: []Tile{{Val: 4, Cmb: true}, {Val: 4, Cmb: true}, {Val: 0}, {Val: 0}},
 			moved:    true,
 		},
 		// 0 4 2 2 --[left]--> 4 4 0 0
 		{
-			input:    [4]Tile{{Val: 0}, {Val: 4}, {Val: 2}, {Val: 2}},
+			input:    []Tile{{Val: 0}, {Val: 4}, {Val: 2}, {Val: 2}},
 			dir:      DirLeft,
-			expected: [4]Tile{{Val: 4}, {Val: 0}, {Val: 2}, {Val: 2}},
+			expected: []Tile{{Val: 4}, {Val: 0}, {Val: 2}, {Val: 2}},
 			moved:    true,
 		},
 		{
-			input:    [4]Tile{{Val: 4}, {Val: 0}, {Val: 2}, {Val: 2}},
+			input:    []Tile{{Val: 4}, {Val: 0}, {Val: 2}, {Val: 2}},
 			dir:      DirLeft,
-			expected: [4]Tile{{Val: 4}, {Val: 2}, {Val: 0}, {Val: 2}},
+			expected: []Tile{{Val: 4}, {Val: 2}, {Val: 0}, {Val: 2}},
 			moved:    true,
 		},
 		{
-			input:    [4]Tile{{Val: 4}, {Val: 2}, {Val: 0}, {Val: 2}},
+			input:    []Tile{{Val: 4}, {Val: 2}, {Val: 0}, {Val: 2}},
 			dir:      DirLeft,
-			expected: [4]Tile{{Val: 4}, {Val: 2}, {Val: 2}, {Val: 0}},
+			expected: []Tile{{Val: 4}, {Val: 2}, {Val: 2}, {Val: 0}},
 			moved:    true,
 		},
 		{
-			input:    [4]Tile{{Val: 4}, {Val: 2}, {Val: 2}, {Val: 0}},
+			input:    []Tile{{Val: 4}, {Val: 2}, {Val: 2}, {Val: 0}},
 			dir:      DirLeft,
-			expected: [4]Tile{{Val: 4}, {Val: 4, Cmb: true}, {Val: 0}, {Val: 0}},
+			expected: []Tile{{Val: 4}, {Val: 4, Cmb: true}, {Val: 0}, {Val: 0}},
 			moved:    true,
 		},
 		{
-			input:    [4]Tile{{Val: 4}, {Val: 4, Cmb: true}, {Val: 0}, {Val: 0}},
+			input:    []Tile{{Val: 4}, {Val: 4, Cmb: true}, {Val: 0}, {Val: 0}},
 			dir:      DirLeft,
-			expected: [4]Tile{{Val: 4}, {Val: 4, Cmb: true}, {Val: 0}, {Val: 0}},
+			expected: []Tile{{Val: 4}, {Val: 4, Cmb: true}, {Val: 0}, {Val: 0}},
 			moved:    false,
 		},
 		// // 2 2 2 2 --[right]--> 4 4 0 0
 		{
-			input:    [4]Tile{{Val: 2}, {Val: 2}, {Val: 2}, {Val: 2}},
+			input:    []Tile{{Val: 2}, {Val: 2}, {Val: 2}, {Val: 2}},
 			dir:      DirRight,
-			expected: [4]Tile{{Val: 2}, {Val: 2}, {Val: 0}, {Val: 4, Cmb: true}},
+			expected: []Tile{{Val: 2}, {Val: 2}, {Val: 0}, {Val: 4, Cmb: true}},
 			moved:    true,
 		},
 		{
-			input:    [4]Tile{{Val: 2}, {Val: 2}, {Val: 0}, {Val: 4, Cmb: true}},
+			input:    []Tile{{Val: 2}, {Val: 2}, {Val: 0}, {Val: 4, Cmb: true}},
 			dir:      DirRight,
-			expected: [4]Tile{{Val: 2}, {Val: 0}, {Val: 2}, {Val: 4, Cmb: true}},
+			expected: []Tile{{Val: 2}, {Val: 0}, {Val: 2}, {Val: 4, Cmb: true}},
 			moved:    true,
 		},
 		{
-			input:    [4]Tile{{Val: 2}, {Val: 0}, {Val: 2}, {Val: 4, Cmb: true}},
+			input:    []Tile{{Val: 2}, {Val: 0}, {Val: 2}, {Val: 4, Cmb: true}},
 			dir:      DirRight,
-			expected: [4]Tile{{Val: 0}, {Val: 2}, {Val: 2}, {Val: 4, Cmb: true}},
+			expected: []Tile{{Val: 0}, {Val: 2}, {Val: 2}, {Val: 4, Cmb: true}},
 			moved:    true,
 		},
 		{
-			input:    [4]Tile{{Val: 0}, {Val: 2}, {Val: 2}, {Val: 4, Cmb: true}},
+			input:    []Tile{{Val: 0}, {Val: 2}, {Val: 2}, {Val: 4, Cmb: true}},
 			dir:      DirRight,
-			expected: [4]Tile{{Val: 0}, {Val: 0}, {Val: 4, Cmb: true}, {Val: 4, Cmb: true}},
+			expected: []Tile{{Val: 0}, {Val: 0}, {Val: 4, Cmb: true}, {Val: 4, Cmb: true}},
 			moved:    true,
 		},
 		// // 0 2 2 2 --[right]--> 0 0 2 4
 		{
-			input:    [4]Tile{{Val: 0}, {Val: 2}, {Val: 2}, {Val: 2}},
+			input:    []Tile{{Val: 0}, {Val: 2}, {Val: 2}, {Val: 2}},
 			dir:      DirRight,
-			expected: [4]Tile{{Val: 0}, {Val: 2}, {Val: 0}, {Val: 4, Cmb: true}},
+			expected: []Tile{{Val: 0}, {Val: 2}, {Val: 0}, {Val: 4, Cmb: true}},
 			moved:    true,
 		},
 		{
-			input:    [4]Tile{{Val: 0}, {Val: 2}, {Val: 0}, {Val: 4, Cmb: true}},
+			input:    []Tile{{Val: 0}, {Val: 2}, {Val: 0}, {Val: 4, Cmb: true}},
 			dir:      DirRight,
-			expected: [4]Tile{{Val: 0}, {Val: 0}, {Val: 2}, {Val: 4, Cmb: true}},
+			expected: []Tile{{Val: 0}, {Val: 0}, {Val: 2}, {Val: 4, Cmb: true}},
 			moved:    true,
 		},
+		// 0 X 0 2 --[left]--> 0 X 2 0
+		{
+			input:    []Tile{{Val: 0}, {Val: Obstacle}, {Val: 0}, {Val: 2}},
+			dir:      DirLeft,
+			expected: []Tile{{Val: 0}, {Val: Obstacle}, {Val: 2}, {Val: 0}},
+			moved:    true,
+		},
+		{
+			input:    []Tile{{Val: 0}, {Val: Obstacle}, {Val: 2}, {Val: 0}},
+			dir:      DirLeft,
+			expected: []Tile{{Val: 0}, {Val: Obstacle}, {Val: 2}, {Val: 0}},
+			moved:    false,
+		},
+		// X X 0 0 --[right]--> X X 0 0
+		{
+			input:    []Tile{{Val: Obstacle}, {Val: Obstacle}, {Val: 0}, {Val: 0}},
+			dir:      DirRight,
+			expected: []Tile{{Val: Obstacle}, {Val: Obstacle}, {Val: 0}, {Val: 0}},
+			moved:    false,
+		},
 	} {
 		got, moved, _ := moveStep(tc.input, tc.dir)
 		if !rowsAreEqual(tc.expected, got) {
@@ -146,13 +209,13 @@ func TestMoveStep(t *testing.T) {
 }
 
 func TestTranspose(t *testing.T) {
-	input := [4][4]Tile{
+	input := [][]Tile{
 		{{Val: 1}, {Val: 2}, {Val: 3}, {Val: 4}},
 		{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
 		{{Val: 6}, {Val: 0}, {Val: 0}, {Val: 0}},
 		{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 5}},
 	}
-	expected := [4][4]Tile{
+	expected := [][]Tile{
 		{{Val: 1}, {Val: 0}, {Val: 6}, {Val: 0}},
 		{{Val: 2}, {Val: 0}, {Val: 0}, {Val: 0}},
 		{{Val: 3}, {Val: 0}, {Val: 0}, {Val: 0}},
@@ -164,6 +227,22 @@ func TestTranspose(t *testing.T) {
 	}
 }
 
+func TestTransposeNonSquare(t *testing.T) {
+	input := [][]Tile{
+		{{Val: 1}, {Val: 2}, {Val: 3}},
+		{{Val: 4}, {Val: 5}, {Val: 6}},
+	}
+	expected := [][]Tile{
+		{{Val: 1}, {Val: 4}},
+		{{Val: 2}, {Val: 5}},
+		{{Val: 3}, {Val: 6}},
+	}
+	got := transpose(input)
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("\nExpected:\n<%v>\nGot:\n<%v>", expected, got)
+	}
+}
+
 func TestIsLoss(t *testing.T) {
 	type tc struct {
 		input    Grid
@@ -173,7 +252,7 @@ func TestIsLoss(t *testing.T) {
 	tests := []tc{
 		{
 			input: Grid{
-				Tiles: [4][4]Tile{
+				Tiles: [][]Tile{
 					{{Val: 2}, {Val: 0}, {Val: 8}, {Val: 0}},
 					{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
 					{{Val: 0}, {Val: 4}, {Val: 0}, {Val: 0}},
@@ -184,7 +263,7 @@ func TestIsLoss(t *testing.T) {
 		},
 		{
 			input: Grid{
-				Tiles: [4][4]Tile{
+				Tiles: [][]Tile{
 					{{Val: 4}, {Val: 4}, {Val: 2}, {Val: 4}},
 					{{Val: 4}, {Val: 2}, {Val: 4}, {Val: 2}},
 					{{Val: 2}, {Val: 4}, {Val: 2}, {Val: 4}},
@@ -195,7 +274,7 @@ func TestIsLoss(t *testing.T) {
 		},
 		{
 			input: Grid{
-				Tiles: [4][4]Tile{
+				Tiles: [][]Tile{
 					{{Val: 2}, {Val: 4}, {Val: 2}, {Val: 4}},
 					{{Val: 4}, {Val: 2}, {Val: 4}, {Val: 2}},
 					{{Val: 2}, {Val: 4}, {Val: 2}, {Val: 4}},
@@ -206,7 +285,7 @@ func TestIsLoss(t *testing.T) {
 		},
 		{
 			input: Grid{
-				Tiles: [4][4]Tile{
+				Tiles: [][]Tile{
 					{{Val: 2}, {Val: 4}, {Val: 16}, {Val: 2}},
 					{{Val: 8}, {Val: 32}, {Val: 64}, {Val: 16}},
 					{{Val: 4}, {Val: 16}, {Val: 8}, {Val: 4}},
@@ -217,7 +296,25 @@ func TestIsLoss(t *testing.T) {
 		},
 		{
 			input: Grid{
-				Tiles: [4][4]Tile{
+				Tiles: [][]Tile{
+					{{Val: 2}, {Val: 4}, {Val: 2}},
+					{{Val: 4}, {Val: 2}, {Val: 4}},
+				},
+			},
+			expected: true,
+		},
+		{
+			input: Grid{
+				Tiles: [][]Tile{
+					{{Val: 2}, {Val: 4}, {Val: 2}},
+					{{Val: 4}, {Val: 2}, {Val: 2}},
+				},
+			},
+			expected: false,
+		},
+		{
+			input: Grid{
+				Tiles: [][]Tile{
 					{{Val: 4}, {Val: 16}, {Val: 4}, {Val: 2}},
 					{{Val: 2}, {Val: 32}, {Val: 4}, {Val: 2}},
 					{{Val: 4}, {Val: 8}, {Val: 4}, {Val: 2}},
@@ -226,6 +323,17 @@ func TestIsLoss(t *testing.T) {
 			},
 			expected: false,
 		},
+		{
+			input: Grid{
+				Tiles: [][]Tile{
+					{{Val: 2}, {Val: 4}, {Val: 2}, {Val: 4}},
+					{{Val: 4}, {Val: 2}, {Val: 4}, {Val: 2}},
+					{{Val: 2}, {Val: 4}, {Val: 2}, {Val: 4}},
+					{{Val: 4}, {Val: 2}, {Val: Obstacle}, {Val: Obstacle}},
+				},
+			},
+			expected: true,
+		},
 	}
 	for i := range tests {
 		actual := tests[i].input.isLoss()
@@ -236,7 +344,10 @@ func TestIsLoss(t *testing.T) {
 }
 
 // gridsAreEqual checks whether grids are equal, ignoring the UUID fields of tiles.
-func gridsAreEqual(grid1, grid2 [4][4]Tile) bool {
+func gridsAreEqual(grid1, grid2 [][]Tile) bool {
+	if len(grid1) != len(grid2) {
+		return false
+	}
 	for i := range grid1 {
 		if !rowsAreEqual(grid1[i], grid2[i]) {
 			return false
@@ -246,7 +357,10 @@ func gridsAreEqual(grid1, grid2 [4][4]Tile) bool {
 }
 
 // rowsAreEqual checks whether rows of tiles are equal, ignoring the UUID fields.
-func rowsAreEqual(row1, row2 [4]Tile) bool {
+func rowsAreEqual(row1, row2 []Tile) bool {
+	if len(row1) != len(row2) {
+		return false
+	}
 	for i := range row1 {
 		if row1[i].Val != row2[i].Val ||
 			row1[i].Cmb != row2[i].Cmb {
@@ -255,3 +369,69 @@ func rowsAreEqual(row1, row2 [4]Tile) bool {
 	}
 	return true
 }
+
+func TestObstacles(t *testing.T) {
+	g := NewSeededGrid(DefaultSize, DefaultSize, 1)
+	g.Tiles = [][]Tile{
+		{{Val: 2}, {Val: 2}, {Val: 0}, {Val: 0}},
+		{{Val: Obstacle}, {Val: 0}, {Val: 0}, {Val: 0}},
+		{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
+		{{Val: 0}, {Val: 0}, {Val: 0}, {Val: Obstacle}},
+	}
+
+	// Only the obstacle next to the merge is broken
+	g.Move(DirLeft)
+	if got := g.NumObstacles(); got != 1 || g.Tiles[3][3].Val != Obstacle {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 1, g.Debug())
+	}
+
+	// Obstacles fill empty spaces, but always leave one
+	empty := DefaultSize*DefaultSize - g.NumTiles()
+	placed := g.AddObstacles(100)
+	if len(placed) != empty-1 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", empty-1, len(placed))
+	}
+	for _, p := range placed {
+		if g.Tiles[p.Y][p.X].Val != Obstacle {
+			t.Errorf("Expected:\n<%v>\nGot:\n<%v>", Obstacle, g.Tiles[p.Y][p.X].Val)
+		}
+	}
+}
+
+func TestSeededGridIsDeterministic(t *testing.T) {
+	const seed = 2048
+	g1 := NewSeededGrid(DefaultSize, DefaultSize, seed)
+	g2 := NewSeededGrid(DefaultSize, DefaultSize, seed)
+	if !gridsAreEqual(g1.Tiles, g2.Tiles) {
+		t.Fatalf("Expected:\n<%v>\nGot:\n<%v>", g1.Debug(), g2.Debug())
+	}
+
+	for i, dir := range []Direction{DirUp, DirLeft, DirDown, DirRight, DirUp, DirUp, DirLeft, DirDown} {
+		g1.Move(dir)
+		g2.Move(dir)
+		if !gridsAreEqual(g1.Tiles, g2.Tiles) {
+			t.Fatalf("[%d] \nExpected:\n<%v>\nGot:\n<%v>", i, g1.Debug(), g2.Debug())
+		}
+	}
+}
+
+func TestRNGMarshalJSON(t *testing.T) {
+	rng := NewRNG(7)
+	rng.IntN(100)
+
+	b, err := json.Marshal(rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := &RNG{}
+	if err := json.Unmarshal(b, restored); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range 10 {
+		expected, got := rng.IntN(100), restored.IntN(100)
+		if expected != got {
+			t.Errorf("[%d] \nExpected:\n<%v>\nGot:\n<%v>", i, expected, got)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package grid
+
+import (
+	"encoding/json"
+	"math/rand/v2"
+)
+
+// RNG is a seeded random number generator whose state can be saved and restored,
+// so a sequence of tile spawns can be reproduced exactly.
+type RNG struct {
+	pcg  *rand.PCG
+	rand *rand.Rand
+}
+
+// NewRNG constructs a new random number generator from a seed.
+func NewRNG(seed uint64) *RNG {
+	pcg := rand.NewPCG(seed, seed)
+	return &RNG{pcg: pcg, rand: rand.New(pcg)}
+}
+
+// NewSeed returns a random seed.
+func NewSeed() uint64 {
+	return rand.Uint64()
+}
+
+// IntN returns a random number in the half-open interval [0,n).
+func (r *RNG) IntN(n int) int {
+	return r.rand.IntN(n)
+}
+
+// Float64 returns a random number in the half-open interval [0.0,1.0).
+func (r *RNG) Float64() float64 {
+	return r.rand.Float64()
+}
+
+// Clone returns a copy of the random number generator in its current state.
+func (r *RNG) Clone() *RNG {
+	pcg := *r.pcg
+	return &RNG{pcg: &pcg, rand: rand.New(&pcg)}
+}
+
+// MarshalJSON satisfies json.Marshaler.
+func (r *RNG) MarshalJSON() ([]byte, error) {
+	b, err := r.pcg.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(b)
+}
+
+// UnmarshalJSON satisfies json.Unmarshaler.
+func (r *RNG) UnmarshalJSON(data []byte) error {
+	var b []byte
+	if err := json.Unmarshal(data, &b); err != nil {
+		return err
+	}
+
+	pcg := &rand.PCG{}
+	if err := pcg.UnmarshalBinary(b); err != nil {
+		return err
+	}
+	r.pcg, r.rand = pcg, rand.New(pcg)
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package backend
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+)
+
+// History contains previous game states so that moves can be undone and redone.
+type History struct {
+	Past   []Snapshot `json:"past"`   // states before each move, most recent last
+	Future []Snapshot `json:"future"` // states which have been undone, most recent last
+	Undos  int        `json:"undos"`  // the number of undos used in the current game
+}
+
+// savedHistory is the maximum number of moves which are saved to be undone or
+// redone. Older moves are only kept whilst the game is running, so the save file
+// stays small.
+const savedHistory = 20
+
+// Snapshot contains the state of a game at a point in time. Only the tile values
+// are kept rather than the whole grid, as a game's history is saved after every
+// move.
+type Snapshot struct {
+	Tiles [][]int       `json:"tiles"` // the value of each tile, indexed by row, then column
+	RNG   *grid.RNG     `json:"rng"`   // continues spawning the same tiles after the move is undone
+	Score int           `json:"score"`
+	Time  time.Duration `json:"time"`
+	Moves int           `json:"moves"`
+	WonAt time.Duration `json:"wonAt,omitempty"`
+}
+
+// NewHistory constructs an empty history.
+func NewHistory() *History {
+	return &History{
+		Past:   []Snapshot{},
+		Future: []Snapshot{},
+	}
+}
+
+// Clear empties the history.
+func (h *History) Clear() *History {
+	h.Past = []Snapshot{}
+	h.Future = []Snapshot{}
+	h.Undos = 0
+	return h
+}
+
+// MarshalJSON satisfies json.Marshaler. Only the most recent moves are saved.
+func (h *History) MarshalJSON() ([]byte, error) {
+	type history History // without this method, so it isn't called recursively
+	saved := history(*h)
+	saved.Past = saved.Past[max(len(saved.Past)-savedHistory, 0):]
+	saved.Future = saved.Future[max(len(saved.Future)-savedHistory, 0):]
+	return json.Marshal(saved)
+}
+
+// fits returns whether every snapshot has the given number of columns and rows, so
+// it can be restored. Snapshots from old save files have no tiles.
+func (h *History) fits(width, height int) bool {
+	for _, s := range slices.Concat(h.Past, h.Future) {
+		if len(s.Tiles) != height {
+			return false
+		}
+		for _, row := range s.Tiles {
+			if len(row) != width {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// record adds a snapshot to the history, discarding any states which were undone
+// and the oldest states beyond the given length.
+func (h *History) record(s Snapshot, length int) {
+	h.Past = append(h.Past, s)
+	if len(h.Past) > length {
+		h.Past = h.Past[len(h.Past)-length:]
+	}
+	h.Future = []Snapshot{}
+}
+
+// snapshot captures the current state of the game.
+func (g *Game) snapshot() Snapshot {
+	s := Snapshot{
+		Tiles: g.Grid.Values(),
+		Score: g.Score,
+		Time:  g.Timer.Duration(),
+		Moves: g.Moves,
+		WonAt: g.WonAt,
+	}
+	if g.Grid.RNG != nil {
+		s.RNG = g.Grid.RNG.Clone()
+	}
+	return s
+}
+
+// restore sets the game to the state of a snapshot.
+func (g *Game) restore(s Snapshot) {
+	g.Grid.SetValues(s.Tiles)
+	g.Grid.RNG = nil
+	if s.RNG != nil {
+		g.Grid.RNG = s.RNG.Clone()
+	}
+	g.Grid.LastSpawn = nil
+	g.Score = s.Score
+	g.Timer.Set(s.Time)
+	g.Moves, g.WonAt = s.Moves, s.WonAt
+}
+
+// CanUndo returns whether there is a move which can be undone.
+func (g *Game) CanUndo() bool {
+	return len(g.History.Past) > 0 && g.UndosLeft() != 0
+}
+
+// CanRedo returns whether there is an undone move which can be redone.
+func (g *Game) CanRedo() bool {
+	return len(g.History.Future) > 0
+}
+
+// UndosLeft returns the number of undos remaining in the current game, or -1 if
+// there is no limit.
+func (g *Game) UndosLeft() int {
+	if g.opts == nil || g.opts.UndoLimit == 0 {
+		return -1
+	}
+	return max(g.opts.UndoLimit-g.History.Undos, 0)
+}
+
+// Undo reverts the game to its state before the last move. Returns false if
+// there is nothing to undo or no undos are left.
+func (g *Game) Undo() bool {
+	if !g.CanUndo() {
+		return false
+	}
+
+	past := g.History.Past
+	g.History.Future = append(g.History.Future, g.snapshot())
+	g.restore(past[len(past)-1])
+	g.History.Past = past[:len(past)-1]
+	g.History.Undos++
+	g.recordStep(Step{Action: ActionUndo})
+
+	g.saveAsync()
+	return true
+}
+
+// Redo reapplies the last undone move. Returns false if there is nothing to redo.
+func (g *Game) Redo() bool {
+	if !g.CanRedo() {
+		return false
+	}
+
+	future := g.History.Future
+	g.History.Past = append(g.History.Past, g.snapshot())
+	g.restore(future[len(future)-1])
+	g.History.Future = future[:len(future)-1]
+	g.recordStep(Step{Action: ActionRedo})
+
+	g.saveAsync()
+	return true
+}
@@ -0,0 +1,146 @@
+package backend
+
+import (
+	"cmp"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/z-riley/go-2048-battle/common/backend/store"
+)
+
+// LeaderboardSize is the number of runs in each ranking of the leaderboard.
+const LeaderboardSize = 10
+
+// Run is a finished singleplayer game on the leaderboard.
+type Run struct {
+	Name        string        `json:"name"` // the player's name when it finished
+	Score       int           `json:"score"`
+	HighestTile int           `json:"highestTile"`
+	Duration    time.Duration `json:"duration"`
+	Moves       int           `json:"moves"`
+	WonAt       time.Duration `json:"wonAt,omitempty"` // the time to the winning tile, or zero if it wasn't reached
+	At          time.Time     `json:"at"`              // when it finished
+	Replay      *Replay       `json:"replay,omitempty"`
+}
+
+// Leaderboard keeps the best singleplayer runs, by score and by the fastest time
+// to the winning tile.
+type Leaderboard struct {
+	Runs []Run `json:"runs"` // in the order they finished
+}
+
+// NewLeaderboard constructs an empty leaderboard.
+func NewLeaderboard() *Leaderboard {
+	return &Leaderboard{Runs: []Run{}}
+}
+
+// Add adds a finished run, then forgets every run which isn't in either ranking.
+// It returns whether the run made it onto the leaderboard.
+func (l *Leaderboard) Add(r Run) bool {
+	l.Runs = append(l.Runs, r)
+	added := len(l.Runs) - 1
+
+	keep := map[int]bool{}
+	for _, i := range slices.Concat(l.byScore(), l.byTime()) {
+		keep[i] = true
+	}
+	runs := make([]Run, 0, len(keep))
+	for i, r := range l.Runs {
+		if keep[i] {
+			runs = append(runs, r)
+		}
+	}
+	l.Runs = runs
+
+	return keep[added]
+}
+
+// ByScore returns the runs with the highest scores, highest first.
+func (l *Leaderboard) ByScore() []Run {
+	return l.runs(l.byScore())
+}
+
+// ByTime returns the runs which reached the winning tile the fastest, fastest
+// first.
+func (l *Leaderboard) ByTime() []Run {
+	return l.runs(l.byTime())
+}
+
+// byScore returns the indices of the runs with the highest scores, highest first.
+// Older runs come first in a tie.
+func (l *Leaderboard) byScore() []int {
+	return l.ranking(
+		func(Run) bool { return true },
+		func(a, b Run) int { return cmp.Compare(b.Score, a.Score) },
+	)
+}
+
+// byTime returns the indices of the runs which reached the winning tile the
+// fastest, fastest first. Older runs come first in a tie.
+func (l *Leaderboard) byTime() []int {
+	return l.ranking(
+		func(r Run) bool { return r.WonAt > 0 },
+		func(a, b Run) int { return cmp.Compare(a.WonAt, b.WonAt) },
+	)
+}
+
+// ranking returns the indices of the best runs which are included, in order.
+func (l *Leaderboard) ranking(include func(Run) bool, compare func(a, b Run) int) []int {
+	var ranking []int
+	for i, r := range l.Runs {
+		if include(r) {
+			ranking = append(ranking, i)
+		}
+	}
+	slices.SortStableFunc(ranking, func(a, b int) int {
+		return compare(l.Runs[a], l.Runs[b])
+	})
+	return ranking[:min(len(ranking), LeaderboardSize)]
+}
+
+// runs returns the runs with the given indices, in the same order.
+func (l *Leaderboard) runs(indices []int) []Run {
+	runs := make([]Run, 0, len(indices))
+	for _, i := range indices {
+		runs = append(runs, l.Runs[i])
+	}
+	return runs
+}
+
+// Serialise converts the leaderboard into JSON.
+func (l *Leaderboard) Serialise() ([]byte, error) {
+	return json.Marshal(l)
+}
+
+// Deserialise loads the JSON representation into memory.
+func (l *Leaderboard) Deserialise(j []byte) error {
+	return json.Unmarshal(j, &l)
+}
+
+// Save saves the leaderboard to a store.
+func (l *Leaderboard) Save(s *store.Store) error {
+	j, err := l.Serialise()
+	if err != nil {
+		return err
+	}
+	return s.SaveBytes(j)
+}
+
+// LoadLeaderboard loads a leaderboard from a store.
+func LoadLeaderboard(s *store.Store) (*Leaderboard, error) {
+	b, err := s.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	l := NewLeaderboard()
+	if err := l.Deserialise(b); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// NewLeaderboardStore returns the store which the leaderboard is saved to.
+func NewLeaderboardStore() *store.Store {
+	return store.NewStore(".leaderboard.bruh")
+}
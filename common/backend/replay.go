@@ -0,0 +1,218 @@
+package backend
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+	"github.com/z-riley/go-2048-battle/common/backend/store"
+)
+
+// Replay is a move-by-move recording of a game.
+type Replay struct {
+	Seed  uint64        `json:"seed"`  // the seed of the recorded grid
+	RNG   *grid.RNG     `json:"rng"`   // the state of the grid's random number generator at the start
+	Tiles [][]grid.Tile `json:"tiles"` // the tiles at the start
+	Steps []Step        `json:"steps"`
+}
+
+// Action is something that the player can do to change the state of a game.
+type Action string
+
+const (
+	ActionMove Action = "move"
+	ActionUndo Action = "undo"
+	ActionRedo Action = "redo"
+)
+
+// Step is a single recorded action.
+type Step struct {
+	Action Action         `json:"action"`
+	Dir    grid.Direction `json:"dir,omitempty"`   // the direction of a move
+	Spawn  *grid.Spawn    `json:"spawn,omitempty"` // the tile spawned by a move
+	Time   time.Duration  `json:"time"`            // the game timer when the action happened
+	At     time.Time      `json:"at"`              // the wall clock time when the action happened
+}
+
+// NewReplay constructs a replay which starts from the current state of a grid.
+func NewReplay(g *grid.Grid) *Replay {
+	start := g.Clone()
+	start.ClearCmbFlags()
+
+	return &Replay{
+		Seed:  start.Seed,
+		RNG:   start.RNG,
+		Tiles: start.Tiles,
+		Steps: []Step{},
+	}
+}
+
+// record adds a step to the replay.
+func (r *Replay) record(step Step) {
+	step.At = time.Now()
+	r.Steps = append(r.Steps, step)
+}
+
+// Serialise converts the replay into JSON.
+func (r *Replay) Serialise() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// Deserialise loads the JSON representation into memory.
+func (r *Replay) Deserialise(j []byte) error {
+	return json.Unmarshal(j, &r)
+}
+
+// Save saves the replay to a store.
+func (r *Replay) Save(s *store.Store) error {
+	j, err := r.Serialise()
+	if err != nil {
+		return err
+	}
+	return s.SaveBytes(j)
+}
+
+// LoadReplay loads a replay from a store.
+func LoadReplay(s *store.Store) (*Replay, error) {
+	b, err := s.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	r := &Replay{}
+	if err := r.Deserialise(b); err != nil {
+		return nil, err
+	}
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// validate returns an error if the replay doesn't start from a grid which can be
+// played back.
+func (r *Replay) validate() error {
+	if len(r.Tiles) == 0 {
+		return fmt.Errorf("%w: no tiles", ErrInvalidReplay)
+	}
+	width, height := len(r.Tiles[0]), len(r.Tiles)
+	for _, row := range r.Tiles {
+		if len(row) != width {
+			return fmt.Errorf("%w: rows have different lengths", ErrInvalidReplay)
+		}
+	}
+	if !grid.ValidSize(width, height) {
+		return fmt.Errorf("%w: unsupported grid size %dx%d", ErrInvalidReplay, width, height)
+	}
+	return nil
+}
+
+var (
+	// ErrInvalidReplay is returned when a replay can't be played back.
+	ErrInvalidReplay = errors.New("invalid replay")
+	// ErrReplayFinished is returned when there are no more steps in a replay.
+	ErrReplayFinished = errors.New("replay finished")
+)
+
+// ReplayPlayer plays back a replay one step at a time.
+type ReplayPlayer struct {
+	replay *Replay
+	game   *Game
+	next   int // index of the next step
+}
+
+// NewReplayPlayer constructs a new player, positioned at the start of the replay.
+// An error is returned if the replay is invalid.
+func NewReplayPlayer(r *Replay) (*ReplayPlayer, error) {
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+	p := &ReplayPlayer{replay: r}
+	p.Restart()
+	return p, nil
+}
+
+// Restart returns to the start of the replay.
+func (p *ReplayPlayer) Restart() {
+	if p.game != nil {
+		p.Stop()
+	}
+	game := NewGame(&Opts{
+		SaveToDisk:  false,
+		Width:       len(p.replay.Tiles[0]),
+		Height:      len(p.replay.Tiles),
+		Seed:        p.replay.Seed,
+		UndoHistory: len(p.replay.Steps),
+	})
+	start := &grid.Grid{
+		Tiles: p.replay.Tiles,
+		Seed:  p.replay.Seed,
+		RNG:   p.replay.RNG,
+	}
+	game.Grid = start.Clone()
+
+	p.game = game
+	p.next = 0
+}
+
+// Stop stops the game's timer, once the player is no longer needed.
+func (p *ReplayPlayer) Stop() {
+	p.game.Timer.Stop()
+}
+
+// Game returns the game in its current state of playback.
+func (p *ReplayPlayer) Game() *Game {
+	return p.game
+}
+
+// Done returns whether every step has been played.
+func (p *ReplayPlayer) Done() bool {
+	return p.next >= len(p.replay.Steps)
+}
+
+// Position returns the number of steps played, and the total number of steps.
+func (p *ReplayPlayer) Position() (int, int) {
+	return p.next, len(p.replay.Steps)
+}
+
+// Delay returns the real time that passed between the last played step and the
+// next one, which is never more than the given limit.
+func (p *ReplayPlayer) Delay(limit time.Duration) time.Duration {
+	if p.next == 0 || p.Done() {
+		return 0
+	}
+	delay := p.replay.Steps[p.next].At.Sub(p.replay.Steps[p.next-1].At)
+	return min(max(delay, 0), limit)
+}
+
+// Step plays the next step of the replay. An error is returned if the replay has
+// finished, or if the played step doesn't match the recording.
+func (p *ReplayPlayer) Step() (Step, error) {
+	if p.Done() {
+		return Step{}, ErrReplayFinished
+	}
+	step := p.replay.Steps[p.next]
+	p.next++
+
+	switch step.Action {
+	case ActionMove:
+		p.game.ExecuteMove(step.Dir)
+		if !grid.EqualSpawn(p.game.Grid.LastSpawn, step.Spawn) {
+			return step, fmt.Errorf("step %d spawned %v, recorded %v", p.next, p.game.Grid.LastSpawn, step.Spawn)
+		}
+	case ActionUndo:
+		if !p.game.Undo() {
+			return step, fmt.Errorf("step %d could not undo", p.next)
+		}
+	case ActionRedo:
+		if !p.game.Redo() {
+			return step, fmt.Errorf("step %d could not redo", p.next)
+		}
+	default:
+		return step, fmt.Errorf("step %d has unsupported action \"%s\"", p.next, step.Action)
+	}
+	p.game.Timer.Set(step.Time)
+
+	return step, nil
+}
@@ -0,0 +1,115 @@
+package backend
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/z-riley/go-2048-battle/common/backend/store"
+)
+
+// winningTile is the tile which wins a game.
+const winningTile = 2048
+
+// HistogramBuckets are the lowest scores counted in each bucket of the score
+// histogram. The last bucket has no upper limit.
+var HistogramBuckets = []int{0, 1000, 2000, 4000, 8000, 16000, 32000, 64000}
+
+// Stats summarises every singleplayer game which has been finished.
+type Stats struct {
+	Played      int           `json:"played"`
+	Wins        int           `json:"wins"` // games which reached the winning tile
+	HighestTile int           `json:"highestTile"`
+	TotalScore  int           `json:"totalScore"`
+	TotalMoves  int           `json:"totalMoves"`
+	BestTime    time.Duration `json:"bestTime"`  // the fastest time to the winning tile, or zero if it has never been reached
+	Histogram   []int         `json:"histogram"` // the number of games in each of HistogramBuckets
+}
+
+// NewStats constructs stats for no games.
+func NewStats() *Stats {
+	return &Stats{Histogram: make([]int, len(HistogramBuckets))}
+}
+
+// AverageScore returns the mean score of every game, or zero if none have been
+// played.
+func (s *Stats) AverageScore() int {
+	if s.Played == 0 {
+		return 0
+	}
+	return s.TotalScore / s.Played
+}
+
+// Record adds a finished game to the stats.
+func (s *Stats) Record(g *Game) {
+	s.Played++
+	s.HighestTile = max(s.HighestTile, g.Grid.HighestTile())
+	s.TotalScore += g.Score
+	s.TotalMoves += g.Moves
+	if g.WonAt > 0 {
+		s.Wins++
+		if s.BestTime == 0 || g.WonAt < s.BestTime {
+			s.BestTime = g.WonAt
+		}
+	}
+
+	s.fillHistogram()
+	s.Histogram[histogramBucket(g.Score)]++
+}
+
+// fillHistogram gives the histogram a count for every bucket, since stats saved
+// before a bucket was added have a shorter one.
+func (s *Stats) fillHistogram() {
+	for len(s.Histogram) < len(HistogramBuckets) {
+		s.Histogram = append(s.Histogram, 0)
+	}
+}
+
+// histogramBucket returns the index of the histogram bucket which a score is
+// counted in.
+func histogramBucket(score int) int {
+	for i := len(HistogramBuckets) - 1; i > 0; i-- {
+		if score >= HistogramBuckets[i] {
+			return i
+		}
+	}
+	return 0
+}
+
+// Serialise converts the stats into JSON.
+func (s *Stats) Serialise() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// Deserialise loads the JSON representation into memory.
+func (s *Stats) Deserialise(j []byte) error {
+	return json.Unmarshal(j, &s)
+}
+
+// Save saves the stats to a store.
+func (s *Stats) Save(st *store.Store) error {
+	j, err := s.Serialise()
+	if err != nil {
+		return err
+	}
+	return st.SaveBytes(j)
+}
+
+// LoadStats loads stats from a store.
+func LoadStats(st *store.Store) (*Stats, error) {
+	b, err := st.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	s := NewStats()
+	if err := s.Deserialise(b); err != nil {
+		return nil, err
+	}
+	s.fillHistogram()
+	return s, nil
+}
+
+// NewStatsStore returns the store which the stats of singleplayer games are saved
+// to.
+func NewStatsStore() *store.Store {
+	return store.NewStore(".stats.bruh")
+}
@@ -7,23 +7,32 @@ import (
 type Timer struct {
 	Time     time.Duration `json:"time"`
 	isPaused bool
+	done     chan struct{} // closed to stop counting
 }
 
-// NewTimer constructs a new timer. Resume must be called to start the timer.
+// NewTimer constructs a new timer. Resume must be called to start the timer, and
+// Stop once it's no longer needed.
 func NewTimer() *Timer {
 	t := Timer{
 		Time:     time.Duration(0),
 		isPaused: true,
+		done:     make(chan struct{}),
 	}
 
-	go func() {
+	go func(done chan struct{}) {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
 		for {
-			time.Sleep(time.Second)
-			if !t.isPaused {
-				t.Time += time.Second
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if !t.isPaused {
+					t.Time += time.Second
+				}
 			}
 		}
-	}()
+	}(t.done)
 
 	return &t
 }
@@ -34,6 +43,15 @@ func (t *Timer) Resume() *Timer {
 	return t
 }
 
+// Stop stops the timer counting for good, so it can be discarded. Calling it more
+// than once has no effect.
+func (t *Timer) Stop() {
+	if t.done != nil {
+		close(t.done)
+		t.done = nil
+	}
+}
+
 // Pause pauses the timer.
 func (t *Timer) Pause() *Timer {
 	t.isPaused = true
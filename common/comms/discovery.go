@@ -0,0 +1,174 @@
+package comms
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DiscoveryPort is the UDP port which hosts announce themselves on.
+	DiscoveryPort = 8081
+
+	// AnnounceInterval is how often hosts announce themselves.
+	AnnounceInterval = time.Second
+
+	// hostTimeout is how long a host is listed for after its last announcement.
+	hostTimeout = 3 * AnnounceInterval
+
+	// discoveryGame identifies announcements from go-2048-battle hosts, so other
+	// broadcasts on the port are ignored.
+	discoveryGame = "go-2048-battle"
+)
+
+// Announcement is broadcast on the LAN by hosts so guests can find them.
+type Announcement struct {
+	Game        string `json:"game"`        // always discoveryGame
+	Name        string `json:"name"`        // username of the host
+	Version     string `json:"version"`     // version of the host's client
+	MinProtocol int    `json:"minProtocol"` // oldest version of the protocol the host can use
+	MaxProtocol int    `json:"maxProtocol"` // newest version of the protocol the host can use
+	Port        uint16 `json:"port"`        // port that the host's server is listening on
+	FreeSlots   int    `json:"freeSlots"`   // number of guests which can still join
+}
+
+// Host is a host which has been found on the LAN.
+type Host struct {
+	Announcement
+	IP       string    // address that the announcement came from
+	LastSeen time.Time // time of the latest announcement
+}
+
+// Compatible returns an error wrapping ErrIncompatible if the host can't be
+// joined. Only the versions of the protocol are announced, so features are checked
+// once the guest has joined.
+func (a Announcement) Compatible() error {
+	_, err := negotiateProtocol(LocalHandshake(), Handshake{
+		MinProtocol: a.MinProtocol,
+		MaxProtocol: a.MaxProtocol,
+	})
+	return err
+}
+
+// Announce broadcasts the announcement returned by get on the LAN every
+// AnnounceInterval, until the context is cancelled.
+func Announce(ctx context.Context, get func() Announcement) error {
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return fmt.Errorf("failed to open socket: %w", err)
+	}
+	dest := &net.UDPAddr{IP: net.IPv4bcast, Port: DiscoveryPort}
+
+	go func() {
+		defer conn.Close()
+
+		ticker := time.NewTicker(AnnounceInterval)
+		defer ticker.Stop()
+		for {
+			a := get()
+			a.Game = discoveryGame
+			b, err := json.Marshal(a)
+			if err == nil {
+				// Announcements are best effort, so a network without broadcast support
+				// just means that nobody will find the host
+				_, _ = conn.WriteToUDP(b, dest)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Browser lists the hosts which are announcing themselves on the LAN.
+type Browser struct {
+	conn  *net.UDPConn
+	mu    sync.Mutex
+	hosts map[string]Host // keyed by IP address and port
+}
+
+// NewBrowser starts listening for hosts on the given port, which is usually
+// DiscoveryPort. The port is shared, so more than one game on the same computer
+// can look for hosts at once.
+func NewBrowser(port int) (*Browser, error) {
+	lc := net.ListenConfig{Control: reuseAddr}
+	conn, err := lc.ListenPacket(context.Background(), "udp4", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for hosts: %w", err)
+	}
+
+	b := &Browser{
+		conn:  conn.(*net.UDPConn),
+		hosts: map[string]Host{},
+	}
+	go b.listen()
+
+	return b, nil
+}
+
+// Hosts returns the hosts which have announced themselves recently, sorted by
+// name.
+func (b *Browser) Hosts() []Host {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	hosts := make([]Host, 0, len(b.hosts))
+	for key, h := range b.hosts {
+		if time.Since(h.LastSeen) > hostTimeout {
+			delete(b.hosts, key)
+			continue
+		}
+		hosts = append(hosts, h)
+	}
+	slices.SortFunc(hosts, func(a, b Host) int {
+		if n := strings.Compare(a.Name, b.Name); n != 0 {
+			return n
+		}
+		return strings.Compare(a.IP, b.IP)
+	})
+
+	return hosts
+}
+
+// Close stops listening for hosts.
+func (b *Browser) Close() error {
+	return b.conn.Close()
+}
+
+// listen records announcements until the browser is closed.
+func (b *Browser) listen() {
+	buf := make([]byte, 1024)
+	for {
+		n, addr, err := b.conn.ReadFromUDP(buf)
+		if errors.Is(err, net.ErrClosed) {
+			return
+		} else if err != nil {
+			continue
+		}
+
+		var a Announcement
+		if err := json.Unmarshal(buf[:n], &a); err != nil || a.Game != discoveryGame {
+			continue
+		}
+
+		h := Host{
+			Announcement: a,
+			IP:           addr.IP.String(),
+			LastSeen:     time.Now(),
+		}
+		b.mu.Lock()
+		b.hosts[fmt.Sprintf("%s:%d", h.IP, h.Port)] = h
+		b.mu.Unlock()
+	}
+}
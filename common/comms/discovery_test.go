@@ -0,0 +1,80 @@
+package comms
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestBrowser(t *testing.T) {
+	b, err := NewBrowser(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	conn, err := net.DialUDP("udp4", nil, &net.UDPAddr{
+		IP:   net.IPv4(127, 0, 0, 1),
+		Port: b.conn.LocalAddr().(*net.UDPAddr).Port,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	want := Announcement{
+		Game:      discoveryGame,
+		Name:      "host",
+		Version:   "1.1",
+		Port:      8080,
+		FreeSlots: 1,
+	}
+	for _, a := range []any{
+		Announcement{Game: "another game", Name: "stranger"},
+		"not an announcement",
+		want,
+	} {
+		msg, err := json.Marshal(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.Write(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Wait for the announcements to arrive
+	var hosts []Host
+	for range 100 {
+		if hosts = b.Hosts(); len(hosts) > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if len(hosts) != 1 {
+		t.Fatalf("Expected:\n<%v>\nGot:\n<%v>", 1, len(hosts))
+	}
+	if hosts[0].Announcement != want {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", want, hosts[0].Announcement)
+	}
+	if hosts[0].IP != "127.0.0.1" {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", "127.0.0.1", hosts[0].IP)
+	}
+}
+
+func TestBrowsersSharePort(t *testing.T) {
+	b, err := NewBrowser(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	// Another game on the same computer can look for hosts at the same time
+	other, err := NewBrowser(b.conn.LocalAddr().(*net.UDPAddr).Port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+}
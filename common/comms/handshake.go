@@ -0,0 +1,114 @@
+package comms
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+const (
+	// MinProtocol is the oldest version of the protocol which this client can use.
+	MinProtocol = 1
+	// MaxProtocol is the newest version of the protocol which this client can use.
+	// It must be incremented whenever a message changes in a way that older clients
+	// can't understand.
+	MaxProtocol = 1
+)
+
+// Feature is an optional part of the protocol, which both clients must support
+// before it can be used.
+type Feature string
+
+const (
+	// FeatureDeltaSync is the host sending each played input to the guests, instead
+	// of whole games. It is required to play at all.
+	FeatureDeltaSync Feature = "delta sync"
+	// FeatureChat is the chat in the lobby and during a match.
+	FeatureChat Feature = "chat"
+	// FeatureRules is the host choosing the rules of a match. Guests without it can
+	// only play by DefaultRules.
+	FeatureRules Feature = "rules"
+)
+
+// features are the features supported by this client.
+var features = []Feature{FeatureDeltaSync, FeatureChat, FeatureRules}
+
+// requiredFeatures are the features which both clients must support to play
+// together.
+var requiredFeatures = []Feature{FeatureDeltaSync}
+
+// ErrIncompatible is returned when two clients can't play together.
+var ErrIncompatible = errors.New("incompatible version")
+
+// Handshake describes the versions of the protocol and the features supported by
+// a client.
+type Handshake struct {
+	MinProtocol int       `json:"minProtocol"`
+	MaxProtocol int       `json:"maxProtocol"`
+	Features    []Feature `json:"features,omitempty"`
+}
+
+// LocalHandshake returns the handshake of this client.
+func LocalHandshake() Handshake {
+	return Handshake{
+		MinProtocol: MinProtocol,
+		MaxProtocol: MaxProtocol,
+		Features:    slices.Clone(features),
+	}
+}
+
+// Session is what two clients have agreed to use when talking to each other.
+type Session struct {
+	Protocol int       // the highest version supported by both clients
+	Features []Feature // the features supported by both clients
+}
+
+// Has returns whether both clients support a feature.
+func (s Session) Has(f Feature) bool {
+	return slices.Contains(s.Features, f)
+}
+
+// Negotiate returns the session used between a client and its peer. An error
+// wrapping ErrIncompatible explains why the peer can't play, from the point of
+// view of the local client.
+func Negotiate(local, peer Handshake) (Session, error) {
+	protocol, err := negotiateProtocol(local, peer)
+	if err != nil {
+		return Session{}, err
+	}
+
+	s := Session{Protocol: protocol}
+	for _, f := range local.Features {
+		if slices.Contains(peer.Features, f) {
+			s.Features = append(s.Features, f)
+		}
+	}
+
+	for _, f := range requiredFeatures {
+		switch {
+		case !slices.Contains(local.Features, f):
+			return Session{}, fmt.Errorf("%w: this game doesn't support %s", ErrIncompatible, f)
+		case !s.Has(f):
+			return Session{}, fmt.Errorf("%w: the other game doesn't support %s", ErrIncompatible, f)
+		}
+	}
+
+	return s, nil
+}
+
+// negotiateProtocol returns the highest version of the protocol supported by a
+// client and its peer.
+func negotiateProtocol(local, peer Handshake) (int, error) {
+	switch {
+	case peer.MaxProtocol == 0:
+		// Versions before the handshake was added don't send one
+		return 0, fmt.Errorf("%w: the other game is too old", ErrIncompatible)
+	case peer.MaxProtocol < local.MinProtocol:
+		return 0, fmt.Errorf("%w: the other game is too old (protocol %d, need %d)",
+			ErrIncompatible, peer.MaxProtocol, local.MinProtocol)
+	case peer.MinProtocol > local.MaxProtocol:
+		return 0, fmt.Errorf("%w: this game is too old (protocol %d, need %d)",
+			ErrIncompatible, local.MaxProtocol, peer.MinProtocol)
+	}
+	return min(local.MaxProtocol, peer.MaxProtocol), nil
+}
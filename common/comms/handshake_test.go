@@ -0,0 +1,93 @@
+package comms
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	type tc struct {
+		name     string
+		local    Handshake
+		peer     Handshake
+		protocol int
+		features []Feature
+		err      error
+	}
+
+	all := []Feature{FeatureDeltaSync, FeatureChat, FeatureRules}
+
+	for _, tc := range []tc{
+		{
+			name:     "same versions",
+			local:    LocalHandshake(),
+			peer:     LocalHandshake(),
+			protocol: MaxProtocol,
+			features: all,
+		},
+		{
+			name:     "highest common version",
+			local:    Handshake{MinProtocol: 1, MaxProtocol: 3, Features: all},
+			peer:     Handshake{MinProtocol: 2, MaxProtocol: 5, Features: all},
+			protocol: 3,
+			features: all,
+		},
+		{
+			name:     "missing optional features",
+			local:    Handshake{MinProtocol: 1, MaxProtocol: 1, Features: all},
+			peer:     Handshake{MinProtocol: 1, MaxProtocol: 1, Features: []Feature{FeatureRules, FeatureDeltaSync}},
+			protocol: 1,
+			features: []Feature{FeatureDeltaSync, FeatureRules},
+		},
+		{
+			name:  "missing required feature",
+			local: Handshake{MinProtocol: 1, MaxProtocol: 1, Features: all},
+			peer:  Handshake{MinProtocol: 1, MaxProtocol: 1, Features: []Feature{FeatureChat}},
+			err:   ErrIncompatible,
+		},
+		{
+			name:  "peer too old",
+			local: Handshake{MinProtocol: 2, MaxProtocol: 3, Features: all},
+			peer:  Handshake{MinProtocol: 1, MaxProtocol: 1, Features: all},
+			err:   ErrIncompatible,
+		},
+		{
+			name:  "peer too new",
+			local: Handshake{MinProtocol: 1, MaxProtocol: 1, Features: all},
+			peer:  Handshake{MinProtocol: 2, MaxProtocol: 3, Features: all},
+			err:   ErrIncompatible,
+		},
+		{
+			name:  "no handshake",
+			local: LocalHandshake(),
+			peer:  Handshake{},
+			err:   ErrIncompatible,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := Negotiate(tc.local, tc.peer)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("Expected:\n<%v>\nGot:\n<%v>", tc.err, err)
+			}
+			if s.Protocol != tc.protocol {
+				t.Errorf("Expected:\n<%v>\nGot:\n<%v>", tc.protocol, s.Protocol)
+			}
+			if !slices.Equal(s.Features, tc.features) {
+				t.Errorf("Expected:\n<%v>\nGot:\n<%v>", tc.features, s.Features)
+			}
+		})
+	}
+}
+
+func TestAnnouncementCompatible(t *testing.T) {
+	a := Announcement{MinProtocol: MinProtocol, MaxProtocol: MaxProtocol}
+	if err := a.Compatible(); err != nil {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", nil, err)
+	}
+
+	a = Announcement{MinProtocol: MaxProtocol + 1, MaxProtocol: MaxProtocol + 1}
+	if err := a.Compatible(); !errors.Is(err, ErrIncompatible) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", ErrIncompatible, err)
+	}
+}
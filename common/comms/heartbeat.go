@@ -0,0 +1,77 @@
+package comms
+
+import (
+	"sync"
+	"time"
+)
+
+// Heartbeat tracks the health of a connection using the messages received over it.
+// It is safe for concurrent use.
+type Heartbeat struct {
+	mu       sync.Mutex
+	lastSeen time.Time     // time that the last message was received
+	latency  time.Duration // smoothed round trip time of pings
+	offset   time.Duration // smoothed estimate of how far the peer's clock is ahead
+	measured bool          // whether any pongs have been received
+}
+
+// NewHeartbeat constructs a new heartbeat for a connection which has just opened.
+func NewHeartbeat() *Heartbeat {
+	return &Heartbeat{lastSeen: time.Now()}
+}
+
+// Ping returns a new ping to send.
+func (h *Heartbeat) Ping() PingData {
+	return PingData{Sent: time.Now()}
+}
+
+// Seen records that a message has been received.
+func (h *Heartbeat) Seen() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastSeen = time.Now()
+}
+
+// Pong records the reply to a ping, updating the latency and the offset of the
+// peer's clock.
+func (h *Heartbeat) Pong(d PongData) {
+	rtt := max(time.Since(d.Ping.Sent), 0)
+	// The ping is assumed to have taken half of the round trip to arrive
+	offset := d.Received.Sub(d.Ping.Sent.Add(rtt / 2))
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastSeen = time.Now()
+	if h.measured {
+		// Smooth out the jitter between pings
+		h.latency = (3*h.latency + rtt) / 4
+		h.offset = (3*h.offset + offset) / 4
+	} else {
+		h.latency = rtt
+		h.offset = offset
+		h.measured = true
+	}
+}
+
+// Latency returns the round trip time of the connection, or false if it hasn't
+// been measured yet.
+func (h *Heartbeat) Latency() (time.Duration, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.latency, h.measured
+}
+
+// LocalTime converts a time on the peer's clock to the local clock. ok is false if
+// the offset between the clocks hasn't been measured yet.
+func (h *Heartbeat) LocalTime(t time.Time) (local time.Time, ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return t.Add(-h.offset), h.measured
+}
+
+// SinceSeen returns the time since the last message was received.
+func (h *Heartbeat) SinceSeen() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return time.Since(h.lastSeen)
+}
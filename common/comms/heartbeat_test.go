@@ -0,0 +1,67 @@
+package comms
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHeartbeat(t *testing.T) {
+	h := NewHeartbeat()
+	if _, ok := h.Latency(); ok {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", false, ok)
+	}
+
+	// The first pong sets the latency straight away
+	h.Pong(PongData{Ping: PingData{Sent: time.Now().Add(-100 * time.Millisecond)}})
+	latency, ok := h.Latency()
+	if !ok || latency < 100*time.Millisecond || latency > 150*time.Millisecond {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 100*time.Millisecond, latency)
+	}
+
+	// Later pongs are smoothed
+	h.Pong(PongData{Ping: PingData{Sent: time.Now().Add(-500 * time.Millisecond)}})
+	latency, _ = h.Latency()
+	if latency < 200*time.Millisecond || latency > 250*time.Millisecond {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 200*time.Millisecond, latency)
+	}
+
+	// A pong survives being sent over the connection
+	b, err := PongData{Ping: h.Ping()}.Serialise()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := ParseMessage(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Type != TypePongData {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", TypePongData, msg.Type)
+	}
+	if _, err := ParsePongData(msg.Content); err != nil {
+		t.Fatal(err)
+	}
+
+	if since := h.SinceSeen(); since > time.Second {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", "less than 1s", since)
+	}
+}
+
+func TestHeartbeatLocalTime(t *testing.T) {
+	h := NewHeartbeat()
+	if _, ok := h.LocalTime(time.Now()); ok {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", false, ok)
+	}
+
+	// The peer's clock is 5 seconds ahead, and the ping took 50ms each way
+	sent := time.Now().Add(-100 * time.Millisecond)
+	h.Pong(PongData{
+		Ping:     PingData{Sent: sent},
+		Received: sent.Add(50*time.Millisecond + 5*time.Second),
+	})
+
+	start := time.Now().Add(3 * time.Second)
+	local, ok := h.LocalTime(start.Add(5 * time.Second))
+	if diff := local.Sub(start).Abs(); !ok || diff > 20*time.Millisecond {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", start, local)
+	}
+}
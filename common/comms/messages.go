@@ -2,8 +2,15 @@ package comms
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/z-riley/go-2048-battle/common/backend"
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
 )
 
 // Message contains data for multiplayer mode communication. The Type field
@@ -25,17 +32,49 @@ type MessageType string
 
 const (
 	TypePlayerData  MessageType = "playerData"
-	TypeGameData    MessageType = "gameData"
+	TypeWelcomeData MessageType = "welcome"
+	TypeInputData   MessageType = "inputData"
+	TypeStateData   MessageType = "stateData"
+	TypeDeltaData   MessageType = "deltaData"
+	TypeAttackData  MessageType = "attackData"
+	TypeRulesData   MessageType = "rules"
+	TypeStartData   MessageType = "start"
+	TypeChatData    MessageType = "chat"
+	TypePingData    MessageType = "ping"
+	TypePongData    MessageType = "pong"
 	TypeEventData   MessageType = "eventData"
 	TypeRequestData MessageType = "request"
 )
 
-// PlayerData contains data about a player.
+// PlayerData contains data about a player. Guests send their own before they
+// know their ID, and the host relays everyone's to all of the guests.
 type PlayerData struct {
-	Version  string `json:"version"`
-	Username string `json:"username"`
+	Version   string    `json:"version"`
+	Handshake Handshake `json:"handshake"`
+	Username  string    `json:"username"`
+	ID        PlayerID  `json:"id,omitempty"`
+	ProfileID string    `json:"profileID,omitempty"` // the player's profile, which stays the same between lobbies
+	Rating    int       `json:"rating,omitempty"`    // the player's rating before the match
+	Left      bool      `json:"left,omitempty"`      // set when the player has left the lobby
+	Spectator bool      `json:"spectator,omitempty"` // set by guests who only want to watch
+	Dedicated bool      `json:"dedicated,omitempty"` // set by a host which doesn't play, like cmd/server
+	// Token proves who a guest is when they rejoin a match. Only the guest it was
+	// given to sends it, and the host never relays it
+	Token string `json:"token,omitempty"`
 }
 
+// PlayerID identifies a player in a lobby. The host is always HostID, and guests
+// are numbered after it in the order they join.
+type PlayerID int
+
+const (
+	// HostID is the ID of the host.
+	HostID PlayerID = 1
+
+	// MaxPlayers is the most players that can be in a lobby, including the host.
+	MaxPlayers = 4
+)
+
 // ParsePlayerData returns player data from a byte slice.
 func ParsePlayerData(b []byte) (d PlayerData, err error) {
 	err = json.Unmarshal(b, &d)
@@ -53,29 +92,208 @@ func (d PlayerData) Serialise() ([]byte, error) {
 	return json.Marshal(Message{TypePlayerData, b})
 }
 
-// GameData contains a game's current state.
-type GameData struct {
-	Game backend.Game `json:"game"`
+// WelcomeData is sent by the host to a guest when it joins the lobby, and again
+// when it rejoins a match after losing its connection.
+type WelcomeData struct {
+	ID    PlayerID `json:"id"`    // the ID given to the guest, or zero for a spectator
+	Token string   `json:"token"` // which the guest must send to rejoin the match
+}
+
+// ParseWelcomeData returns welcome data from a byte slice.
+func ParseWelcomeData(b []byte) (d WelcomeData, err error) {
+	err = json.Unmarshal(b, &d)
+	return d, err
+}
+
+// Serialise converts welcome data into a byte slice.
+func (d WelcomeData) Serialise() ([]byte, error) {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Message{TypeWelcomeData, b})
+}
+
+// InputData contains an action which the guest wants to take in their game. Only
+// the host executes it, so the guest can't claim a state which wasn't played.
+type InputData struct {
+	Seq    int            `json:"seq"` // starts at 1 and increments with every input
+	Action Action         `json:"action"`
+	Dir    grid.Direction `json:"dir,omitempty"` // only used by ActionMove
+}
+
+// Action is something a player can do to their game.
+type Action string
+
+const (
+	// ActionMove moves the grid in a direction.
+	ActionMove Action = "move"
+	// ActionReset starts a new game.
+	ActionReset Action = "reset"
+	// ActionLeave removes a player from the match. Only the host can take it, on
+	// behalf of a player who has disconnected.
+	ActionLeave Action = "leave"
+	// ActionTimeUp ends a round which has run out of time. Only the host can take
+	// it, and it doesn't belong to any player.
+	ActionTimeUp Action = "time up"
+	// ActionNextRound starts the next round of a match, after the last one has
+	// finished. Only the host can take it, and it doesn't belong to any player.
+	ActionNextRound Action = "next round"
+	// ActionRematch starts a new match between the same players, once everyone
+	// still in the match has asked for one. Only the host can take it, and it
+	// doesn't belong to any player.
+	ActionRematch Action = "rematch"
+)
+
+var (
+	// ErrInvalidInput is returned for an input which could never be played.
+	ErrInvalidInput = errors.New("invalid input")
+	// ErrInputOutOfOrder is returned for an input which doesn't directly follow the
+	// last one.
+	ErrInputOutOfOrder = errors.New("input out of order")
+	// ErrDesync is returned when a change to a game has a different result to the
+	// host's, so the games must be resynchronised.
+	ErrDesync = errors.New("game out of sync with host")
+)
+
+// ParseInputData returns input data from a byte slice.
+func ParseInputData(b []byte) (d InputData, err error) {
+	err = json.Unmarshal(b, &d)
+	return d, err
+}
+
+// Serialise converts input data into a byte slice.
+func (d InputData) Serialise() ([]byte, error) {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Message{TypeInputData, b})
+}
+
+// Validate returns an error if the input can't follow the input with sequence
+// number lastSeq.
+func (d InputData) Validate(lastSeq int) error {
+	if d.Seq != lastSeq+1 {
+		return fmt.Errorf("%w: expected %d, got %d", ErrInputOutOfOrder, lastSeq+1, d.Seq)
+	}
+	switch d.Action {
+	case ActionMove:
+		switch d.Dir {
+		case grid.DirUp, grid.DirDown, grid.DirLeft, grid.DirRight:
+			return nil
+		default:
+			return fmt.Errorf("%w: unknown direction \"%s\"", ErrInvalidInput, d.Dir)
+		}
+	case ActionReset:
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown action \"%s\"", ErrInvalidInput, d.Action)
+	}
+}
+
+// Mode is a set of rules for a versus match.
+type Mode string
+
+const (
+	// ModeRace is a race to 2048 in which players can't affect each other's games.
+	ModeRace Mode = "race"
+	// ModeAttack is a race to 2048 in which big merges send obstacles to an
+	// opponent's game.
+	ModeAttack Mode = "attack"
+)
+
+// RulesData contains the rules of a versus match, which the host chooses in the
+// lobby. Every round is won by the first player to reach the target tile, or the
+// last one standing. If there is a time limit, the highest score wins when it runs
+// out.
+type RulesData struct {
+	Mode      Mode          `json:"mode"`
+	Target    int           `json:"target"`              // the tile which wins a round
+	TimeLimit time.Duration `json:"timeLimit,omitempty"` // the length of each round, or zero for no limit
+	Rounds    int           `json:"rounds"`              // the first to win a majority of the rounds wins the match
+}
+
+// ErrInvalidRules is returned for rules which a match can't be played by.
+var ErrInvalidRules = errors.New("invalid rules")
+
+// DefaultRules returns the rules of a classic match: a single race to 2048.
+func DefaultRules() RulesData {
+	return RulesData{
+		Mode:   ModeRace,
+		Target: 2048,
+		Rounds: 1,
+	}
 }
 
-// ParseGameData returns game data from a byte slice.
-func ParseGameData(b []byte) (d GameData, err error) {
+// Validate returns an error if a match can't be played by the rules.
+func (d RulesData) Validate() error {
+	switch {
+	case d.Mode != ModeRace && d.Mode != ModeAttack:
+		return fmt.Errorf("%w: unknown mode \"%s\"", ErrInvalidRules, d.Mode)
+	case d.Target < 8 || d.Target&(d.Target-1) != 0:
+		return fmt.Errorf("%w: target %d isn't a tile", ErrInvalidRules, d.Target)
+	case d.TimeLimit < 0:
+		return fmt.Errorf("%w: negative time limit", ErrInvalidRules)
+	case d.Rounds < 1:
+		return fmt.Errorf("%w: %d rounds", ErrInvalidRules, d.Rounds)
+	default:
+		return nil
+	}
+}
+
+// ParseRulesData returns rules data from a byte slice.
+func ParseRulesData(b []byte) (d RulesData, err error) {
 	err = json.Unmarshal(b, &d)
 	return d, err
 }
 
-// Serialise converts game data into a byte slice.
-func (d GameData) Serialise() ([]byte, error) {
+// Serialise converts rules data into a byte slice.
+func (d RulesData) Serialise() ([]byte, error) {
 	b, err := json.Marshal(d)
 	if err != nil {
 		return nil, err
 	}
-	return json.Marshal(Message{TypeGameData, b})
+	return json.Marshal(Message{TypeRulesData, b})
+}
+
+// StateData contains the authoritative state of every game in a match. It is only
+// sent by the host, at the start of a match and when a guest asks to resync.
+type StateData struct {
+	Games      map[PlayerID]backend.Game `json:"games"`
+	Rules      RulesData                 `json:"rules"`
+	Round      int                       `json:"round"`                // starts at 1
+	Wins       map[PlayerID]int          `json:"wins,omitempty"`       // rounds won by each player before this one
+	Incoming   map[PlayerID]int          `json:"incoming,omitempty"`   // obstacles waiting to land in each game
+	Eliminated []PlayerID                `json:"eliminated,omitempty"` // in the order they were knocked out
+	Left       []PlayerID                `json:"left,omitempty"`
+	Winner     PlayerID                  `json:"winner,omitempty"`
+	TimeUp     bool                      `json:"timeUp,omitempty"`  // set when the round ran out of time
+	Series     map[PlayerID]int          `json:"series,omitempty"`  // matches won by each player before this one
+	Rematch    []PlayerID                `json:"rematch,omitempty"` // players who have asked for a rematch
+	Seq        int                       `json:"seq"`               // sequence number of the last delta included
+}
+
+// ParseStateData returns state data from a byte slice.
+func ParseStateData(b []byte) (d StateData, err error) {
+	err = json.Unmarshal(b, &d)
+	return d, err
+}
+
+// Serialise converts state data into a byte slice.
+func (d StateData) Serialise() ([]byte, error) {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Message{TypeStateData, b})
 }
 
 // EventData contains an event which has occurred.
 type EventData struct {
-	Event Event `json:"event"`
+	Event  Event    `json:"event"`
+	Player PlayerID `json:"player,omitempty"` // who caused the event, when relayed by the host
+	Reason string   `json:"reason,omitempty"` // why a player was turned away
 }
 
 // Event is a stand-alone occurrence.
@@ -86,6 +304,22 @@ const (
 	EventHostStartGame Event = "host started game"
 	// EventScreenLoaded signifies that the screen has finished initialising.
 	EventScreenLoaded Event = "screen loaded"
+	// EventLobbyFull signifies that the host has turned away a player because the
+	// lobby is full. Spectators are never turned away.
+	EventLobbyFull Event = "lobby full"
+	// EventIncompatible signifies that the host has turned away a player whose game
+	// can't play with the host's. The reason is given from the player's point of
+	// view.
+	EventIncompatible Event = "incompatible"
+	// EventRematchRequest signifies that a player wants a rematch, after a match
+	// has finished.
+	EventRematchRequest Event = "rematch request"
+	// EventRematchAccept signifies that a player has accepted somebody else's
+	// request for a rematch.
+	EventRematchAccept Event = "rematch accept"
+	// EventReady signifies that a guest has loaded the match and measured the
+	// host's clock, so the countdown to the start can begin.
+	EventReady Event = "ready"
 )
 
 // ParseEventData returns event data from a byte slice.
@@ -122,3 +356,206 @@ func (d RequestData) Serialise() ([]byte, error) {
 	}
 	return json.Marshal(Message{TypeRequestData, b})
 }
+
+// DeltaData contains a single change to one of the games in a match. It is sent
+// by the host after every change, instead of the whole state.
+type DeltaData struct {
+	Seq    int            `json:"seq"`    // increments with every delta in the match
+	Player PlayerID       `json:"player"` // whose game changed
+	Action Action         `json:"action"`
+	Dir    grid.Direction `json:"dir,omitempty"`   // only used by ActionMove
+	Spawn  *grid.Spawn    `json:"spawn,omitempty"` // tile spawned by ActionMove, if any
+	Seed   uint64         `json:"seed,omitempty"`  // seed of the new games for ActionReset, ActionNextRound and ActionRematch
+
+	// Obstacles are the incoming obstacles which landed after ActionMove, in
+	// ModeAttack
+	Obstacles []grid.Spawn `json:"obstacles,omitempty"`
+}
+
+// ParseDeltaData returns delta data from a byte slice.
+func ParseDeltaData(b []byte) (d DeltaData, err error) {
+	err = json.Unmarshal(b, &d)
+	return d, err
+}
+
+// Serialise converts delta data into a byte slice.
+func (d DeltaData) Serialise() ([]byte, error) {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Message{TypeDeltaData, b})
+}
+
+// NewDeltaData returns the delta for an action which has just been executed on a
+// game.
+func NewDeltaData(seq int, player PlayerID, action Action, dir grid.Direction, g *backend.Game) DeltaData {
+	d := DeltaData{
+		Seq:    seq,
+		Player: player,
+		Action: action,
+	}
+	switch action {
+	case ActionMove:
+		d.Dir = dir
+		d.Spawn = g.Grid.LastSpawn
+	case ActionReset:
+		d.Seed = g.Grid.Seed
+	}
+	return d
+}
+
+// Apply executes the change on a copy of the game. ActionLeave, ActionTimeUp,
+// ActionNextRound and ActionRematch don't change a single game, so they must be
+// handled by the caller. Tiles are spawned by the game's own random number
+// generator, so ErrDesync is returned if the spawned tile doesn't match the host's.
+func (d DeltaData) Apply(g *backend.Game) error {
+	switch d.Action {
+	case ActionMove:
+		g.ExecuteMove(d.Dir)
+		if !grid.EqualSpawn(g.Grid.LastSpawn, d.Spawn) {
+			return fmt.Errorf("%w: spawned %v, host spawned %v", ErrDesync, g.Grid.LastSpawn, d.Spawn)
+		}
+	case ActionReset:
+		g.ResetKeepTimerWithSeed(d.Seed)
+	case ActionLeave, ActionTimeUp, ActionNextRound, ActionRematch:
+	default:
+		return fmt.Errorf("%w: unknown action \"%s\"", ErrInvalidInput, d.Action)
+	}
+	return nil
+}
+
+// AttackData is sent by the host when a player's merges send obstacles to an
+// opponent, in ModeAttack. The obstacles land in the target's game after their
+// next move.
+type AttackData struct {
+	Seq       int      `json:"seq"` // shares its sequence numbers with deltas
+	From      PlayerID `json:"from"`
+	To        PlayerID `json:"to"`
+	Obstacles int      `json:"obstacles"`
+}
+
+// ParseAttackData returns attack data from a byte slice.
+func ParseAttackData(b []byte) (d AttackData, err error) {
+	err = json.Unmarshal(b, &d)
+	return d, err
+}
+
+// Serialise converts attack data into a byte slice.
+func (d AttackData) Serialise() ([]byte, error) {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Message{TypeAttackData, b})
+}
+
+// PingData is sent regularly to check that the connection is alive and measure its
+// latency. The receiver replies straight away with a pong containing the ping.
+type PingData struct {
+	Sent time.Time `json:"sent"` // time on the sender's clock
+}
+
+// ParsePingData returns ping data from a byte slice.
+func ParsePingData(b []byte) (d PingData, err error) {
+	err = json.Unmarshal(b, &d)
+	return d, err
+}
+
+// Serialise converts ping data into a byte slice.
+func (d PingData) Serialise() ([]byte, error) {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Message{TypePingData, b})
+}
+
+// PongData is the reply to a ping.
+type PongData struct {
+	Ping     PingData  `json:"ping"`
+	Received time.Time `json:"received"` // time that the ping arrived, on the receiver's clock
+}
+
+// ParsePongData returns pong data from a byte slice.
+func ParsePongData(b []byte) (d PongData, err error) {
+	err = json.Unmarshal(b, &d)
+	return d, err
+}
+
+// Serialise converts pong data into a byte slice.
+func (d PongData) Serialise() ([]byte, error) {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Message{TypePongData, b})
+}
+
+// StartData is sent by the host once every guest is ready, to start the countdown
+// to the beginning of a match. Everybody's game timer starts when it reaches zero.
+type StartData struct {
+	At time.Time `json:"at"` // time that the match starts, on the host's clock
+}
+
+// ParseStartData returns start data from a byte slice.
+func ParseStartData(b []byte) (d StartData, err error) {
+	err = json.Unmarshal(b, &d)
+	return d, err
+}
+
+// Serialise converts start data into a byte slice.
+func (d StartData) Serialise() ([]byte, error) {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Message{TypeStartData, b})
+}
+
+// MaxChatLength is the most characters a chat message can have.
+const MaxChatLength = 80
+
+// ChatData contains a chat message. Guests send their messages to the host, who
+// fills in the sender's name and relays them to everyone.
+type ChatData struct {
+	Name string `json:"name,omitempty"` // set by the host
+	Text string `json:"text"`
+}
+
+// ParseChatData returns chat data from a byte slice.
+func ParseChatData(b []byte) (d ChatData, err error) {
+	err = json.Unmarshal(b, &d)
+	return d, err
+}
+
+// Serialise converts chat data into a byte slice.
+func (d ChatData) Serialise() ([]byte, error) {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Message{TypeChatData, b})
+}
+
+// SanitiseChat makes the text of a chat message safe to show. Control characters
+// and invalid UTF-8 are removed, all whitespace is collapsed into single spaces,
+// and the text is cut short at MaxChatLength characters.
+func SanitiseChat(text string) string {
+	text = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsSpace(r):
+			return ' '
+		case r == utf8.RuneError, !unicode.IsPrint(r):
+			return -1
+		default:
+			return r
+		}
+	}, text)
+	text = strings.Join(strings.Fields(text), " ")
+
+	if utf8.RuneCountInString(text) > MaxChatLength {
+		text = strings.TrimSpace(string([]rune(text)[:MaxChatLength]))
+	}
+	return text
+}
@@ -0,0 +1,280 @@
+package comms
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/z-riley/go-2048-battle/common/backend"
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+)
+
+func TestInputDataValidate(t *testing.T) {
+	type tc struct {
+		name    string
+		input   InputData
+		lastSeq int
+		want    error
+	}
+
+	for _, tc := range []tc{
+		{
+			name:    "move",
+			input:   InputData{Seq: 1, Action: ActionMove, Dir: grid.DirLeft},
+			lastSeq: 0,
+			want:    nil,
+		},
+		{
+			name:    "reset",
+			input:   InputData{Seq: 8, Action: ActionReset},
+			lastSeq: 7,
+			want:    nil,
+		},
+		{
+			name:    "repeated input",
+			input:   InputData{Seq: 3, Action: ActionMove, Dir: grid.DirUp},
+			lastSeq: 3,
+			want:    ErrInputOutOfOrder,
+		},
+		{
+			name:    "skipped input",
+			input:   InputData{Seq: 5, Action: ActionMove, Dir: grid.DirUp},
+			lastSeq: 3,
+			want:    ErrInputOutOfOrder,
+		},
+		{
+			name:    "unknown direction",
+			input:   InputData{Seq: 1, Action: ActionMove, Dir: "diagonal"},
+			lastSeq: 0,
+			want:    ErrInvalidInput,
+		},
+		{
+			name:    "unknown action",
+			input:   InputData{Seq: 1, Action: "win"},
+			lastSeq: 0,
+			want:    ErrInvalidInput,
+		},
+		{
+			name:    "host action",
+			input:   InputData{Seq: 1, Action: ActionNextRound},
+			lastSeq: 0,
+			want:    ErrInvalidInput,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.input.Validate(tc.lastSeq)
+			if !errors.Is(got, tc.want) {
+				t.Errorf("Expected:\n<%v>\nGot:\n<%v>", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestRulesDataValidate(t *testing.T) {
+	type tc struct {
+		name  string
+		rules RulesData
+		want  error
+	}
+
+	for _, tc := range []tc{
+		{
+			name:  "default",
+			rules: DefaultRules(),
+			want:  nil,
+		},
+		{
+			name:  "time attack",
+			rules: RulesData{Mode: ModeAttack, Target: 2048, TimeLimit: 3 * time.Minute, Rounds: 1},
+			want:  nil,
+		},
+		{
+			name:  "best of 3",
+			rules: RulesData{Mode: ModeRace, Target: 512, Rounds: 3},
+			want:  nil,
+		},
+		{
+			name:  "unknown mode",
+			rules: RulesData{Mode: "chess", Target: 2048, Rounds: 1},
+			want:  ErrInvalidRules,
+		},
+		{
+			name:  "target isn't a tile",
+			rules: RulesData{Mode: ModeRace, Target: 1000, Rounds: 1},
+			want:  ErrInvalidRules,
+		},
+		{
+			name:  "no rounds",
+			rules: RulesData{Mode: ModeRace, Target: 2048},
+			want:  ErrInvalidRules,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.rules.Validate()
+			if !errors.Is(got, tc.want) {
+				t.Errorf("Expected:\n<%v>\nGot:\n<%v>", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestSanitiseChat(t *testing.T) {
+	type tc struct {
+		name string
+		text string
+		want string
+	}
+
+	for _, tc := range []tc{
+		{
+			name: "plain",
+			text: "GG",
+			want: "GG",
+		},
+		{
+			name: "whitespace",
+			text: "  nice\n\tmerge!  ",
+			want: "nice merge!",
+		},
+		{
+			name: "control characters",
+			text: "hi\x00\x1b[31m there\u200b",
+			want: "hi[31m there",
+		},
+		{
+			name: "invalid UTF-8",
+			text: "ab\xffc",
+			want: "abc",
+		},
+		{
+			name: "too long",
+			text: strings.Repeat("é", MaxChatLength+10),
+			want: strings.Repeat("é", MaxChatLength),
+		},
+		{
+			name: "only whitespace",
+			text: " \n ",
+			want: "",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := SanitiseChat(tc.text)
+			if got != tc.want {
+				t.Errorf("Expected:\n<%v>\nGot:\n<%v>", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestStateData(t *testing.T) {
+	host := backend.NewGame(&backend.Opts{})
+	guest := backend.NewGame(&backend.Opts{})
+	guest.ExecuteMove(grid.DirRight)
+	guest.ExecuteMove(grid.DirUp)
+
+	b, err := StateData{
+		Games:      map[PlayerID]backend.Game{HostID: *host, 2: *guest},
+		Eliminated: []PlayerID{2},
+		Seq:        2,
+	}.Serialise()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := ParseMessage(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Type != TypeStateData {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", TypeStateData, msg.Type)
+	}
+	got, err := ParseStateData(msg.Content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Seq != 2 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 2, got.Seq)
+	}
+	if len(got.Eliminated) != 1 || got.Eliminated[0] != 2 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", []PlayerID{2}, got.Eliminated)
+	}
+	if g := got.Games[2]; !equalValues(g.Grid, guest.Grid) || g.Score != guest.Score {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", guest.Grid.Debug(), g.Grid.Debug())
+	}
+	if g := got.Games[HostID]; !equalValues(g.Grid, host.Grid) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", host.Grid.Debug(), g.Grid.Debug())
+	}
+}
+
+func TestDeltaDataApply(t *testing.T) {
+	host := backend.NewGame(&backend.Opts{Seed: 7})
+	guest := backend.NewGame(&backend.Opts{Seed: 7})
+
+	// roundTrip sends a delta through its serialised form
+	roundTrip := func(d DeltaData) DeltaData {
+		b, err := d.Serialise()
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg, err := ParseMessage(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ParseDeltaData(msg.Content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	seq := 0
+	for _, dir := range []grid.Direction{grid.DirUp, grid.DirRight, grid.DirRight, grid.DirDown, grid.DirLeft} {
+		host.ExecuteMove(dir)
+		seq++
+		d := roundTrip(NewDeltaData(seq, HostID, ActionMove, dir, host))
+		if err := d.Apply(guest); err != nil {
+			t.Fatalf("Failed to apply delta: %v", err)
+		}
+	}
+	if !equalValues(host.Grid, guest.Grid) || host.Score != guest.Score {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", host.Grid.Debug(), guest.Grid.Debug())
+	}
+
+	host.ResetKeepTimerWithSeed(9)
+	seq++
+	d := roundTrip(NewDeltaData(seq, HostID, ActionReset, "", host))
+	if err := d.Apply(guest); err != nil {
+		t.Fatalf("Failed to apply delta: %v", err)
+	}
+	if !equalValues(host.Grid, guest.Grid) || guest.Score != 0 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", host.Grid.Debug(), guest.Grid.Debug())
+	}
+
+	// A copy which spawns different tiles to the host must be resynchronised
+	diverged := backend.NewGame(&backend.Opts{Seed: 8})
+	host.Grid.Tiles = grid.NewTiles(grid.DefaultSize, grid.DefaultSize)
+	host.Grid.Tiles[0][0].Val = 2 // so moving down always spawns a tile
+	diverged.Grid.Tiles = host.Grid.Clone().Tiles
+	host.ExecuteMove(grid.DirDown)
+	d = NewDeltaData(seq+1, HostID, ActionMove, grid.DirDown, host)
+	if err := d.Apply(diverged); !errors.Is(err, ErrDesync) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", ErrDesync, err)
+	}
+}
+
+// equalValues returns whether two grids have the same tile values. Tiles in
+// separate games never share UUIDs, so EqualGrid can't be used.
+func equalValues(a, b *grid.Grid) bool {
+	if a.Width() != b.Width() || a.Height() != b.Height() {
+		return false
+	}
+	for y := range a.Tiles {
+		for x := range a.Tiles[y] {
+			if a.Tiles[y][x].Val != b.Tiles[y][x].Val {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,4 @@
+package comms
+
+// soReusePort is SO_REUSEPORT, which the syscall package doesn't define on Linux.
+const soReusePort = 0xf
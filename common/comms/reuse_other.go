@@ -0,0 +1,11 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package comms
+
+import "syscall"
+
+// reuseAddr does nothing on systems where the address can't be shared, so only one
+// game on the computer can look for hosts at once.
+func reuseAddr(_, _ string, _ syscall.RawConn) error {
+	return nil
+}
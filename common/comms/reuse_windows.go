@@ -0,0 +1,15 @@
+package comms
+
+import "syscall"
+
+// reuseAddr lets other sockets listen on the same address as this one.
+func reuseAddr(_, _ string, c syscall.RawConn) error {
+	var sockErr error
+	err := c.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptInt(syscall.Handle(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
@@ -0,0 +1,23 @@
+package comms
+
+import "time"
+
+// Timings of the versus protocol, which the host and every guest must agree on.
+const (
+	// PingInterval is how often the players are pinged during a match.
+	PingInterval = time.Second
+	// HeartbeatTimeout is how long a player can be silent for before the connection
+	// is considered lost.
+	HeartbeatTimeout = 3 * PingInterval
+	// ReconnectGrace is how long a player has to reconnect before they are knocked
+	// out of the match.
+	ReconnectGrace = 15 * time.Second
+	// RoundPause is how long the result of a round is shown before the next one
+	// starts.
+	RoundPause = 3 * time.Second
+	// CountdownLength is how long the countdown to the start of a match lasts.
+	CountdownLength = 3 * time.Second
+	// ReadyTimeout is how long the host waits for every player to be ready before
+	// starting the countdown anyway.
+	ReadyTimeout = 10 * time.Second
+)
@@ -0,0 +1,134 @@
+// Package host runs the host's side of a versus game, which is shared by the host's
+// screens and the headless server. A Lobby gives guests their places before the
+// match, then a Match counts down to it, times its rounds, lets lost guests rejoin
+// and spectators start watching, and sends every change to the guests.
+package host
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/z-riley/go-2048-battle/common/comms"
+)
+
+var (
+	// ErrFull is returned when somebody can't join because every place is taken.
+	ErrFull = errors.New("lobby is full")
+	// ErrRejoinRefused is returned when somebody tries to rejoin a match as a player
+	// who is still connected, or without the player's token.
+	ErrRejoinRefused = errors.New("rejoin refused")
+)
+
+// Transport sends messages to the guests connected to the host. It is implemented
+// by *servesyouright.Server.
+type Transport interface {
+	GetClientIDs() []int
+	WriteToClient(id int, b []byte) error
+}
+
+// message is data which can be sent to a guest.
+type message interface {
+	Serialise() ([]byte, error)
+}
+
+// Peer is the connection to a player in a match.
+type Peer struct {
+	Session   comms.Session // what was agreed with the player's game in the lobby
+	Heartbeat *comms.Heartbeat
+	// DisconnectedAt is when the connection was lost, or zero if the player is
+	// connected
+	DisconnectedAt time.Time
+
+	conn  int    // the connection ID on the host's server, or -1 if there isn't one
+	token string // proves who the guest is when they rejoin
+}
+
+// NewPeer constructs the connection to a player, which has just been made with the
+// agreed session. Guests use it for their connection to the host.
+func NewPeer(session comms.Session) *Peer {
+	return &Peer{Session: session, Heartbeat: comms.NewHeartbeat(), conn: -1}
+}
+
+// newToken returns a random token for a guest to rejoin a match with.
+func newToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err) // crypto/rand never fails on supported platforms
+	}
+	return hex.EncodeToString(b)
+}
+
+// negotiate agrees what to use with a guest's game. The error wraps
+// comms.ErrIncompatible if the games can't play together by the rules.
+func negotiate(peer comms.Handshake, rules comms.RulesData) (comms.Session, error) {
+	session, err := comms.Negotiate(comms.LocalHandshake(), peer)
+	if err == nil && !session.Has(comms.FeatureRules) && rules != comms.DefaultRules() {
+		err = fmt.Errorf("%w: the other game doesn't support %s", comms.ErrIncompatible, comms.FeatureRules)
+	}
+	return session, err
+}
+
+// incompatibleEvent returns the event which turns away a guest whose game can't
+// play with the host's. The reason is explained from the guest's point of view.
+func incompatibleEvent(peer comms.Handshake) comms.EventData {
+	reason := fmt.Sprintf("%v: this game doesn't support %s", comms.ErrIncompatible, comms.FeatureRules)
+	if _, err := comms.Negotiate(peer, comms.LocalHandshake()); err != nil {
+		reason = err.Error()
+	}
+	return comms.EventData{Event: comms.EventIncompatible, Reason: reason}
+}
+
+// send sends a message to the guest on a connection. Nothing is sent without a
+// transport, e.g. in a match against a bot.
+func send(net Transport, conn int, m message) error {
+	if net == nil {
+		return nil
+	}
+	b, err := m.Serialise()
+	if err != nil {
+		return fmt.Errorf("failed to serialise message: %w", err)
+	}
+	if err := net.WriteToClient(conn, b); err != nil {
+		return fmt.Errorf("failed to send message to client %d: %w", conn, err)
+	}
+	return nil
+}
+
+// sendToAll sends a message to every connected guest for which include returns
+// true. One lost connection doesn't stop the rest from receiving it.
+func sendToAll(net Transport, m message, include func(conn int) bool) error {
+	if net == nil {
+		return nil
+	}
+	b, err := m.Serialise()
+	if err != nil {
+		return fmt.Errorf("failed to serialise message: %w", err)
+	}
+
+	var errs []error
+	for _, conn := range net.GetClientIDs() {
+		if !include(conn) {
+			continue
+		}
+		if err := net.WriteToClient(conn, b); err != nil {
+			errs = append(errs, fmt.Errorf("failed to send message to client %d: %w", conn, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// everyone is used with sendToAll to send a message to every guest.
+func everyone(int) bool { return true }
+
+// answerPing replies to a ping from the guest on a connection, so it can measure
+// the latency and the host's clock.
+func answerPing(net Transport, conn int, msg comms.Message) error {
+	ping, err := comms.ParsePingData(msg.Content)
+	if err != nil {
+		return fmt.Errorf("failed to parse ping data: %w", err)
+	}
+	return send(net, conn, comms.PongData{Ping: ping, Received: time.Now()})
+}
@@ -0,0 +1,538 @@
+package host
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+	"github.com/z-riley/go-2048-battle/common/comms"
+	"github.com/z-riley/go-2048-battle/common/host/hosttest"
+	"github.com/z-riley/go-2048-battle/common/match"
+)
+
+func guest(name string) comms.PlayerData {
+	return comms.PlayerData{Username: name, Handshake: comms.LocalHandshake()}
+}
+
+func self() comms.PlayerData {
+	return comms.PlayerData{Username: "host", Handshake: comms.LocalHandshake(), ID: comms.HostID}
+}
+
+// handle sends a message to the match from a connection.
+func handle(t *testing.T, h *Match, conn int, m message) error {
+	t.Helper()
+	b, err := m.Serialise()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := comms.ParseMessage(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h.HandleMessage(conn, msg)
+}
+
+// heartbeat sends a message to the match's heartbeat from a connection.
+func heartbeat(t *testing.T, h *Match, conn int, m message) (bool, error) {
+	t.Helper()
+	b, err := m.Serialise()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := comms.ParseMessage(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h.HandleHeartbeat(conn, msg)
+}
+
+// received returns the last message of a type sent to a client.
+func received(t *testing.T, net *hosttest.Transport, conn int, typ comms.MessageType) []byte {
+	t.Helper()
+	msgs := net.Received(conn, typ)
+	if len(msgs) == 0 {
+		t.Fatalf("Expected:\n<%v>\nGot:\n<%v>", typ, "nothing")
+	}
+	return msgs[len(msgs)-1].Content
+}
+
+// receivedDelta returns the last change sent to a client.
+func receivedDelta(t *testing.T, net *hosttest.Transport, conn int) comms.DeltaData {
+	t.Helper()
+	d, err := comms.ParseDeltaData(received(t, net, conn, comms.TypeDeltaData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// receivedWelcome returns the last welcome sent to a client.
+func receivedWelcome(t *testing.T, net *hosttest.Transport, conn int) comms.WelcomeData {
+	t.Helper()
+	w, err := comms.ParseWelcomeData(received(t, net, conn, comms.TypeWelcomeData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func TestLobby(t *testing.T) {
+	net := hosttest.NewTransport(0, 1, 2, 3)
+	l := NewLobby(net, self(), comms.DefaultRules())
+
+	// Guests are numbered after the host, and each is given their own token
+	tokens := map[string]bool{}
+	for conn := range comms.MaxPlayers - 1 {
+		joined, err := l.Join(conn, guest("guest"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := receivedWelcome(t, net, conn)
+		if !joined || w.ID != comms.HostID+comms.PlayerID(conn)+1 || w.Token == "" || tokens[w.Token] {
+			t.Errorf("Expected:\n<%v>\nGot:\n<%v>", comms.HostID+comms.PlayerID(conn)+1, w)
+		}
+		tokens[w.Token] = true
+	}
+	var ids []comms.PlayerID
+	for _, p := range l.Players() {
+		ids = append(ids, p.ID)
+	}
+	if want := []comms.PlayerID{1, 2, 3, 4}; !slices.Equal(ids, want) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", want, ids)
+	}
+
+	// Sending player data again only changes the guest's details, and a token sent
+	// with it is never relayed to anybody else
+	renamed := guest("renamed")
+	renamed.Token = "secret"
+	if joined, err := l.Join(0, renamed); err != nil || joined {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", false, joined)
+	}
+	if name, _ := l.Name(0); name != "renamed" {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", "renamed", name)
+	}
+	if msgs := net.Received(0, comms.TypeWelcomeData); len(msgs) != 0 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 0, len(msgs))
+	}
+	for _, msg := range net.Received(1, comms.TypePlayerData) {
+		if p, _ := comms.ParsePlayerData(msg.Content); p.Token != "" {
+			t.Errorf("Expected:\n<%v>\nGot:\n<%v>", "no token", p.Token)
+		}
+	}
+
+	// Nobody else fits, and they're told so
+	if _, err := l.Join(3, guest("late guest")); !errors.Is(err, ErrFull) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", ErrFull, err)
+	}
+	if event, _ := comms.ParseEventData(received(t, net, 3, comms.TypeEventData)); event.Event != comms.EventLobbyFull {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", comms.EventLobbyFull, event.Event)
+	}
+	if l.FreeSlots() != 0 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 0, l.FreeSlots())
+	}
+
+	// Spectators can still watch, without a place or a token
+	fan := guest("fan")
+	fan.Spectator = true
+	if joined, err := l.Join(3, fan); err != nil || joined {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", false, joined)
+	}
+	if w := receivedWelcome(t, net, 3); w.ID != 0 || w.Token != "" || l.Spectators() != 1 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", "spectator", w)
+	}
+	if left, ok, _ := l.Leave(3); !ok || !left.Spectator || l.Spectators() != 0 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", "spectator left", left)
+	}
+
+	// Somebody leaving frees up their place, and everyone is told
+	left, ok, err := l.Leave(0)
+	if err != nil || !ok || left.ID != 2 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 2, left.ID)
+	}
+	if p, _ := comms.ParsePlayerData(received(t, net, 1, comms.TypePlayerData)); !p.Left {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", "player left", p)
+	}
+	if l.FreeSlots() != 1 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 1, l.FreeSlots())
+	}
+
+	// A guest who can't be told the rules can't play by any others
+	old := comms.PlayerData{
+		Username:  "old guest",
+		Handshake: comms.Handshake{MinProtocol: 1, MaxProtocol: 1, Features: []comms.Feature{comms.FeatureDeltaSync}},
+	}
+	if _, err := l.Join(3, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.SetRules(comms.RulesData{Mode: comms.ModeAttack, Target: 1024, Rounds: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.CheckRules(); err == nil {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", "error", err)
+	}
+	l.Leave(3)
+	if _, err := l.Join(3, old); !errors.Is(err, comms.ErrIncompatible) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", comms.ErrIncompatible, err)
+	}
+	if event, _ := comms.ParseEventData(received(t, net, 3, comms.TypeEventData)); event.Event != comms.EventIncompatible {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", comms.EventIncompatible, event.Event)
+	}
+
+	// Everyone is told when the rules change, and so is anybody who joins later
+	rules := comms.RulesData{Mode: comms.ModeAttack, Target: 1024, Rounds: 1}
+	if err := l.SetRules(rules); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := comms.ParseRulesData(received(t, net, 1, comms.TypeRulesData)); got != rules || l.Rules() != rules {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", rules, got)
+	}
+	if _, err := l.Join(3, guest("late guest")); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := comms.ParseRulesData(received(t, net, 3, comms.TypeRulesData)); got != rules {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", rules, got)
+	}
+}
+
+func TestDedicatedLobby(t *testing.T) {
+	host := self()
+	host.Dedicated = true
+	l := NewLobby(hosttest.NewTransport(), host, comms.DefaultRules())
+
+	// A dedicated host doesn't play, so every place is for a guest
+	for conn := range comms.MaxPlayers {
+		if _, err := l.Join(conn, guest("guest")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	players := l.Players()
+	if len(players) != comms.MaxPlayers || players[0].ID != comms.HostID+1 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", "every guest", players)
+	}
+}
+
+// newTestMatch returns a match between the host and two guests on connections 0
+// and 1, with the tokens the guests were welcomed with.
+func newTestMatch(t *testing.T) (*Match, *hosttest.Transport, []string) {
+	t.Helper()
+	net := hosttest.NewTransport(0, 1)
+	l := NewLobby(net, self(), comms.DefaultRules())
+	var tokens []string
+	for conn := range 2 {
+		if _, err := l.Join(conn, guest("guest")); err != nil {
+			t.Fatal(err)
+		}
+		tokens = append(tokens, receivedWelcome(t, net, conn).Token)
+	}
+
+	var ids []comms.PlayerID
+	for _, p := range l.Players() {
+		ids = append(ids, p.ID)
+	}
+	return NewMatch(match.New(ids, 1), l), net, tokens
+}
+
+func TestMatch(t *testing.T) {
+	h, net, _ := newTestMatch(t)
+	m := h.Match()
+	h.startAt = time.Now()
+	h.Update()
+
+	// A guest is sent the starting state once it has loaded the match
+	if err := handle(t, h, 0, comms.EventData{Event: comms.EventScreenLoaded}); err != nil {
+		t.Fatal(err)
+	}
+	state, err := comms.ParseStateData(received(t, net, 0, comms.TypeStateData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g := state.Games[2]; state.Seq != 0 || g.Grid.Debug() != m.Game(2).Grid.Debug() {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", m.Game(2).Grid.Debug(), g.Grid.Debug())
+	}
+
+	// A guest's inputs are played on their own game, and each change is sent to
+	// everyone
+	var played []comms.DeltaData
+	h.SetDeltaCallback(func(d comms.DeltaData) { played = append(played, d) })
+	for seq, dir := range []grid.Direction{grid.DirLeft, grid.DirUp} {
+		if err := handle(t, h, 1, comms.InputData{Seq: seq + 1, Action: comms.ActionMove, Dir: dir}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d := receivedDelta(t, net, 0)
+	if d.Seq != 2 || d.Player != 3 || d.Dir != grid.DirUp || len(played) != 2 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 2, d.Seq)
+	}
+
+	// An input out of order is rejected without a change being sent
+	before := m.Game(3).Grid.Debug()
+	if err := handle(t, h, 1, comms.InputData{Seq: 4, Action: comms.ActionMove, Dir: grid.DirRight}); err != nil {
+		t.Fatal(err)
+	}
+	if msgs := net.Received(0, comms.TypeDeltaData); len(msgs) != 0 || m.Game(3).Grid.Debug() != before {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 0, len(msgs))
+	}
+
+	// Inputs from somebody who isn't playing are refused
+	if err := handle(t, h, 5, comms.InputData{Seq: 1, Action: comms.ActionReset}); err == nil {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", "error", err)
+	}
+
+	// The host's own moves are sent too
+	if err := h.Play(comms.HostID, comms.ActionReset, ""); err != nil {
+		t.Fatal(err)
+	}
+	if d := receivedDelta(t, net, 1); d.Player != comms.HostID || d.Seed != m.Game(comms.HostID).Grid.Seed {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", comms.HostID, d.Player)
+	}
+
+	// A guest can ask for the state of every game to resync
+	if err := handle(t, h, 0, comms.RequestData{Request: comms.TypeStateData}); err != nil {
+		t.Fatal(err)
+	}
+	state, err = comms.ParseStateData(received(t, net, 0, comms.TypeStateData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Games) != 3 || state.Seq != 3 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 3, state.Seq)
+	}
+}
+
+func TestMatchCountdown(t *testing.T) {
+	h, net, _ := newTestMatch(t)
+	var startedAt time.Time
+	h.SetStartCallback(func(at time.Time) { startedAt = at })
+
+	// The countdown waits for every guest
+	if err := handle(t, h, 0, comms.EventData{Event: comms.EventReady}); err != nil {
+		t.Fatal(err)
+	}
+	h.Update()
+	if !h.StartAt().IsZero() {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", "no countdown", h.StartAt())
+	}
+	if err := handle(t, h, 1, comms.EventData{Event: comms.EventReady}); err != nil {
+		t.Fatal(err)
+	}
+	h.Update()
+	if len(net.Received(0, comms.TypeStartData)) != 1 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", "start time", "nothing")
+	}
+
+	// Nobody can play until it finishes
+	if err := handle(t, h, 0, comms.InputData{Seq: 1, Action: comms.ActionMove, Dir: grid.DirLeft}); err != nil {
+		t.Fatal(err)
+	}
+	if msgs := net.Received(1, comms.TypeDeltaData); len(msgs) != 0 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 0, len(msgs))
+	}
+
+	h.startAt = time.Now()
+	h.Update()
+	if !h.Started() || startedAt != h.StartAt() {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", h.StartAt(), startedAt)
+	}
+	if err := handle(t, h, 0, comms.InputData{Seq: 2, Action: comms.ActionMove, Dir: grid.DirLeft}); err != nil {
+		t.Fatal(err)
+	}
+	if d := receivedDelta(t, net, 1); d.Player != 2 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 2, d.Player)
+	}
+}
+
+func TestMatchHeartbeat(t *testing.T) {
+	h, net, _ := newTestMatch(t)
+	id, p := h.PeerFrom(0)
+	if id != 2 || p == nil {
+		t.Fatalf("Expected:\n<%v>\nGot:\n<%v>", 2, id)
+	}
+
+	// The guests are pinged as soon as the match is updated
+	h.Update()
+	if msgs := net.Received(1, comms.TypePingData); len(msgs) != 1 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 1, len(msgs))
+	}
+	if !p.DisconnectedAt.IsZero() || h.Match().Over() {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", "guest connected", p.DisconnectedAt)
+	}
+
+	// A guest's pings are answered
+	ping := comms.PingData{Sent: time.Now()}
+	if handled, err := heartbeat(t, h, 0, ping); !handled || err != nil {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", true, err)
+	}
+	pong, err := comms.ParsePongData(received(t, net, 0, comms.TypePongData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pong.Ping.Sent.Equal(ping.Sent) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", ping.Sent, pong.Ping.Sent)
+	}
+
+	// Their pongs measure the latency
+	if _, ok := p.Heartbeat.Latency(); ok {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", false, ok)
+	}
+	if _, err := heartbeat(t, h, 0, comms.PongData{Ping: ping}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.Heartbeat.Latency(); !ok {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", true, ok)
+	}
+
+	// Anything else is left for HandleMessage
+	if handled, _ := heartbeat(t, h, 0, comms.InputData{Seq: 1, Action: comms.ActionReset}); handled {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", false, handled)
+	}
+}
+
+func TestMatchRounds(t *testing.T) {
+	h, net, _ := newTestMatch(t)
+	h.Match().SetRules(comms.RulesData{Mode: comms.ModeRace, Target: 2048, TimeLimit: time.Minute, Rounds: 2})
+	h.startAt = time.Now()
+	h.Update()
+
+	// The round ends when its time runs out
+	h.roundStart = time.Now().Add(-time.Minute)
+	h.Update()
+	if !h.Match().RoundOver() {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", "round over", h.Match().Round())
+	}
+	h.Update()
+	if h.RoundOverAt().IsZero() {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", "round over time", h.RoundOverAt())
+	}
+
+	// The next round starts after the pause
+	h.roundOverAt = time.Now().Add(-comms.RoundPause)
+	h.Update()
+	if h.Match().Round() != 2 || h.Match().RoundOver() {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 2, h.Match().Round())
+	}
+	var actions []comms.Action
+	for _, msg := range net.Received(0, comms.TypeDeltaData) {
+		d, _ := comms.ParseDeltaData(msg.Content)
+		actions = append(actions, d.Action)
+	}
+	if want := []comms.Action{comms.ActionTimeUp, comms.ActionNextRound}; !slices.Equal(actions, want) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", want, actions)
+	}
+}
+
+func TestMatchConnections(t *testing.T) {
+	h, net, tokens := newTestMatch(t)
+
+	// Nobody can take over the game of a guest who is still connected, even with
+	// their token
+	claim := guest("guest")
+	claim.ID = 2
+	claim.Token = tokens[0]
+	if err := handle(t, h, 1, claim); !errors.Is(err, ErrRejoinRefused) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", ErrRejoinRefused, err)
+	}
+	if id, _ := h.PeerFrom(1); id != 3 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 3, id)
+	}
+
+	// Once they've lost their connection, only their token lets them rejoin
+	_, p := h.PeerFrom(0)
+	p.DisconnectedAt = time.Now()
+	claim.Token = tokens[1]
+	if err := handle(t, h, 1, claim); !errors.Is(err, ErrRejoinRefused) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", ErrRejoinRefused, err)
+	}
+	claim.Token = ""
+	if err := handle(t, h, 1, claim); !errors.Is(err, ErrRejoinRefused) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", ErrRejoinRefused, err)
+	}
+
+	// The guest rejoins on a new connection with the right token, and is welcomed back
+	claim.Token = tokens[0]
+	if err := handle(t, h, 4, claim); err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := h.PeerFrom(4); id != 2 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 2, id)
+	}
+	if id, _ := h.PeerFrom(0); id != 0 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 0, id)
+	}
+	if w := receivedWelcome(t, net, 4); w.ID != 2 || w.Token != tokens[0] {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 2, w.ID)
+	}
+
+	// Nobody can join who wasn't in the lobby
+	stranger := guest("stranger")
+	stranger.ID = 4
+	if err := handle(t, h, 0, stranger); !errors.Is(err, ErrRejoinRefused) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", ErrRejoinRefused, err)
+	}
+	if event, _ := comms.ParseEventData(received(t, net, 0, comms.TypeEventData)); event.Event != comms.EventLobbyFull {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", comms.EventLobbyFull, event.Event)
+	}
+
+	// Spectators are sent the lobby and told the match has started
+	fan := guest("late fan")
+	fan.Spectator = true
+	if err := handle(t, h, 0, fan); err != nil {
+		t.Fatal(err)
+	}
+	if msgs := net.Received(0, comms.TypePlayerData); len(msgs) != 3 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 3, len(msgs))
+	}
+
+	// A guest who doesn't reconnect in time is knocked out
+	_, p = h.PeerFrom(1)
+	p.DisconnectedAt = time.Now().Add(-comms.ReconnectGrace - time.Second)
+	p.Heartbeat = &comms.Heartbeat{}
+	h.Update()
+	if !h.Match().Left(3) || h.Standing() != 2 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", "player 3 to leave", h.Standing())
+	}
+	if d := receivedDelta(t, net, 4); d.Player != 3 || d.Action != comms.ActionLeave {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", comms.ActionLeave, d.Action)
+	}
+}
+
+func TestMatchRematch(t *testing.T) {
+	h, net, _ := newTestMatch(t)
+	h.Match().SetRules(comms.RulesData{Mode: comms.ModeRace, Target: 2048, TimeLimit: time.Minute, Rounds: 1})
+	h.startAt = time.Now()
+	h.Update()
+	h.roundStart = time.Now().Add(-time.Minute)
+	h.Update()
+	if !h.Match().Over() {
+		t.Fatalf("Expected:\n<%v>\nGot:\n<%v>", "match over", h.Match().Round())
+	}
+
+	// Everybody is told who wants a rematch
+	if err := h.WantRematch(comms.HostID, comms.EventRematchRequest); err != nil {
+		t.Fatal(err)
+	}
+	if err := handle(t, h, 0, comms.EventData{Event: comms.EventRematchAccept}); err != nil {
+		t.Fatal(err)
+	}
+	if msgs := net.Received(1, comms.TypeEventData); len(msgs) != 2 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 2, len(msgs))
+	}
+	if !h.Match().Over() {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", "no rematch yet", h.Match().Over())
+	}
+
+	// The rematch counts down once everybody wants one
+	if err := handle(t, h, 1, comms.EventData{Event: comms.EventRematchAccept}); err != nil {
+		t.Fatal(err)
+	}
+	if h.Match().Over() || h.Started() || h.StartAt().IsZero() {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", "countdown to rematch", h.StartAt())
+	}
+	if d := receivedDelta(t, net, 0); d.Action != comms.ActionRematch {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", comms.ActionRematch, d.Action)
+	}
+	if len(net.Received(1, comms.TypeStartData)) != 1 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", "start time of the rematch", "nothing")
+	}
+}
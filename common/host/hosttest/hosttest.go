@@ -0,0 +1,53 @@
+// Package hosttest provides a fake transport for testing the host's side of a
+// versus game without a network.
+package hosttest
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/z-riley/go-2048-battle/common/comms"
+)
+
+// Transport records the messages sent to each client. It implements
+// host.Transport.
+type Transport struct {
+	sent map[int][]comms.Message
+}
+
+// NewTransport constructs a transport with a client connected on each of conns.
+func NewTransport(conns ...int) *Transport {
+	t := &Transport{sent: map[int][]comms.Message{}}
+	for _, conn := range conns {
+		t.sent[conn] = nil
+	}
+	return t
+}
+
+// GetClientIDs returns the connected clients in order.
+func (t *Transport) GetClientIDs() []int {
+	return slices.Sorted(maps.Keys(t.sent))
+}
+
+// WriteToClient records a message sent to a client.
+func (t *Transport) WriteToClient(id int, b []byte) error {
+	msg, err := comms.ParseMessage(b)
+	if err != nil {
+		return err
+	}
+	t.sent[id] = append(t.sent[id], msg)
+	return nil
+}
+
+// Received returns the messages of a type sent to a client, and forgets every
+// message sent to it.
+func (t *Transport) Received(conn int, typ comms.MessageType) []comms.Message {
+	var msgs []comms.Message
+	for _, msg := range t.sent[conn] {
+		if msg.Type == typ {
+			msgs = append(msgs, msg)
+		}
+	}
+	t.sent[conn] = nil
+	return msgs
+}
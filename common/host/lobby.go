@@ -0,0 +1,277 @@
+package host
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/z-riley/go-2048-battle/common/comms"
+)
+
+// Lobby is where guests wait for the host to start a match. It is safe for
+// concurrent use.
+type Lobby struct {
+	net Transport
+
+	mu         sync.Mutex
+	self       comms.PlayerData                    // the host, who only plays unless dedicated
+	rules      comms.RulesData                     // the rules of the next match
+	guests     map[comms.PlayerID]comms.PlayerData // every guest in the lobby
+	conns      map[int]comms.PlayerID              // the guest on each connection
+	tokens     map[comms.PlayerID]string           // what each guest must send to rejoin the match
+	spectators map[int]string                      // the username of each spectator, by connection
+	sessions   map[int]comms.Session               // what was agreed with the game on each connection
+}
+
+// NewLobby constructs an empty lobby, which sends messages with net. self is the
+// player data of the host, which is sent to guests as the first player.
+func NewLobby(net Transport, self comms.PlayerData, rules comms.RulesData) *Lobby {
+	return &Lobby{
+		net:        net,
+		self:       self,
+		rules:      rules,
+		guests:     map[comms.PlayerID]comms.PlayerData{},
+		conns:      map[int]comms.PlayerID{},
+		tokens:     map[comms.PlayerID]string{},
+		spectators: map[int]string{},
+		sessions:   map[int]comms.Session{},
+	}
+}
+
+// slots returns the number of guests who fit in the lobby. A dedicated host
+// doesn't take up a place itself.
+func (l *Lobby) slots() int {
+	if l.self.Dedicated {
+		return comms.MaxPlayers
+	}
+	return comms.MaxPlayers - 1
+}
+
+// FreeSlots returns the number of guests who can still join.
+func (l *Lobby) FreeSlots() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.slots() - len(l.guests)
+}
+
+// Guests returns the number of guests who have joined.
+func (l *Lobby) Guests() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.guests)
+}
+
+// Spectators returns the number of people watching.
+func (l *Lobby) Spectators() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.spectators)
+}
+
+// Players returns everyone who will play in the match in order of ID, including
+// the host unless it's dedicated.
+func (l *Lobby) Players() []comms.PlayerData {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	players := l.everyone()
+	if l.self.Dedicated {
+		return players[1:]
+	}
+	return players
+}
+
+// everyone returns the player data of the host, followed by every guest in order
+// of ID. It's what the guests are sent, and needs the lock to be held.
+func (l *Lobby) everyone() []comms.PlayerData {
+	players := []comms.PlayerData{l.self}
+	for _, id := range slices.Sorted(maps.Keys(l.guests)) {
+		players = append(players, l.guests[id])
+	}
+	return players
+}
+
+// SetHost changes the player data of the host, and tells every guest about it.
+func (l *Lobby) SetHost(self comms.PlayerData) error {
+	l.mu.Lock()
+	l.self = self
+	l.mu.Unlock()
+	return l.sendPlayerData(self)
+}
+
+// Rules returns the rules of the next match.
+func (l *Lobby) Rules() comms.RulesData {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rules
+}
+
+// SetRules changes the rules of the next match, and tells every guest whose game
+// supports them.
+func (l *Lobby) SetRules(rules comms.RulesData) error {
+	l.mu.Lock()
+	l.rules = rules
+	l.mu.Unlock()
+	return sendToAll(l.net, rules, l.has(comms.FeatureRules))
+}
+
+// CheckRules returns an error naming a guest who can't play by the rules, because
+// the rules were changed after they joined.
+func (l *Lobby) CheckRules() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rules == comms.DefaultRules() {
+		return nil
+	}
+	for conn, id := range l.conns {
+		if !l.sessions[conn].Has(comms.FeatureRules) {
+			return fmt.Errorf("\"%s\" can only play by the default rules", l.guests[id].Username)
+		}
+	}
+	return nil
+}
+
+// Join handles player data from a guest. A guest joining the lobby is given the
+// lowest free ID and welcomed with the token to rejoin the match with, then
+// everyone is told about them, whereas a guest who is already in it has changed
+// their details. Spectators don't take up a place. joined is whether a new guest
+// has taken a place.
+//
+// Guests who can't join are told why, and the error wraps comms.ErrIncompatible
+// or is ErrFull.
+func (l *Lobby) Join(conn int, data comms.PlayerData) (joined bool, err error) {
+	session, err := negotiate(data.Handshake, l.Rules())
+	if err != nil {
+		if err := send(l.net, conn, incompatibleEvent(data.Handshake)); err != nil {
+			return false, fmt.Errorf("failed to turn away client: %w", err)
+		}
+		return false, err
+	}
+	// The token is only ever sent to its own guest
+	data.Token = ""
+
+	l.mu.Lock()
+	var id comms.PlayerID
+	_, known := l.spectators[conn]
+	if data.Spectator {
+		l.spectators[conn] = data.Username
+	} else {
+		id, known = l.conns[conn]
+		if !known {
+			for id = comms.HostID + 1; id <= comms.HostID+comms.PlayerID(l.slots()); id++ {
+				if _, ok := l.guests[id]; !ok {
+					break
+				}
+			}
+			if id > comms.HostID+comms.PlayerID(l.slots()) {
+				l.mu.Unlock()
+				if err := send(l.net, conn, comms.EventData{Event: comms.EventLobbyFull}); err != nil {
+					return false, fmt.Errorf("failed to turn away client: %w", err)
+				}
+				return false, ErrFull
+			}
+			l.conns[conn] = id
+			l.tokens[id] = newToken()
+		}
+		data.ID = id
+		l.guests[id] = data
+	}
+	l.sessions[conn] = session
+	players, token, rules := l.everyone(), l.tokens[id], l.rules
+	l.mu.Unlock()
+	joined = !known && !data.Spectator
+
+	if !known {
+		if err := send(l.net, conn, comms.WelcomeData{ID: id, Token: token}); err != nil {
+			return joined, fmt.Errorf("failed to welcome client: %w", err)
+		}
+	}
+
+	// Relay everyone's player data to every guest
+	if err := l.sendPlayerData(players...); err != nil {
+		return joined, fmt.Errorf("failed to send player data to clients: %w", err)
+	}
+
+	if !known && session.Has(comms.FeatureRules) {
+		if err := send(l.net, conn, rules); err != nil {
+			return joined, fmt.Errorf("failed to send rules to client: %w", err)
+		}
+	}
+
+	return joined, nil
+}
+
+// Leave removes the guest or spectator on a connection from the lobby, and tells
+// everyone else if a guest has left. ok is false if nobody had joined on it.
+func (l *Lobby) Leave(conn int) (left comms.PlayerData, ok bool, err error) {
+	l.mu.Lock()
+	delete(l.sessions, conn)
+	if name, ok := l.spectators[conn]; ok {
+		delete(l.spectators, conn)
+		l.mu.Unlock()
+		return comms.PlayerData{Username: name, Spectator: true}, true, nil
+	}
+	id, ok := l.conns[conn]
+	if !ok {
+		l.mu.Unlock()
+		return comms.PlayerData{}, false, nil
+	}
+	left = l.guests[id]
+	delete(l.conns, conn)
+	delete(l.guests, id)
+	delete(l.tokens, id)
+	l.mu.Unlock()
+
+	left.Left = true
+	if err := l.sendPlayerData(left); err != nil {
+		return left, true, fmt.Errorf("failed to tell guests that a player left: %w", err)
+	}
+	return left, true, nil
+}
+
+// Name returns the username of the guest or spectator on a connection.
+func (l *Lobby) Name(conn int) (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if id, ok := l.conns[conn]; ok {
+		return l.guests[id].Username, true
+	}
+	name, ok := l.spectators[conn]
+	return name, ok
+}
+
+// SendChat sends a message to every guest and spectator whose game supports the
+// chat, with the name of whoever wrote it. It returns the text which was sent,
+// which is empty if there was nothing to send.
+func (l *Lobby) SendChat(name, text string) (string, error) {
+	text = comms.SanitiseChat(text)
+	if text == "" {
+		return "", nil
+	}
+	return text, sendToAll(l.net, comms.ChatData{Name: name, Text: text}, l.has(comms.FeatureChat))
+}
+
+// Start tells every guest and spectator that the match is starting.
+func (l *Lobby) Start() error {
+	return sendToAll(l.net, comms.EventData{Event: comms.EventHostStartGame}, everyone)
+}
+
+// has returns a function for sendToAll which includes the connections whose games
+// support a feature.
+func (l *Lobby) has(f comms.Feature) func(conn int) bool {
+	return func(conn int) bool {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		return l.sessions[conn].Has(f)
+	}
+}
+
+// sendPlayerData sends player data to every connected guest.
+func (l *Lobby) sendPlayerData(players ...comms.PlayerData) error {
+	for _, p := range players {
+		if err := sendToAll(l.net, p, everyone); err != nil {
+			return err
+		}
+	}
+	return nil
+}
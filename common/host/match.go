@@ -0,0 +1,393 @@
+package host
+
+import (
+	"sync"
+	"time"
+
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+	"github.com/z-riley/go-2048-battle/common/comms"
+	"github.com/z-riley/go-2048-battle/common/match"
+	"github.com/z-riley/go-2048-battle/log"
+)
+
+// Match is the host's side of a match between the players in a lobby. The host
+// plays every game: the guests send it their inputs, and it sends everyone the
+// changes. It must be used from one goroutine, apart from HandleHeartbeat and
+// PeerFrom.
+type Match struct {
+	match *match.Match
+	net   Transport
+	logf  func(format string, v ...any)
+
+	players    map[comms.PlayerID]comms.PlayerData // everyone from the lobby, including the host
+	lobby      []comms.PlayerData                  // what spectators are sent when they start watching
+	connMu     sync.Mutex                          // guards the connection IDs of the peers
+	peers      map[comms.PlayerID]*Peer            // the connection to each guest
+	spectators map[int]spectator                   // everyone watching, by connection
+
+	onDelta func(comms.DeltaData)
+	onStart func(at time.Time)
+	onChat  func(name, text string)
+
+	// enteredAt is when the match was started, so the host doesn't wait forever for
+	// guests to be ready
+	enteredAt time.Time
+	// ready is the guests who are ready for the match to start
+	ready map[comms.PlayerID]bool
+	// startAt is when the countdown finishes, or zero until every guest is ready
+	startAt time.Time
+	// started is set once the countdown has finished
+	started bool
+	// roundStart is when the current round started, for the time limit
+	roundStart time.Time
+	// roundOverAt is when the current round finished, or zero if it's being played
+	roundOverAt time.Time
+	// pingedAt is when the guests were last pinged
+	pingedAt time.Time
+}
+
+// spectator is somebody watching the match.
+type spectator struct {
+	name    string
+	session comms.Session
+}
+
+// NewMatch constructs the host's side of a match between everyone in a lobby,
+// which sends messages with the lobby's transport. The countdown starts once every
+// guest is ready.
+func NewMatch(m *match.Match, l *Lobby) *Match {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	h := &Match{
+		match:      m,
+		net:        l.net,
+		logf:       log.Printf,
+		players:    map[comms.PlayerID]comms.PlayerData{},
+		lobby:      l.everyone(),
+		peers:      make(map[comms.PlayerID]*Peer, len(l.conns)),
+		spectators: make(map[int]spectator, len(l.spectators)),
+		enteredAt:  time.Now(),
+		ready:      map[comms.PlayerID]bool{},
+		roundStart: time.Now(),
+	}
+	for _, p := range h.lobby {
+		h.players[p.ID] = p
+	}
+	for conn, id := range l.conns {
+		h.peers[id] = &Peer{
+			Session:   l.sessions[conn],
+			Heartbeat: comms.NewHeartbeat(),
+			conn:      conn,
+			token:     l.tokens[id],
+		}
+	}
+	for conn, name := range l.spectators {
+		h.spectators[conn] = spectator{name: name, session: l.sessions[conn]}
+	}
+	m.WaitToStart() // until the countdown finishes
+	return h
+}
+
+// SetLogger sets the function which logs what happens in the match, which is the
+// game's debug log by default.
+func (h *Match) SetLogger(logf func(format string, v ...any)) *Match {
+	h.logf = logf
+	return h
+}
+
+// SetDeltaCallback sets the function which is called with every change made to the
+// match, once it has been sent to the guests.
+func (h *Match) SetDeltaCallback(f func(comms.DeltaData)) *Match {
+	h.onDelta = f
+	return h
+}
+
+// SetStartCallback sets the function which is called when the countdown finishes,
+// with the time it finished.
+func (h *Match) SetStartCallback(f func(at time.Time)) *Match {
+	h.onStart = f
+	return h
+}
+
+// SetChatCallback sets the function which is called with every chat message sent
+// by a guest or spectator, once it has been relayed.
+func (h *Match) SetChatCallback(f func(name, text string)) *Match {
+	h.onChat = f
+	return h
+}
+
+// Match returns the match being hosted.
+func (h *Match) Match() *match.Match {
+	return h.match
+}
+
+// Peers returns the connection to each guest. The map mustn't be changed.
+func (h *Match) Peers() map[comms.PlayerID]*Peer {
+	return h.peers
+}
+
+// StartAt returns when the countdown finishes, or zero until it has started.
+func (h *Match) StartAt() time.Time {
+	return h.startAt
+}
+
+// Started returns whether the countdown has finished, so the match can be played.
+func (h *Match) Started() bool {
+	return h.started
+}
+
+// RoundStart returns when the current round started.
+func (h *Match) RoundStart() time.Time {
+	return h.roundStart
+}
+
+// RoundOverAt returns when the current round finished, or zero if it's being
+// played.
+func (h *Match) RoundOverAt() time.Time {
+	return h.roundOverAt
+}
+
+// Standing returns the number of players who haven't left the match.
+func (h *Match) Standing() int {
+	n := 0
+	for _, id := range h.match.Players() {
+		if !h.match.Left(id) {
+			n++
+		}
+	}
+	return n
+}
+
+// PeerFrom returns the guest on a connection, or zero and nil if it isn't a
+// guest's. It's safe to call from any goroutine.
+func (h *Match) PeerFrom(conn int) (comms.PlayerID, *Peer) {
+	h.connMu.Lock()
+	defer h.connMu.Unlock()
+	for id, p := range h.peers {
+		if p.conn == conn {
+			return id, p
+		}
+	}
+	return 0, nil
+}
+
+// Update advances the match: it pings the guests, knocks out anybody who hasn't
+// reconnected in time, counts down to the start and times the rounds.
+func (h *Match) Update() {
+	if time.Since(h.pingedAt) >= comms.PingInterval {
+		h.ping()
+	}
+	h.updateConnections()
+	h.updateCountdown()
+	h.updateRound()
+}
+
+// ping pings every guest, so a lost connection can be noticed and the clocks
+// compared.
+func (h *Match) ping() {
+	h.pingedAt = time.Now()
+	// Pings are expected to fail whilst a connection is lost
+	_ = sendToAll(h.net, comms.PingData{Sent: time.Now()}, everyone)
+}
+
+// updateConnections records when the connection to each guest is lost or restored,
+// and knocks out guests who haven't reconnected within the grace period. Once the
+// match is over, they are still knocked out so nobody waits for them to want a
+// rematch.
+func (h *Match) updateConnections() {
+	for id, p := range h.peers {
+		if h.match.Left(id) {
+			continue
+		}
+
+		if p.Heartbeat.SinceSeen() < comms.HeartbeatTimeout {
+			if !p.DisconnectedAt.IsZero() {
+				h.logf("%s reconnected", h.players[id].Username)
+				p.DisconnectedAt = time.Time{}
+			}
+			continue
+		}
+
+		if p.DisconnectedAt.IsZero() {
+			h.logf("Lost connection to %s", h.players[id].Username)
+			p.DisconnectedAt = time.Now()
+		}
+		if time.Since(p.DisconnectedAt) > comms.ReconnectGrace {
+			h.logf("%s didn't reconnect in time", h.players[id].Username)
+			d, err := h.match.Leave(id)
+			if err != nil {
+				h.logf("Failed to remove player from match: %v", err)
+				continue
+			}
+			h.sendDelta(d)
+		}
+	}
+}
+
+// updateCountdown starts the countdown once every guest has loaded the match and
+// measured the host's clock, then lets the match be played when it finishes.
+func (h *Match) updateCountdown() {
+	switch {
+	case h.started:
+		return
+
+	case !h.startAt.IsZero():
+		if !time.Now().Before(h.startAt) {
+			h.started = true
+			h.roundStart = h.startAt
+			h.match.Start()
+			if h.onStart != nil {
+				h.onStart(h.startAt)
+			}
+		}
+
+	case h.allReady() || time.Since(h.enteredAt) > comms.ReadyTimeout:
+		h.startCountdown()
+	}
+}
+
+// allReady returns whether every guest still in the match is ready for it to
+// start.
+func (h *Match) allReady() bool {
+	for id := range h.peers {
+		if !h.ready[id] && !h.match.Left(id) {
+			return false
+		}
+	}
+	return true
+}
+
+// startCountdown starts the countdown to the start of the match, and tells the
+// guests when it finishes.
+func (h *Match) startCountdown() {
+	h.startAt, h.started = time.Now().Add(comms.CountdownLength), false
+	h.match.WaitToStart()
+
+	if err := sendToAll(h.net, comms.StartData{At: h.startAt}, everyone); err != nil {
+		h.logf("Failed to send start time to guests: %v", err)
+	}
+}
+
+// updateRound ends the round when its time runs out, and starts the next one once
+// the result has been shown for long enough.
+func (h *Match) updateRound() {
+	rules := h.match.Rules()
+	if !h.match.RoundOver() {
+		if h.started && rules.TimeLimit > 0 && time.Since(h.roundStart) >= rules.TimeLimit {
+			d, err := h.match.TimeUp()
+			if err != nil {
+				h.logf("Failed to end round: %v", err)
+				return
+			}
+			h.sendDelta(d)
+		}
+		return
+	}
+
+	if h.roundOverAt.IsZero() {
+		h.roundOverAt = time.Now()
+	}
+	if h.match.Over() || time.Since(h.roundOverAt) < comms.RoundPause {
+		return
+	}
+
+	d, err := h.match.NextRound()
+	if err != nil {
+		h.logf("Failed to start next round: %v", err)
+		return
+	}
+	h.roundStart, h.roundOverAt = time.Now(), time.Time{}
+	h.sendDelta(d)
+}
+
+// Play plays an action on a player's game, then sends the change to everyone.
+func (h *Match) Play(id comms.PlayerID, action comms.Action, dir grid.Direction) error {
+	d, a, err := h.match.Play(id, action, dir)
+	if err != nil {
+		return err
+	}
+	h.sendDelta(d)
+	h.sendAttack(a)
+	return nil
+}
+
+// WantRematch records that a player wants a rematch, and tells everyone else with
+// the event they asked with. The rematch starts once everybody wants one.
+func (h *Match) WantRematch(id comms.PlayerID, event comms.Event) error {
+	if err := h.match.WantRematch(id); err != nil {
+		return err
+	}
+	if err := sendToAll(h.net, comms.EventData{Event: event, Player: id}, everyone); err != nil {
+		h.logf("Failed to send rematch request: %v", err)
+	}
+	h.tryRematch()
+	return nil
+}
+
+// tryRematch starts a rematch if everybody wants one. Everybody is still
+// connected, so the countdown starts straight away.
+func (h *Match) tryRematch() {
+	if !h.match.RematchReady() {
+		return
+	}
+
+	d, err := h.match.Rematch()
+	if err != nil {
+		h.logf("Failed to start rematch: %v", err)
+		return
+	}
+	h.roundStart, h.roundOverAt = time.Now(), time.Time{}
+	h.sendDelta(d)
+	h.startCountdown()
+}
+
+// SendChat sends a message to every guest and spectator whose game supports the
+// chat, with the name of whoever wrote it. It returns the text which was sent,
+// which is empty if there was nothing to send.
+func (h *Match) SendChat(name, text string) (string, error) {
+	text = comms.SanitiseChat(text)
+	if text == "" {
+		return "", nil
+	}
+	has := func(conn int) bool { return h.session(conn).Has(comms.FeatureChat) }
+	return text, sendToAll(h.net, comms.ChatData{Name: name, Text: text}, has)
+}
+
+// Disconnect handles a guest or spectator disconnecting from the host. Guests keep
+// their place until the grace period for reconnecting has passed.
+func (h *Match) Disconnect(conn int) {
+	if s, ok := h.spectators[conn]; ok {
+		h.logf("%s stopped watching", s.name)
+		delete(h.spectators, conn)
+	}
+}
+
+// session returns what was agreed with the game on a connection.
+func (h *Match) session(conn int) comms.Session {
+	if _, p := h.PeerFrom(conn); p != nil {
+		return p.Session
+	}
+	return h.spectators[conn].session
+}
+
+// sendDelta sends a change which has just been made to the match to everyone.
+func (h *Match) sendDelta(d comms.DeltaData) {
+	if err := sendToAll(h.net, d, everyone); err != nil {
+		h.logf("Failed to send game update: %v", err)
+	}
+	if h.onDelta != nil {
+		h.onDelta(d)
+	}
+}
+
+// sendAttack sends an attack which has just been made in the match to everyone, if
+// there was one.
+func (h *Match) sendAttack(a *comms.AttackData) {
+	if a == nil {
+		return
+	}
+	if err := sendToAll(h.net, a, everyone); err != nil {
+		h.logf("Failed to send attack: %v", err)
+	}
+}
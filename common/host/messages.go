@@ -0,0 +1,204 @@
+package host
+
+import (
+	"fmt"
+
+	"github.com/z-riley/go-2048-battle/common/comms"
+)
+
+// HandleHeartbeat records that a connection is still alive, then answers a ping or
+// records a pong. It returns false for any other message, which must be handled
+// with HandleMessage. It's safe to call from any goroutine, so the latency isn't
+// affected by how often the match is updated.
+func (h *Match) HandleHeartbeat(conn int, msg comms.Message) (bool, error) {
+	_, p := h.PeerFrom(conn)
+	if p != nil {
+		p.Heartbeat.Seen()
+	}
+
+	switch msg.Type {
+	case comms.TypePingData:
+		return true, answerPing(h.net, conn, msg)
+
+	case comms.TypePongData:
+		pongData, err := comms.ParsePongData(msg.Content)
+		if err != nil {
+			return true, fmt.Errorf("failed to parse pong data: %w", err)
+		}
+		if p != nil {
+			p.Heartbeat.Pong(pongData)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// HandleMessage handles a message from a guest or spectator, other than pings and
+// pongs.
+func (h *Match) HandleMessage(conn int, msg comms.Message) error {
+	switch msg.Type {
+	case comms.TypePlayerData:
+		playerData, err := comms.ParsePlayerData(msg.Content)
+		if err != nil {
+			return fmt.Errorf("failed to parse player data: %w", err)
+		}
+		return h.handlePlayerData(conn, playerData)
+
+	case comms.TypeInputData:
+		inputData, err := comms.ParseInputData(msg.Content)
+		if err != nil {
+			return fmt.Errorf("failed to parse input data: %w", err)
+		}
+		return h.handleInputData(conn, inputData)
+
+	case comms.TypeEventData:
+		eventData, err := comms.ParseEventData(msg.Content)
+		if err != nil {
+			return fmt.Errorf("failed to parse event data: %w", err)
+		}
+		return h.handleEventData(conn, eventData)
+
+	case comms.TypeRequestData:
+		requestData, err := comms.ParseRequestData(msg.Content)
+		if err != nil {
+			return fmt.Errorf("failed to parse request data: %w", err)
+		}
+		if requestData.Request != comms.TypeStateData {
+			return nil
+		}
+		return send(h.net, conn, h.match.State())
+
+	case comms.TypeChatData:
+		chatData, err := comms.ParseChatData(msg.Content)
+		if err != nil {
+			return fmt.Errorf("failed to parse chat data: %w", err)
+		}
+		return h.handleChatData(conn, chatData)
+
+	default:
+		return fmt.Errorf("unsupported message type \"%s\"", msg.Type)
+	}
+}
+
+// handlePlayerData handles player data during a match. Spectators can start
+// watching, and guests who have lost their connection can rejoin on a new one, but
+// nobody else can join. Nobody can take over the game of a guest who is still
+// connected, or without their token.
+func (h *Match) handlePlayerData(conn int, data comms.PlayerData) error {
+	session, err := negotiate(data.Handshake, h.match.Rules())
+	if err != nil {
+		h.logf("Turned away %s: %v", data.Username, err)
+		return send(h.net, conn, incompatibleEvent(data.Handshake))
+	}
+
+	if data.Spectator {
+		h.spectators[conn] = spectator{name: data.Username, session: session}
+		return h.welcomeSpectator(conn)
+	}
+
+	p, ok := h.peers[data.ID]
+	switch {
+	case !ok || h.match.Left(data.ID):
+		if err := send(h.net, conn, comms.EventData{Event: comms.EventLobbyFull}); err != nil {
+			return fmt.Errorf("failed to turn away client: %w", err)
+		}
+		return fmt.Errorf("%w: player %d isn't in the match", ErrRejoinRefused, data.ID)
+	case p.DisconnectedAt.IsZero():
+		return fmt.Errorf("%w: %s is still connected", ErrRejoinRefused, h.players[data.ID].Username)
+	case data.Token == "" || data.Token != p.token:
+		return fmt.Errorf("%w: wrong token for %s", ErrRejoinRefused, h.players[data.ID].Username)
+	}
+
+	h.connMu.Lock()
+	for _, other := range h.peers {
+		if other.conn == conn {
+			// The connection ID used to belong to somebody else who has since been lost
+			other.conn = -1
+		}
+	}
+	p.conn = conn
+	h.connMu.Unlock()
+
+	p.Session = session
+	p.Heartbeat.Seen()
+	return send(h.net, conn, comms.WelcomeData{ID: data.ID, Token: p.token})
+}
+
+// welcomeSpectator lets somebody start watching after the match has started, by
+// sending them the lobby and telling them that the match has started.
+func (h *Match) welcomeSpectator(conn int) error {
+	msgs := []message{comms.WelcomeData{}}
+	for _, p := range h.lobby {
+		msgs = append(msgs, p)
+	}
+	msgs = append(msgs, comms.EventData{Event: comms.EventHostStartGame})
+
+	for _, msg := range msgs {
+		if err := send(h.net, conn, msg); err != nil {
+			return fmt.Errorf("failed to welcome spectator: %w", err)
+		}
+	}
+	return nil
+}
+
+// handleInputData plays an input from a guest on their game, then sends the change
+// to everyone. An invalid input is rejected without changing the game.
+func (h *Match) handleInputData(conn int, data comms.InputData) error {
+	id, p := h.PeerFrom(conn)
+	if p == nil {
+		return fmt.Errorf("input from unknown connection %d", conn)
+	}
+
+	d, a, err := h.match.PlayInput(id, data)
+	if err != nil {
+		h.logf("Rejected input from %s: %v", h.players[id].Username, err)
+		return nil
+	}
+	h.sendDelta(d)
+	h.sendAttack(a)
+	return nil
+}
+
+// handleEventData handles events from the guests and spectators.
+func (h *Match) handleEventData(conn int, data comms.EventData) error {
+	switch data.Event {
+	case comms.EventScreenLoaded:
+		// Send the starting state to the guest or spectator
+		return send(h.net, conn, h.match.State())
+
+	case comms.EventReady:
+		if id, _ := h.PeerFrom(conn); id != 0 {
+			h.ready[id] = true
+		}
+
+	case comms.EventRematchRequest, comms.EventRematchAccept:
+		id, _ := h.PeerFrom(conn)
+		if id == 0 {
+			return fmt.Errorf("rematch request from unknown connection %d", conn)
+		}
+		if err := h.WantRematch(id, data.Event); err != nil {
+			h.logf("Rejected rematch from %s: %v", h.players[id].Username, err)
+		}
+	}
+
+	return nil
+}
+
+// handleChatData relays a message from a guest or spectator to everyone, with the
+// name of whoever wrote it.
+func (h *Match) handleChatData(conn int, data comms.ChatData) error {
+	var name string
+	if id, p := h.PeerFrom(conn); p != nil {
+		name = h.players[id].Username
+	} else if s, ok := h.spectators[conn]; ok {
+		name = s.name
+	} else {
+		return fmt.Errorf("chat from unknown connection %d", conn)
+	}
+
+	text, err := h.SendChat(name, data.Text)
+	if text != "" && h.onChat != nil {
+		h.onChat(name, text)
+	}
+	return err
+}
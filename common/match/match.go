@@ -0,0 +1,660 @@
+// Package match keeps track of a versus match between two or more players. The host
+// plays every input on its copy of the match and sends the resulting deltas to the
+// guests, who apply them to their own copies. Every player runs the same rules, so
+// they all agree on how each round ends.
+package match
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"math/bits"
+	"slices"
+
+	"github.com/z-riley/go-2048-battle/common/backend"
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+	"github.com/z-riley/go-2048-battle/common/comms"
+)
+
+var (
+	// ErrUnknownPlayer is returned for a player who isn't in the match.
+	ErrUnknownPlayer = errors.New("unknown player")
+	// ErrEliminated is returned for an input from a player who has been knocked out.
+	ErrEliminated = errors.New("player has been eliminated")
+	// ErrOver is returned for an input after the round or match has finished.
+	ErrOver = errors.New("round is over")
+	// ErrNotOver is returned when the next round is started before the last one has
+	// finished, or after the match has finished.
+	ErrNotOver = errors.New("round isn't over")
+	// ErrNotStarted is returned for an input before the countdown to the start of
+	// the match has finished.
+	ErrNotStarted = errors.New("match hasn't started")
+	// ErrNoRematch is returned when a rematch is started before everyone in the
+	// match has asked for one.
+	ErrNoRematch = errors.New("not everyone wants a rematch")
+)
+
+// Match contains the games of every player in a match. In each round, the first
+// player to reach the target tile wins, otherwise players are knocked out when they
+// lose or leave until only one is left standing. The match is won by whoever wins
+// the most rounds.
+type Match struct {
+	ids        []comms.PlayerID
+	games      map[comms.PlayerID]*backend.Game
+	rules      comms.RulesData
+	round      int                    // starts at 1
+	wins       map[comms.PlayerID]int // rounds won by each player before this one
+	incoming   map[comms.PlayerID]int // obstacles waiting to land in each game
+	eliminated []comms.PlayerID       // in the order they were knocked out this round
+	left       map[comms.PlayerID]bool
+	winner     comms.PlayerID          // zero until somebody reaches the target tile
+	timeUp     bool                    // set if the round ran out of time
+	abandoned  bool                    // set if the host left before the match finished
+	waiting    bool                    // set whilst waiting for the match to start
+	series     map[comms.PlayerID]int  // matches won by each player before this one
+	rematch    map[comms.PlayerID]bool // players who want a rematch
+	seq        int                     // sequence number of the last delta
+	inputSeqs  map[comms.PlayerID]int
+}
+
+// New constructs a match between the given players. Every game spawns tiles from
+// the same seed so the match is fair.
+func New(ids []comms.PlayerID, seed uint64) *Match {
+	if seed == 0 {
+		seed = grid.NewSeed()
+	}
+
+	m := &Match{
+		ids:       slices.Sorted(slices.Values(ids)),
+		games:     make(map[comms.PlayerID]*backend.Game, len(ids)),
+		rules:     comms.DefaultRules(),
+		round:     1,
+		wins:      map[comms.PlayerID]int{},
+		incoming:  map[comms.PlayerID]int{},
+		left:      map[comms.PlayerID]bool{},
+		series:    map[comms.PlayerID]int{},
+		rematch:   map[comms.PlayerID]bool{},
+		inputSeqs: map[comms.PlayerID]int{},
+	}
+	for _, id := range m.ids {
+		m.games[id] = backend.NewGame(&backend.Opts{
+			SaveToDisk: false,
+			Seed:       seed,
+		})
+	}
+
+	return m
+}
+
+// Players returns the IDs of every player in the match, in ascending order.
+func (m *Match) Players() []comms.PlayerID {
+	return slices.Clone(m.ids)
+}
+
+// Game returns a player's game, or nil if they aren't in the match.
+func (m *Match) Game(id comms.PlayerID) *backend.Game {
+	return m.games[id]
+}
+
+// Close stops the timer of every game, once the match is no longer needed.
+func (m *Match) Close() {
+	for _, g := range m.games {
+		g.Timer.Stop()
+	}
+}
+
+// SetRules sets the rules of the match. It must be used before the match starts.
+func (m *Match) SetRules(rules comms.RulesData) {
+	m.rules = rules
+}
+
+// Rules returns the rules of the match.
+func (m *Match) Rules() comms.RulesData {
+	return m.rules
+}
+
+// Round returns the number of the current round, starting at 1.
+func (m *Match) Round() int {
+	return m.round
+}
+
+// Wins returns the number of rounds that a player has won, including the current
+// round once it's over.
+func (m *Match) Wins(id comms.PlayerID) int {
+	wins := m.wins[id]
+	if winner, ok := m.roundWinner(); ok && winner == id {
+		wins++
+	}
+	return wins
+}
+
+// Series returns the number of matches that a player has won against the same
+// players, including the current match once it's over.
+func (m *Match) Series(id comms.PlayerID) int {
+	series := m.series[id]
+	if winner, ok := m.matchWinner(); ok && winner == id {
+		series++
+	}
+	return series
+}
+
+// Incoming returns the number of obstacles waiting to land in a player's game.
+func (m *Match) Incoming(id comms.PlayerID) int {
+	return m.incoming[id]
+}
+
+// WaitToStart stops any inputs from being played until Start is called, whilst
+// everybody counts down to the start of the match. It is only used by the host.
+func (m *Match) WaitToStart() {
+	m.waiting = true
+}
+
+// Start lets inputs be played once the countdown to the start of the match has
+// finished.
+func (m *Match) Start() {
+	m.waiting = false
+}
+
+// Play executes an action on a player's game, returning the delta to send to the
+// guests. In comms.ModeAttack, the attack caused by the action is also returned
+// if there is one, to be sent after the delta. It is only used by the host.
+func (m *Match) Play(id comms.PlayerID, action comms.Action, dir grid.Direction) (comms.DeltaData, *comms.AttackData, error) {
+	g, ok := m.games[id]
+	switch {
+	case !ok:
+		return comms.DeltaData{}, nil, fmt.Errorf("%w: %d", ErrUnknownPlayer, id)
+	case m.waiting:
+		return comms.DeltaData{}, nil, ErrNotStarted
+	case m.RoundOver():
+		return comms.DeltaData{}, nil, ErrOver
+	case m.Eliminated(id):
+		return comms.DeltaData{}, nil, fmt.Errorf("%w: %d", ErrEliminated, id)
+	}
+
+	var obstacles []grid.Spawn
+	outgoing := 0
+	switch action {
+	case comms.ActionMove:
+		g.ExecuteMove(dir)
+		if m.rules.Mode == comms.ModeAttack && g.Grid.LastSpawn != nil {
+			// Attacking cancels out obstacles on their way, before the rest land
+			outgoing = attackSize(g.Grid)
+			cancelled := min(outgoing, m.incoming[id])
+			outgoing -= cancelled
+			obstacles = g.Grid.AddObstacles(m.incoming[id] - cancelled)
+			m.incoming[id] = 0
+		}
+	case comms.ActionReset:
+		g.ResetKeepTimer()
+	default:
+		return comms.DeltaData{}, nil, fmt.Errorf("%w: unknown action \"%s\"", comms.ErrInvalidInput, action)
+	}
+	m.updateResult(id)
+
+	m.seq++
+	d := comms.NewDeltaData(m.seq, id, action, dir, g)
+	d.Obstacles = obstacles
+
+	target, ok := m.target(id)
+	if outgoing == 0 || !ok {
+		return d, nil, nil
+	}
+	m.incoming[target] += outgoing
+	m.seq++
+	return d, &comms.AttackData{Seq: m.seq, From: id, To: target, Obstacles: outgoing}, nil
+}
+
+// PlayInput validates an input sent by a guest, then plays it on their game. The
+// guest's inputs carry on from the latest one, even if it was rejected, so one bad
+// input doesn't block the rest.
+func (m *Match) PlayInput(id comms.PlayerID, input comms.InputData) (comms.DeltaData, *comms.AttackData, error) {
+	lastSeq := m.inputSeqs[id]
+	m.inputSeqs[id] = max(lastSeq, input.Seq)
+
+	if err := input.Validate(lastSeq); err != nil {
+		return comms.DeltaData{}, nil, err
+	}
+	return m.Play(id, input.Action, input.Dir)
+}
+
+// Leave knocks out a player who has left the match, returning the delta to send
+// to the guests. It is only used by the host.
+func (m *Match) Leave(id comms.PlayerID) (comms.DeltaData, error) {
+	if _, ok := m.games[id]; !ok {
+		return comms.DeltaData{}, fmt.Errorf("%w: %d", ErrUnknownPlayer, id)
+	}
+	m.leave(id)
+
+	m.seq++
+	return comms.DeltaData{Seq: m.seq, Player: id, Action: comms.ActionLeave}, nil
+}
+
+// TimeUp ends a round which has run out of time, returning the delta to send to the
+// guests. The highest score left standing wins the round. It is only used by the
+// host.
+func (m *Match) TimeUp() (comms.DeltaData, error) {
+	if m.RoundOver() {
+		return comms.DeltaData{}, ErrOver
+	}
+	m.timeUp = true
+
+	m.seq++
+	return comms.DeltaData{Seq: m.seq, Action: comms.ActionTimeUp}, nil
+}
+
+// NextRound starts the next round once the last one has finished, returning the
+// delta to send to the guests. It is only used by the host.
+func (m *Match) NextRound() (comms.DeltaData, error) {
+	if !m.RoundOver() || m.Over() {
+		return comms.DeltaData{}, ErrNotOver
+	}
+	seed := grid.NewSeed()
+	m.nextRound(seed)
+
+	m.seq++
+	return comms.DeltaData{Seq: m.seq, Action: comms.ActionNextRound, Seed: seed}, nil
+}
+
+// WantRematch records that a player wants a rematch, after the match has finished.
+func (m *Match) WantRematch(id comms.PlayerID) error {
+	switch {
+	case m.games[id] == nil:
+		return fmt.Errorf("%w: %d", ErrUnknownPlayer, id)
+	case m.left[id]:
+		return fmt.Errorf("%w: %d has left", ErrEliminated, id)
+	case !m.Over() || m.abandoned:
+		return ErrNotOver
+	}
+	m.rematch[id] = true
+	return nil
+}
+
+// WantsRematch returns whether a player has asked for a rematch.
+func (m *Match) WantsRematch(id comms.PlayerID) bool {
+	return m.rematch[id]
+}
+
+// RematchReady returns whether everybody still in the match wants a rematch.
+func (m *Match) RematchReady() bool {
+	if !m.Over() || m.abandoned || len(m.ids)-len(m.left) < 2 {
+		return false
+	}
+	for _, id := range m.ids {
+		if !m.left[id] && !m.rematch[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// Rematch starts a new match between everybody still in the match, once they all
+// want one, returning the delta to send to the guests. The winner of the match is
+// added to the series. It is only used by the host.
+func (m *Match) Rematch() (comms.DeltaData, error) {
+	if !m.RematchReady() {
+		return comms.DeltaData{}, ErrNoRematch
+	}
+	seed := grid.NewSeed()
+	m.startRematch(seed)
+
+	m.seq++
+	return comms.DeltaData{Seq: m.seq, Action: comms.ActionRematch, Seed: seed}, nil
+}
+
+// Abandon ends the match because the host has left. It is only used by guests,
+// since nobody is left to decide the rest of the match.
+func (m *Match) Abandon() {
+	if _, ok := m.games[comms.HostID]; ok {
+		// A dedicated host isn't playing
+		m.leave(comms.HostID)
+	}
+	m.abandoned = true
+}
+
+// ApplyDelta applies a change decided by the host to a guest's copy of the match.
+// comms.ErrDesync is returned if a delta was missed or had a different result to
+// the host's, in which case the state must be requested from the host.
+func (m *Match) ApplyDelta(d comms.DeltaData) error {
+	if d.Seq != m.seq+1 {
+		return fmt.Errorf("%w: expected delta %d, got %d", comms.ErrDesync, m.seq+1, d.Seq)
+	}
+
+	// Some changes apply to every game
+	switch d.Action {
+	case comms.ActionTimeUp:
+		m.timeUp = true
+		m.seq = d.Seq
+		return nil
+	case comms.ActionNextRound:
+		m.nextRound(d.Seed)
+		m.seq = d.Seq
+		return nil
+	case comms.ActionRematch:
+		m.startRematch(d.Seed)
+		m.seq = d.Seq
+		return nil
+	}
+
+	g, ok := m.games[d.Player]
+	if !ok {
+		return fmt.Errorf("%w: %d", ErrUnknownPlayer, d.Player)
+	}
+
+	if d.Action == comms.ActionLeave {
+		m.leave(d.Player)
+	} else {
+		if err := d.Apply(g); err != nil {
+			return err
+		}
+		if d.Action == comms.ActionMove && g.Grid.LastSpawn != nil {
+			// Obstacles are placed by the game's own random number generator, so it
+			// stays in step with the host's
+			obstacles := g.Grid.AddObstacles(len(d.Obstacles))
+			if !slices.Equal(obstacles, d.Obstacles) {
+				return fmt.Errorf("%w: obstacles landed at %v, host placed %v", comms.ErrDesync, obstacles, d.Obstacles)
+			}
+			m.incoming[d.Player] = 0
+		}
+		m.updateResult(d.Player)
+	}
+	m.seq = d.Seq
+
+	return nil
+}
+
+// ApplyAttack applies an attack decided by the host to a guest's copy of the
+// match. Like deltas, comms.ErrDesync is returned if a change was missed.
+func (m *Match) ApplyAttack(a comms.AttackData) error {
+	if a.Seq != m.seq+1 {
+		return fmt.Errorf("%w: expected delta %d, got attack %d", comms.ErrDesync, m.seq+1, a.Seq)
+	}
+	if _, ok := m.games[a.To]; !ok {
+		return fmt.Errorf("%w: %d", ErrUnknownPlayer, a.To)
+	}
+
+	m.incoming[a.To] += a.Obstacles
+	m.seq = a.Seq
+
+	return nil
+}
+
+// State returns the state of the whole match, for a guest to load.
+func (m *Match) State() comms.StateData {
+	state := comms.StateData{
+		Games:      make(map[comms.PlayerID]backend.Game, len(m.games)),
+		Rules:      m.rules,
+		Round:      m.round,
+		Wins:       maps.Clone(m.wins),
+		Incoming:   maps.Clone(m.incoming),
+		Eliminated: slices.Clone(m.eliminated),
+		Winner:     m.winner,
+		TimeUp:     m.timeUp,
+		Series:     maps.Clone(m.series),
+		Seq:        m.seq,
+	}
+	for id, g := range m.games {
+		state.Games[id] = *g
+	}
+	for _, id := range m.ids {
+		if m.left[id] {
+			state.Left = append(state.Left, id)
+		}
+		if m.rematch[id] {
+			state.Rematch = append(state.Rematch, id)
+		}
+	}
+
+	return state
+}
+
+// Load replaces a guest's copy of the match with the state decided by the host.
+// Every game keeps its own timer and options, so changes can still be applied to
+// it.
+func (m *Match) Load(state comms.StateData) error {
+	for _, id := range m.ids {
+		if _, ok := state.Games[id]; !ok {
+			return fmt.Errorf("state is missing the game of player %d", id)
+		}
+	}
+
+	for id, g := range m.games {
+		s := state.Games[id]
+		g.Grid = s.Grid
+		g.Score = s.Score
+		g.HighScore = s.HighScore
+	}
+	m.rules = state.Rules
+	m.round = state.Round
+	m.wins = map[comms.PlayerID]int{}
+	maps.Copy(m.wins, state.Wins)
+	m.incoming = map[comms.PlayerID]int{}
+	maps.Copy(m.incoming, state.Incoming)
+	m.eliminated = slices.Clone(state.Eliminated)
+	m.left = map[comms.PlayerID]bool{}
+	for _, id := range state.Left {
+		m.left[id] = true
+	}
+	m.winner = state.Winner
+	m.timeUp = state.TimeUp
+	m.series = map[comms.PlayerID]int{}
+	maps.Copy(m.series, state.Series)
+	m.rematch = map[comms.PlayerID]bool{}
+	for _, id := range state.Rematch {
+		m.rematch[id] = true
+	}
+	m.seq = state.Seq
+
+	return nil
+}
+
+// RoundOver returns whether the current round has finished.
+func (m *Match) RoundOver() bool {
+	return m.winner != 0 || m.timeUp || m.abandoned || len(m.ids)-len(m.eliminated) <= 1
+}
+
+// Over returns whether the match has finished.
+func (m *Match) Over() bool {
+	switch {
+	case m.abandoned:
+		return true
+	case !m.RoundOver():
+		return false
+	case m.round >= m.rules.Rounds:
+		return true
+	case len(m.ids)-len(m.left) < 2:
+		// Nobody is left to play another round against
+		return true
+	}
+
+	// Nobody can catch up with a player who has won most of the rounds
+	for _, id := range m.ids {
+		if m.Wins(id) > m.rules.Rounds/2 {
+			return true
+		}
+	}
+	return false
+}
+
+// Abandoned returns whether the match finished because the host left, so nobody
+// won it.
+func (m *Match) Abandoned() bool {
+	return m.abandoned
+}
+
+// Eliminated returns whether a player has been knocked out of the match.
+func (m *Match) Eliminated(id comms.PlayerID) bool {
+	return slices.Contains(m.eliminated, id)
+}
+
+// Left returns whether a player was knocked out by leaving the match.
+func (m *Match) Left(id comms.PlayerID) bool {
+	return m.left[id]
+}
+
+// Ranking returns the players from first to last place in the match, in order of
+// the rounds they've won. Players who have won the same number of rounds are in
+// the order they're placed in the current round.
+func (m *Match) Ranking() []comms.PlayerID {
+	ranking := m.RoundRanking()
+	slices.SortStableFunc(ranking, func(a, b comms.PlayerID) int {
+		return cmp.Compare(m.Wins(b), m.Wins(a))
+	})
+	return ranking
+}
+
+// Rank returns a player's place in the match, starting at 1.
+func (m *Match) Rank(id comms.PlayerID) int {
+	return slices.Index(m.Ranking(), id) + 1
+}
+
+// RoundRanking returns the players from first to last place in the current round.
+// The winner comes first, followed by anyone still standing in order of score,
+// then everyone who was knocked out, latest first.
+func (m *Match) RoundRanking() []comms.PlayerID {
+	ranking := make([]comms.PlayerID, 0, len(m.ids))
+	if m.winner != 0 {
+		ranking = append(ranking, m.winner)
+	}
+
+	var standing []comms.PlayerID
+	for _, id := range m.ids {
+		if id != m.winner && !m.Eliminated(id) {
+			standing = append(standing, id)
+		}
+	}
+	slices.SortStableFunc(standing, func(a, b comms.PlayerID) int {
+		return cmp.Compare(m.games[b].Score, m.games[a].Score)
+	})
+	ranking = append(ranking, standing...)
+
+	for _, id := range slices.Backward(m.eliminated) {
+		if id != m.winner {
+			ranking = append(ranking, id)
+		}
+	}
+
+	return ranking
+}
+
+// RoundRank returns a player's place in the current round, starting at 1.
+func (m *Match) RoundRank(id comms.PlayerID) int {
+	return slices.Index(m.RoundRanking(), id) + 1
+}
+
+// roundWinner returns the winner of the current round. ok is false if the round
+// isn't over, or nobody won it.
+func (m *Match) roundWinner() (winner comms.PlayerID, ok bool) {
+	if !m.RoundOver() || m.abandoned {
+		return 0, false
+	}
+	return m.RoundRanking()[0], true
+}
+
+// matchWinner returns the winner of the match. ok is false if the match isn't
+// over, or was abandoned.
+func (m *Match) matchWinner() (winner comms.PlayerID, ok bool) {
+	if !m.Over() || m.abandoned {
+		return 0, false
+	}
+	return m.Ranking()[0], true
+}
+
+// nextRound starts a new game for every player with the given seed. Players who
+// have left stay knocked out.
+func (m *Match) nextRound(seed uint64) {
+	if winner, ok := m.roundWinner(); ok {
+		m.wins[winner]++
+	}
+
+	for _, g := range m.games {
+		g.ResetKeepTimerWithSeed(seed)
+	}
+	m.resetRound()
+	m.round++
+}
+
+// startRematch starts a new match with the given seed, keeping the series score.
+// Players who have left stay knocked out. Every game timer starts again from
+// zero, paused.
+func (m *Match) startRematch(seed uint64) {
+	if winner, ok := m.matchWinner(); ok {
+		m.series[winner]++
+	}
+
+	for _, g := range m.games {
+		g.ResetWithSeed(seed)
+	}
+	m.resetRound()
+	clear(m.wins)
+	clear(m.rematch)
+	m.round = 1
+}
+
+// resetRound clears the result of the current round, once every game has been
+// reset.
+func (m *Match) resetRound() {
+	m.eliminated = slices.DeleteFunc(m.eliminated, func(id comms.PlayerID) bool {
+		return !m.left[id]
+	})
+	for _, id := range m.ids {
+		if m.left[id] && !m.Eliminated(id) {
+			m.eliminated = append(m.eliminated, id)
+		}
+	}
+	clear(m.incoming)
+	m.winner = 0
+	m.timeUp = false
+}
+
+// updateResult checks whether a player has won or been knocked out, after a change
+// to their game.
+func (m *Match) updateResult(id comms.PlayerID) {
+	g := m.games[id].Grid
+	switch {
+	case g.Outcome() == grid.Lose:
+		if !m.Eliminated(id) {
+			m.eliminated = append(m.eliminated, id)
+		}
+	case g.HighestTile() >= m.rules.Target:
+		if m.winner == 0 {
+			m.winner = id
+		}
+	}
+}
+
+// target returns the player who is attacked by a player's merges: the next one
+// still standing, in order of ID. ok is false if nobody is left to attack.
+func (m *Match) target(id comms.PlayerID) (target comms.PlayerID, ok bool) {
+	i := slices.Index(m.ids, id)
+	for n := 1; n < len(m.ids); n++ {
+		target = m.ids[(i+n)%len(m.ids)]
+		if !m.Eliminated(target) {
+			return target, !m.RoundOver()
+		}
+	}
+	return 0, false
+}
+
+// attackSize returns the number of obstacles sent by the merges in the last move.
+// Merging into a 64 sends one, and each bigger tile sends one more.
+func attackSize(g *grid.Grid) int {
+	n := 0
+	for y := range g.Tiles {
+		for x := range g.Tiles[y] {
+			if t := g.Tiles[y][x]; t.Cmb && t.Val >= 64 {
+				n += bits.Len(uint(t.Val)) - 6
+			}
+		}
+	}
+	return n
+}
+
+// leave knocks out a player who has left. Somebody who leaves after the round has
+// finished keeps their place in it, and is knocked out of the next one.
+func (m *Match) leave(id comms.PlayerID) {
+	m.left[id] = true
+	if !m.Eliminated(id) && !m.RoundOver() {
+		m.eliminated = append(m.eliminated, id)
+	}
+}
@@ -0,0 +1,472 @@
+package match
+
+import (
+	"errors"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+	"github.com/z-riley/go-2048-battle/common/comms"
+)
+
+func TestRanking(t *testing.T) {
+	type tc struct {
+		name    string
+		players []comms.PlayerID
+		scores  map[comms.PlayerID]int
+		play    func(m *Match)
+		over    bool
+		want    []comms.PlayerID
+	}
+
+	for _, tc := range []tc{
+		{
+			name:    "in progress",
+			players: []comms.PlayerID{1, 2, 3},
+			scores:  map[comms.PlayerID]int{1: 100, 2: 300, 3: 200},
+			play: func(m *Match) {
+				lose(m, 1)
+			},
+			over: false,
+			want: []comms.PlayerID{2, 3, 1},
+		},
+		{
+			name:    "last standing",
+			players: []comms.PlayerID{1, 2, 3},
+			scores:  map[comms.PlayerID]int{1: 100, 2: 300, 3: 200},
+			play: func(m *Match) {
+				lose(m, 2)
+				lose(m, 3)
+			},
+			over: true,
+			want: []comms.PlayerID{1, 3, 2},
+		},
+		{
+			name:    "first to 2048",
+			players: []comms.PlayerID{1, 2, 3, 4},
+			scores:  map[comms.PlayerID]int{1: 500, 2: 100, 3: 900, 4: 700},
+			play: func(m *Match) {
+				lose(m, 3)
+				win(m, 2)
+			},
+			over: true,
+			want: []comms.PlayerID{2, 4, 1, 3},
+		},
+		{
+			name:    "opponent left",
+			players: []comms.PlayerID{1, 2},
+			scores:  map[comms.PlayerID]int{1: 0, 2: 800},
+			play: func(m *Match) {
+				if _, err := m.Leave(2); err != nil {
+					t.Fatal(err)
+				}
+			},
+			over: true,
+			want: []comms.PlayerID{1, 2},
+		},
+		{
+			name:    "left after the end",
+			players: []comms.PlayerID{1, 2, 3},
+			scores:  map[comms.PlayerID]int{1: 100, 2: 300, 3: 200},
+			play: func(m *Match) {
+				win(m, 1)
+				if _, err := m.Leave(2); err != nil {
+					t.Fatal(err)
+				}
+			},
+			over: true,
+			want: []comms.PlayerID{1, 2, 3},
+		},
+		{
+			name:    "host left",
+			players: []comms.PlayerID{1, 2, 3},
+			scores:  map[comms.PlayerID]int{1: 900, 2: 100, 3: 200},
+			play: func(m *Match) {
+				m.Abandon()
+			},
+			over: true,
+			want: []comms.PlayerID{3, 2, 1},
+		},
+		{
+			name:    "dedicated host left",
+			players: []comms.PlayerID{2, 3},
+			scores:  map[comms.PlayerID]int{2: 100, 3: 200},
+			play: func(m *Match) {
+				m.Abandon()
+			},
+			over: true,
+			want: []comms.PlayerID{3, 2},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := New(tc.players, 0)
+			for id, score := range tc.scores {
+				m.Game(id).Score = score
+			}
+			tc.play(m)
+
+			if m.Over() != tc.over {
+				t.Errorf("Expected:\n<%v>\nGot:\n<%v>", tc.over, m.Over())
+			}
+			if got := m.Ranking(); !slices.Equal(got, tc.want) {
+				t.Errorf("Expected:\n<%v>\nGot:\n<%v>", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestRounds(t *testing.T) {
+	players := []comms.PlayerID{comms.HostID, 2, 3}
+	host := New(players, 99)
+	host.SetRules(comms.RulesData{Mode: comms.ModeRace, Target: 256, TimeLimit: time.Minute, Rounds: 3})
+	guest := New(players, 1)
+	if err := guest.Load(roundTrip(t, host.State())); err != nil {
+		t.Fatal(err)
+	}
+
+	// apply applies a change made by the host to the guest
+	apply := func(d comms.DeltaData, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := guest.ApplyDelta(d); err != nil {
+			t.Fatalf("Failed to apply delta: %v", err)
+		}
+	}
+
+	// Reaching the target tile wins the first round, but not the match
+	host.Game(2).Grid.Tiles[0][0].Val = 256
+	host.updateResult(2)
+	if !host.RoundOver() || host.Over() || host.Wins(2) != 1 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 1, host.Wins(2))
+	}
+	if _, _, err := host.Play(3, comms.ActionMove, grid.DirUp); !errors.Is(err, ErrOver) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", ErrOver, err)
+	}
+	if err := guest.Load(roundTrip(t, host.State())); err != nil {
+		t.Fatal(err)
+	}
+
+	// The highest score wins when a round runs out of time
+	apply(host.NextRound())
+	if guest.Round() != 2 || guest.RoundOver() || !equalValues(host.Game(2).Grid, guest.Game(2).Grid) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", host.Game(2).Grid.Debug(), guest.Game(2).Grid.Debug())
+	}
+	for _, m := range []*Match{host, guest} {
+		m.Game(2).Score, m.Game(3).Score = 100, 200
+	}
+	apply(host.TimeUp())
+	if !guest.RoundOver() || guest.Over() || guest.Wins(3) != 1 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 1, guest.Wins(3))
+	}
+
+	// Winning most of the rounds wins the match
+	apply(host.NextRound())
+	for _, m := range []*Match{host, guest} {
+		m.Game(2).Grid.Tiles[0][0].Val = 512
+		m.updateResult(2)
+	}
+	want := []comms.PlayerID{2, 3, comms.HostID}
+	for _, m := range []*Match{host, guest} {
+		if !m.Over() || !slices.Equal(m.Ranking(), want) {
+			t.Errorf("Expected:\n<%v>\nGot:\n<%v>", want, m.Ranking())
+		}
+	}
+	if _, err := host.NextRound(); !errors.Is(err, ErrNotOver) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", ErrNotOver, err)
+	}
+}
+
+func TestRematch(t *testing.T) {
+	players := []comms.PlayerID{comms.HostID, 2, 3}
+	host := New(players, 5)
+	guest := New(players, 1)
+	if err := guest.Load(roundTrip(t, host.State())); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := host.WantRematch(2); !errors.Is(err, ErrNotOver) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", ErrNotOver, err)
+	}
+
+	// Player 3 leaves, then player 2 wins the match
+	d, err := host.Leave(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := guest.ApplyDelta(d); err != nil {
+		t.Fatalf("Failed to apply delta: %v", err)
+	}
+	for _, m := range []*Match{host, guest} {
+		m.Game(2).Grid.Tiles[0][0].Val = 2048
+		m.updateResult(2)
+	}
+	if !host.Over() || host.Series(2) != 1 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 1, host.Series(2))
+	}
+
+	// Everybody who is still in the match must want a rematch
+	if err := host.WantRematch(3); !errors.Is(err, ErrEliminated) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", ErrEliminated, err)
+	}
+	if err := host.WantRematch(2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := host.Rematch(); !errors.Is(err, ErrNoRematch) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", ErrNoRematch, err)
+	}
+	if err := host.WantRematch(comms.HostID); err != nil {
+		t.Fatal(err)
+	}
+	if err := guest.Load(roundTrip(t, host.State())); err != nil {
+		t.Fatal(err)
+	}
+	if !guest.WantsRematch(2) || !guest.RematchReady() {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", true, guest.RematchReady())
+	}
+
+	d, err = host.Rematch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := guest.ApplyDelta(d); err != nil {
+		t.Fatalf("Failed to apply delta: %v", err)
+	}
+	for _, m := range []*Match{host, guest} {
+		switch {
+		case m.Over() || m.Round() != 1 || m.Wins(2) != 0:
+			t.Errorf("Expected:\n<%v>\nGot:\n<%v>", false, m.Over())
+		case m.Series(2) != 1 || m.Series(comms.HostID) != 0:
+			t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 1, m.Series(2))
+		case !m.Left(3) || m.Eliminated(2) || m.WantsRematch(2):
+			t.Errorf("Expected:\n<%v>\nGot:\n<%v>", []comms.PlayerID{3}, m.eliminated)
+		}
+	}
+	if !equalValues(host.Game(2).Grid, guest.Game(2).Grid) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", host.Game(2).Grid.Debug(), guest.Game(2).Grid.Debug())
+	}
+}
+
+func TestWaitToStart(t *testing.T) {
+	m := New([]comms.PlayerID{comms.HostID, 2}, 0)
+	m.WaitToStart()
+
+	// Early inputs are rejected, without holding up the ones after the start
+	if _, _, err := m.PlayInput(2, comms.InputData{Seq: 1, Action: comms.ActionMove, Dir: grid.DirUp}); !errors.Is(err, ErrNotStarted) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", ErrNotStarted, err)
+	}
+	m.Start()
+	if _, _, err := m.PlayInput(2, comms.InputData{Seq: 2, Action: comms.ActionReset}); err != nil {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", nil, err)
+	}
+}
+
+func TestSync(t *testing.T) {
+	players := []comms.PlayerID{comms.HostID, 2, 3}
+	host := New(players, 99)
+	guest := New(players, 1)
+	if err := guest.Load(roundTrip(t, host.State())); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every change made by the host can be applied by a guest
+	var deltas []comms.DeltaData
+	play := func(d comms.DeltaData, _ *comms.AttackData, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("Failed to play: %v", err)
+		}
+		deltas = append(deltas, d)
+	}
+	play(host.Play(comms.HostID, comms.ActionMove, grid.DirLeft))
+	play(host.PlayInput(2, comms.InputData{Seq: 1, Action: comms.ActionMove, Dir: grid.DirUp}))
+	play(host.PlayInput(3, comms.InputData{Seq: 1, Action: comms.ActionMove, Dir: grid.DirDown}))
+	play(host.PlayInput(2, comms.InputData{Seq: 2, Action: comms.ActionReset}))
+	play(host.Play(comms.HostID, comms.ActionMove, grid.DirRight))
+	d, err := host.Leave(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deltas = append(deltas, d)
+
+	for _, d := range deltas {
+		if err := guest.ApplyDelta(d); err != nil {
+			t.Fatalf("Failed to apply delta: %v", err)
+		}
+	}
+	for _, id := range players {
+		if !equalValues(host.Game(id).Grid, guest.Game(id).Grid) || host.Game(id).Score != guest.Game(id).Score {
+			t.Errorf("Expected:\n<%v>\nGot:\n<%v>", host.Game(id).Grid.Debug(), guest.Game(id).Grid.Debug())
+		}
+	}
+	if !guest.Left(3) || !guest.Eliminated(3) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", true, guest.Left(3))
+	}
+
+	// Inputs are rejected without changing the match
+	if _, _, err := host.PlayInput(2, comms.InputData{Seq: 2, Action: comms.ActionMove, Dir: grid.DirUp}); !errors.Is(err, comms.ErrInputOutOfOrder) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", comms.ErrInputOutOfOrder, err)
+	}
+	if _, _, err := host.PlayInput(3, comms.InputData{Seq: 2, Action: comms.ActionMove, Dir: grid.DirUp}); !errors.Is(err, ErrEliminated) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", ErrEliminated, err)
+	}
+	if _, _, err := host.Play(4, comms.ActionMove, grid.DirUp); !errors.Is(err, ErrUnknownPlayer) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", ErrUnknownPlayer, err)
+	}
+
+	// A missed delta must be resynchronised
+	d, _, err = host.Play(comms.HostID, comms.ActionMove, grid.DirDown)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Seq++
+	if err := guest.ApplyDelta(d); !errors.Is(err, comms.ErrDesync) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", comms.ErrDesync, err)
+	}
+
+	// A guest can catch up by loading the whole state
+	resynced := New(players, 1)
+	if err := resynced.Load(roundTrip(t, host.State())); err != nil {
+		t.Fatal(err)
+	}
+	if !equalValues(host.Game(comms.HostID).Grid, resynced.Game(comms.HostID).Grid) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", host.Game(comms.HostID).Grid.Debug(), resynced.Game(comms.HostID).Grid.Debug())
+	}
+	if !resynced.Left(3) || !slices.Equal(resynced.Ranking(), host.Ranking()) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", host.Ranking(), resynced.Ranking())
+	}
+}
+
+func TestClose(t *testing.T) {
+	before := runtime.NumGoroutine()
+	m := New([]comms.PlayerID{1, 2, 3, 4}, 0)
+
+	// Every game's timer stops counting
+	m.Close()
+	for deadline := time.Now().Add(time.Second); runtime.NumGoroutine() > before && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", before, n)
+	}
+}
+
+func TestAttack(t *testing.T) {
+	players := []comms.PlayerID{comms.HostID, 2}
+	host := New(players, 99)
+	host.SetRules(comms.RulesData{Mode: comms.ModeAttack, Target: 2048, Rounds: 1})
+	guest := New(players, 1)
+
+	// Merging two 128s makes a 256, which sends three obstacles
+	merge128s(host.Game(comms.HostID).Grid)
+	if err := guest.Load(roundTrip(t, host.State())); err != nil {
+		t.Fatal(err)
+	}
+	d, a, err := host.Play(comms.HostID, comms.ActionMove, grid.DirLeft)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == nil || a.To != 2 || a.Obstacles != 3 {
+		t.Fatalf("Expected:\n<%v>\nGot:\n<%v>", comms.AttackData{Seq: 2, From: comms.HostID, To: 2, Obstacles: 3}, a)
+	}
+	if err := guest.ApplyDelta(d); err != nil {
+		t.Fatalf("Failed to apply delta: %v", err)
+	}
+	if err := guest.ApplyAttack(*a); err != nil {
+		t.Fatalf("Failed to apply attack: %v", err)
+	}
+	if guest.Incoming(2) != 3 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 3, guest.Incoming(2))
+	}
+
+	// The obstacles land after the target's next move, in the same place for everyone
+	for _, dir := range []grid.Direction{grid.DirUp, grid.DirLeft, grid.DirDown, grid.DirRight} {
+		d, _, err := host.Play(2, comms.ActionMove, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := guest.ApplyDelta(d); err != nil {
+			t.Fatalf("Failed to apply delta: %v", err)
+		}
+		if d.Spawn != nil {
+			break
+		}
+	}
+	if got := host.Game(2).Grid.NumObstacles(); got != 3 || host.Incoming(2) != 0 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 3, got)
+	}
+	if !equalValues(host.Game(2).Grid, guest.Game(2).Grid) || guest.Incoming(2) != 0 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", host.Game(2).Grid.Debug(), guest.Game(2).Grid.Debug())
+	}
+
+	// Nothing is sent in a race
+	race := New(players, 99)
+	merge128s(race.Game(comms.HostID).Grid)
+	if _, a, _ := race.Play(comms.HostID, comms.ActionMove, grid.DirLeft); a != nil {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", nil, a)
+	}
+}
+
+// roundTrip sends a state through its serialised form.
+func roundTrip(t *testing.T, state comms.StateData) comms.StateData {
+	t.Helper()
+	b, err := state.Serialise()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := comms.ParseMessage(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := comms.ParseStateData(msg.Content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+// merge128s clears a grid apart from two 128s, which merge when moved left.
+func merge128s(g *grid.Grid) {
+	for y := range g.Tiles {
+		for x := range g.Tiles[y] {
+			g.Tiles[y][x].Val = 0
+		}
+	}
+	g.Tiles[0][2].Val, g.Tiles[0][3].Val = 128, 128
+}
+
+// lose makes a player lose their game.
+func lose(m *Match, id comms.PlayerID) {
+	tiles := m.Game(id).Grid.Tiles
+	for y := range tiles {
+		for x := range tiles[y] {
+			// Alternating tiles can never be combined
+			tiles[y][x].Val = 2 << ((x + y) % 2)
+		}
+	}
+	m.updateResult(id)
+}
+
+// win makes a player win their game.
+func win(m *Match, id comms.PlayerID) {
+	m.Game(id).Grid.Tiles[0][0].Val = 2048
+	m.updateResult(id)
+}
+
+// equalValues returns whether two grids have the same tile values.
+func equalValues(a, b *grid.Grid) bool {
+	if a.Width() != b.Width() || a.Height() != b.Height() {
+		return false
+	}
+	for y := range a.Tiles {
+		for x := range a.Tiles[y] {
+			if a.Tiles[y][x].Val != b.Tiles[y][x].Val {
+				return false
+			}
+		}
+	}
+	return true
+}
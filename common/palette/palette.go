@@ -0,0 +1,87 @@
+// Package palette contains the colours of the grid and its tiles. It has no
+// graphics dependencies, so every frontend can share it.
+package palette
+
+import (
+	"image/color"
+
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+)
+
+// Grid colours.
+var (
+	GreyText        = rgb(120, 110, 100) // official colour
+	WhiteText       = rgb(255, 255, 255) // official colour
+	TileBackground  = rgb(204, 192, 180) // official colour
+	ArenaBackground = rgb(187, 173, 160) // official colour
+	UnknownTile     = rgb(255, 0, 0)     // for tiles without a colour of their own
+	Obstacle        = rgb(94, 86, 78)
+)
+
+// Tile colours.
+var (
+	Tile2    = rgb(239, 229, 218) // official colour
+	Tile4    = rgb(236, 224, 198) // official colour
+	Tile8    = rgb(242, 176, 121) // official colour
+	Tile16   = rgb(235, 140, 83)  // official colour
+	Tile32   = rgb(245, 123, 93)  // official colour
+	Tile64   = rgb(233, 89, 55)   // official colour
+	Tile128  = rgb(242, 217, 107) // official colour
+	Tile256  = rgb(241, 208, 76)  // official colour
+	Tile512  = rgb(229, 192, 43)  // official colour
+	Tile1024 = rgb(224, 192, 65)
+	Tile2048 = rgb(235, 196, 2) // official colour
+	Tile4096 = rgb(255, 59, 59)
+	Tile8192 = rgb(255, 32, 33)
+)
+
+// Tile returns the colour for a tile of a given value.
+func Tile(val int) color.RGBA {
+	switch val {
+	case grid.Obstacle:
+		return Obstacle
+	case 2:
+		return Tile2
+	case 4:
+		return Tile4
+	case 8:
+		return Tile8
+	case 16:
+		return Tile16
+	case 32:
+		return Tile32
+	case 64:
+		return Tile64
+	case 128:
+		return Tile128
+	case 256:
+		return Tile256
+	case 512:
+		return Tile512
+	case 1024:
+		return Tile1024
+	case 2048:
+		return Tile2048
+	case 4096:
+		return Tile4096
+	case 8192:
+		return Tile8192
+	default:
+		return UnknownTile
+	}
+}
+
+// TileText returns the colour of the text for tile of a given value.
+func TileText(val int) color.RGBA {
+	switch val {
+	case 2, 4:
+		return GreyText
+	default:
+		return WhiteText
+	}
+}
+
+// rgb returns an opaque colour.
+func rgb(r, g, b uint8) color.RGBA {
+	return color.RGBA{r, g, b, 255}
+}
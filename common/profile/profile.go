@@ -0,0 +1,162 @@
+// Package profile keeps the player's identity between versus matches: a stable ID,
+// the name they chose, their rating and the results of the matches they've played.
+package profile
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/z-riley/go-2048-battle/common/backend/store"
+)
+
+const (
+	// DefaultRating is the rating of a player who hasn't finished a match against
+	// anybody rated.
+	DefaultRating = 1200
+
+	// kFactor is the most that a rating can change by after one match.
+	kFactor = 32
+
+	// maxHistory is the number of results kept in a profile. Older ones are
+	// forgotten, but still count towards the rating.
+	maxHistory = 100
+)
+
+// Profile is the player's identity in versus mode, and how they've done in it.
+type Profile struct {
+	ID      string   `json:"id"` // stays the same when the name changes
+	Name    string   `json:"name"`
+	Rating  int      `json:"rating"`
+	History []Result `json:"history"` // oldest first
+}
+
+// Outcome is how a match finished for the player.
+type Outcome string
+
+const (
+	OutcomeWin  Outcome = "win"
+	OutcomeLoss Outcome = "loss"
+	OutcomeLeft Outcome = "left" // the player left before the end, which counts as last place
+)
+
+// Result is the record of a finished match.
+type Result struct {
+	At        time.Time     `json:"at"` // when the match finished
+	Opponents []Opponent    `json:"opponents"`
+	Score     int           `json:"score"` // the player's score in the last round
+	Rank      int           `json:"rank"`  // the player's place, starting at 1
+	Duration  time.Duration `json:"duration"`
+	Outcome   Outcome       `json:"outcome"`
+	Change    int           `json:"change"` // how much the rating changed by
+}
+
+// Opponent is somebody who the player played against.
+type Opponent struct {
+	ID     string `json:"id,omitempty"` // empty for a bot
+	Name   string `json:"name"`
+	Rating int    `json:"rating,omitempty"` // before the match, or zero if they're unrated
+	Rank   int    `json:"rank"`
+}
+
+// Rated returns whether the result of a match against the opponent changes the
+// player's rating.
+func (o Opponent) Rated() bool {
+	return o.ID != "" && o.Rating > 0
+}
+
+// New constructs a profile with a new ID and the default rating.
+func New(name string) *Profile {
+	return &Profile{
+		ID:      uuid.NewString(),
+		Name:    name,
+		Rating:  DefaultRating,
+		History: []Result{},
+	}
+}
+
+// Record adds the result of a match to the profile, and updates the rating. The
+// result is returned with the change in rating filled in.
+func (p *Profile) Record(r Result) Result {
+	r.Change = RatingChange(p.Rating, r.Rank, r.Opponents)
+	p.Rating += r.Change
+
+	p.History = append(p.History, r)
+	if len(p.History) > maxHistory {
+		p.History = p.History[len(p.History)-maxHistory:]
+	}
+	return r
+}
+
+// RatingChange returns how much the rating of a player who finished in a place
+// changes by after a match, in the Elo style. A match between several players
+// counts as a match against each of them, won by whoever placed higher, and the
+// changes are averaged so that a match against three opponents doesn't count three
+// times as much.
+func RatingChange(rating, rank int, opponents []Opponent) int {
+	var change float64
+	rated := 0
+	for _, o := range opponents {
+		if !o.Rated() {
+			continue
+		}
+		rated++
+
+		actual := 0.5
+		switch {
+		case rank < o.Rank:
+			actual = 1
+		case rank > o.Rank:
+			actual = 0
+		}
+		change += kFactor * (actual - expectedScore(rating, o.Rating))
+	}
+	if rated == 0 {
+		return 0
+	}
+	return int(math.Round(change / float64(rated)))
+}
+
+// expectedScore returns the chance of a player with a rating beating an opponent
+// with another rating, counting a draw as half a win.
+func expectedScore(rating, opponent int) float64 {
+	return 1 / (1 + math.Pow(10, float64(opponent-rating)/400))
+}
+
+// Serialise converts the profile into JSON.
+func (p *Profile) Serialise() ([]byte, error) {
+	return json.Marshal(p)
+}
+
+// Deserialise loads the JSON representation into memory.
+func (p *Profile) Deserialise(j []byte) error {
+	return json.Unmarshal(j, &p)
+}
+
+// Save saves the profile to a store.
+func (p *Profile) Save(s *store.Store) error {
+	j, err := p.Serialise()
+	if err != nil {
+		return err
+	}
+	return s.SaveBytes(j)
+}
+
+// Load loads a profile from a store.
+func Load(s *store.Store) (*Profile, error) {
+	b, err := s.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	p := &Profile{}
+	if err := p.Deserialise(b); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// NewStore returns the store which the player's profile is saved to.
+func NewStore() *store.Store {
+	return store.NewStore(".profile.bruh")
+}
@@ -0,0 +1,127 @@
+package profile
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/z-riley/go-2048-battle/common/backend/store"
+)
+
+func TestRatingChange(t *testing.T) {
+	type tc struct {
+		name      string
+		rating    int
+		rank      int
+		opponents []Opponent
+		want      int
+	}
+
+	for _, tc := range []tc{
+		{
+			name:      "win against equal",
+			rating:    1200,
+			rank:      1,
+			opponents: []Opponent{{ID: "a", Rating: 1200, Rank: 2}},
+			want:      16,
+		},
+		{
+			name:      "loss against equal",
+			rating:    1200,
+			rank:      2,
+			opponents: []Opponent{{ID: "a", Rating: 1200, Rank: 1}},
+			want:      -16,
+		},
+		{
+			name:      "win against stronger",
+			rating:    1200,
+			rank:      1,
+			opponents: []Opponent{{ID: "a", Rating: 1600, Rank: 2}},
+			want:      29,
+		},
+		{
+			name:      "loss against weaker",
+			rating:    1600,
+			rank:      2,
+			opponents: []Opponent{{ID: "a", Rating: 1200, Rank: 1}},
+			want:      -29,
+		},
+		{
+			name:   "second of three",
+			rating: 1200,
+			rank:   2,
+			opponents: []Opponent{
+				{ID: "a", Rating: 1200, Rank: 1},
+				{ID: "b", Rating: 1200, Rank: 3},
+			},
+			want: 0,
+		},
+		{
+			name:   "bots are unrated",
+			rating: 1200,
+			rank:   1,
+			opponents: []Opponent{
+				{Name: "Hard bot", Rank: 2},
+			},
+			want: 0,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := RatingChange(tc.rating, tc.rank, tc.opponents)
+			if got != tc.want {
+				t.Errorf("Expected:\n<%v>\nGot:\n<%v>", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestRecord(t *testing.T) {
+	p := New("player")
+	opponent := []Opponent{{ID: "a", Name: "opponent", Rating: DefaultRating, Rank: 2}}
+
+	r := p.Record(Result{Opponents: opponent, Rank: 1, Outcome: OutcomeWin})
+	if want := DefaultRating + r.Change; p.Rating != want || r.Change <= 0 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", want, p.Rating)
+	}
+
+	// Only the latest results are kept
+	for range maxHistory {
+		p.Record(Result{Opponents: opponent, Rank: 2, Outcome: OutcomeLoss})
+	}
+	if len(p.History) != maxHistory {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", maxHistory, len(p.History))
+	}
+	if p.History[0].Outcome != OutcomeLoss {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", OutcomeLoss, p.History[0].Outcome)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	const filename = ".test_profile.bruh"
+	s := store.NewStore(filename)
+	defer func() {
+		_ = os.Remove(filename)
+	}()
+
+	p := New("player")
+	p.Record(Result{
+		At:        time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Opponents: []Opponent{{ID: "a", Name: "opponent", Rating: 1300, Rank: 1}},
+		Score:     2048,
+		Rank:      2,
+		Duration:  time.Minute,
+		Outcome:   OutcomeLoss,
+	})
+	if err := p.Save(s); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Load(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p, got) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", p, got)
+	}
+}
@@ -2,14 +2,16 @@ package common
 
 import (
 	"image/color"
+	"time"
 
 	"github.com/z-riley/gogl"
 )
 
 // Entrybox is an interactive text box for data entry.
 type EntryBox struct {
-	TextBox *gogl.TextBox
-	bloom   *gogl.CurvedRect
+	TextBox    *gogl.TextBox
+	bloom      *gogl.CurvedRect
+	deselected func() // restores the unselected style
 }
 
 // NewEntryBox constructs a new text box with suitable defaults.
@@ -30,15 +32,16 @@ func NewEntryBox(width, height float64, pos gogl.Vec, txt string) *EntryBox {
 	bloom := gogl.NewCurvedRect(width, height, 6, pos).SetStyle(styleUnselected)
 
 	tb := NewTextBox(width, height, pos, txt).SetTextAlignment(gogl.AlignCentre)
+	deselected := func() {
+		tb.SetTextColour(LightGreyTextColour)
+		bloom.SetStyle(styleUnselected)
+	}
 	tb.SetSelectedCB(func() {
 		tb.SetTextColour(gogl.White)
 		bloom.SetStyle(styleSelected)
-	}).SetDeselectedCB(func() {
-		tb.SetTextColour(LightGreyTextColour)
-		bloom.SetStyle(styleUnselected)
-	})
+	}).SetDeselectedCB(deselected)
 
-	return &EntryBox{tb, bloom}
+	return &EntryBox{tb, bloom, deselected}
 }
 
 // Draw draws an entry box to the frame buffer.
@@ -63,6 +66,12 @@ func (e *EntryBox) SetText(text string) *EntryBox {
 	return e
 }
 
+// Deselect stops editing the entry box, as if somewhere else had been clicked.
+func (e *EntryBox) Deselect() {
+	e.TextBox.SetEditing(false)
+	e.deselected()
+}
+
 // SetModifiedCB sets a callback which is executed when the text in the entry
 // box is modified.
 func (e *EntryBox) SetModifiedCB(callback func()) *EntryBox {
@@ -161,3 +170,54 @@ func NewTooltip() *gogl.TextBox {
 		SetTextSize(16).
 		SetTextColour(LightGreyTextColour)
 }
+
+// toastDuration is how long a toast shows each notification for.
+const toastDuration = 3 * time.Second
+
+// Toast is a text box which shows notifications for a few seconds each, one after
+// the other.
+type Toast struct {
+	box     *gogl.TextBox
+	queue   []string // the notifications still to show, starting with the current one
+	shownAt time.Time
+}
+
+// NewToast constructs a new toast with no notifications.
+func NewToast(width, height float64, pos gogl.Vec) *Toast {
+	r := gogl.NewCurvedRect(width, height, 6, pos).
+		SetStyle(gogl.Style{Colour: Tile2048Colour})
+
+	box := gogl.NewTextBox(r, "", FontPathBold).
+		SetTextSize(20).
+		SetTextColour(WhiteFontColour)
+
+	return &Toast{box: box}
+}
+
+// Show queues a notification to be shown after the ones before it.
+func (t *Toast) Show(text string) *Toast {
+	t.queue = append(t.queue, text)
+	return t
+}
+
+// Update moves on to the next notification once the current one has been shown
+// for long enough.
+func (t *Toast) Update() {
+	if len(t.queue) == 0 {
+		return
+	}
+	if t.shownAt.IsZero() {
+		t.shownAt = time.Now()
+		t.box.SetText(t.queue[0])
+	} else if time.Since(t.shownAt) > toastDuration {
+		t.queue = t.queue[1:]
+		t.shownAt = time.Time{}
+	}
+}
+
+// Draw draws the current notification to the window, if there is one.
+func (t *Toast) Draw(buf *gogl.FrameBuffer) {
+	if !t.shownAt.IsZero() {
+		t.box.Draw(buf)
+	}
+}
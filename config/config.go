@@ -2,9 +2,9 @@
 package config
 
 const (
-	// Version is used to check compatibility with other go-2048-battle clients when
-	// in versus mode.
-	Version = "1.0"
+	// Version is shown to other go-2048-battle clients in versus mode. Compatibility
+	// is checked with the protocol versions in package comms.
+	Version = "1.11"
 
 	// Debug enables debugging and diagnostics features which are useful for development.
 	Debug = false
@@ -14,4 +14,10 @@ const (
 
 	// WinHeight specifies the pixel height of the window.
 	WinHeight = 768
+
+	// UndoHistory is the maximum number of moves which can be undone in singleplayer.
+	UndoHistory = 100
+
+	// UndoLimit is the maximum number of undos per singleplayer game. Zero for unlimited.
+	UndoLimit = 0
 )
@@ -8,12 +8,12 @@ require (
 	github.com/moby/moby v27.3.1+incompatible
 	github.com/z-riley/gogl v0.1.0
 	github.com/z-riley/servesyouright v1.0.0
-	golang.org/x/exp v0.0.0-20240808152545-0cdaa3abc0fa
 )
 
 require (
 	github.com/netgusto/poly2tri-go v0.0.0-20170716161910-d102ad91854f // indirect
 	github.com/veandco/go-sdl2 v0.4.40 // indirect
+	golang.org/x/exp v0.0.0-20240808152545-0cdaa3abc0fa // indirect
 	golang.org/x/image v0.19.0 // indirect
 	golang.org/x/text v0.17.0 // indirect
 )
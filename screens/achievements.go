@@ -0,0 +1,149 @@
+package screens
+
+import (
+	"fmt"
+
+	"github.com/z-riley/go-2048-battle/common"
+	"github.com/z-riley/go-2048-battle/common/achievements"
+	"github.com/z-riley/go-2048-battle/config"
+	"github.com/z-riley/go-2048-battle/log"
+	"github.com/z-riley/gogl"
+)
+
+type AchievementsScreen struct {
+	win *gogl.Window
+
+	title    *gogl.Text
+	progress *gogl.Text
+	rows     []*achievementRow
+	back     *gogl.Button
+}
+
+// achievementRow contains the widgets which show one achievement in the list.
+type achievementRow struct {
+	background  *gogl.CurvedRect
+	name        *gogl.Text
+	description *gogl.Text
+	status      *gogl.Text
+}
+
+const (
+	// achievementsTop is the vertical position of the first row of the list.
+	achievementsTop = 190
+	// achievementRowHeight is the vertical distance between rows of the list.
+	achievementRowHeight = 70
+)
+
+// NewAchievementsScreen constructs an uninitialised achievements screen.
+func NewAchievementsScreen(win *gogl.Window) *AchievementsScreen {
+	return &AchievementsScreen{win: win}
+}
+
+// Enter initialises the screen.
+func (s *AchievementsScreen) Enter(_ InitData) {
+	unlocks, err := achievements.LoadUnlocks(achievements.NewStore())
+	if err != nil {
+		log.Println("Failed to read achievements:", err)
+		unlocks = achievements.Unlocks{}
+	}
+
+	s.title = gogl.NewText("Achievements", gogl.Vec{X: config.WinWidth / 2, Y: 80}, common.FontPathMedium).
+		SetColour(common.GreyTextColour).
+		SetAlignment(gogl.AlignCentre).
+		SetSize(80)
+
+	s.progress = gogl.NewText("", gogl.Vec{X: config.WinWidth / 2, Y: 145}, common.FontPathMedium).
+		SetColour(common.GreyTextColour).
+		SetAlignment(gogl.AlignCentre).
+		SetSize(24)
+
+	const (
+		left  = 150
+		width = config.WinWidth - 2*left
+	)
+	s.rows = s.rows[:0]
+	unlocked := 0
+	for i, a := range achievements.All {
+		y := achievementsTop + float64(i)*achievementRowHeight
+		at, ok := unlocks[a.ID]
+
+		// Unlocked achievements are highlighted, with the date they were unlocked
+		colour, status := common.ArenaBackgroundColour, "Locked"
+		if ok {
+			unlocked++
+			colour, status = common.Tile2048Colour, at.Format("2 Jan 2006")
+		}
+
+		row := &achievementRow{
+			background: gogl.NewCurvedRect(
+				width, achievementRowHeight-10, 6,
+				gogl.Vec{X: left, Y: y},
+			),
+			name: gogl.NewText(
+				a.Name,
+				gogl.Vec{X: left + 20, Y: y + 20},
+				common.FontPathBold,
+			).
+				SetColour(common.WhiteFontColour).
+				SetAlignment(gogl.AlignCentreLeft).
+				SetSize(26),
+			description: gogl.NewText(
+				a.Description,
+				gogl.Vec{X: left + 20, Y: y + 44},
+				common.FontPathMedium,
+			).
+				SetColour(common.WhiteFontColour).
+				SetAlignment(gogl.AlignCentreLeft).
+				SetSize(18),
+			status: gogl.NewText(
+				status,
+				gogl.Vec{X: left + width - 20, Y: y + (achievementRowHeight-10)/2},
+				common.FontPathMedium,
+			).
+				SetColour(common.WhiteFontColour).
+				SetAlignment(gogl.AlignCentreRight).
+				SetSize(22),
+		}
+		row.background.SetStyle(gogl.Style{Colour: colour})
+		s.rows = append(s.rows, row)
+	}
+	s.progress.SetText(fmt.Sprintf("%d of %d unlocked", unlocked, len(achievements.All)))
+
+	s.back = common.NewMenuButton(
+		300, 60,
+		gogl.Vec{X: (config.WinWidth - 300) / 2, Y: 670},
+		func() {
+			SetScreen(Stats, nil)
+		},
+	).SetLabelText("Back")
+
+	s.win.RegisterKeybind(gogl.KeyEscape, gogl.KeyRelease, func() {
+		SetScreen(Stats, nil)
+	})
+}
+
+// Exit deinitialises the screen.
+func (s *AchievementsScreen) Exit() {
+	s.win.UnregisterKeybind(gogl.KeyEscape, gogl.KeyRelease)
+}
+
+// Update updates and draws the achievements screen.
+func (s *AchievementsScreen) Update() {
+	s.win.SetBackground(common.BackgroundColour)
+
+	s.win.Draw(s.title)
+	s.win.Draw(s.progress)
+	for _, row := range s.rows {
+		for _, d := range []gogl.Drawable{
+			row.background,
+			row.name,
+			row.description,
+			row.status,
+		} {
+			s.win.Draw(d)
+		}
+	}
+
+	s.back.Update(s.win)
+	s.win.Draw(s.back)
+}
@@ -0,0 +1,154 @@
+package screens
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/z-riley/go-2048-battle/common"
+	"github.com/z-riley/go-2048-battle/common/comms"
+	"github.com/z-riley/gogl"
+)
+
+// emotes are the quick chat messages which are sent with the F1 to F4 keys.
+var emotes = []string{"GG", "Nice merge!", "Good luck!", "Oops!"}
+
+const (
+	// chatLines is the number of messages shown above the chat entry box.
+	chatLines = 3
+	// chatExpiry is how long a message is shown during a match, so the chat doesn't
+	// cover the arenas for long.
+	chatExpiry = 10 * time.Second
+)
+
+// chatBox is a compact chat overlay, with the latest messages shown above an entry
+// box for typing new ones. Messages are sent by pressing return.
+type chatBox struct {
+	entry *common.EntryBox
+	hint  *gogl.Text
+	log   *gogl.Text
+
+	send   func(text string) // sends a message written by the player
+	expiry time.Duration     // how long messages are shown for, or zero to keep them
+
+	mu       sync.Mutex // guards the messages, which arrive from the network
+	messages []chatMessage
+}
+
+// chatMessage is a message shown in the chat.
+type chatMessage struct {
+	text string
+	at   time.Time
+}
+
+// newChatBox constructs a chat overlay with its entry box at pos. send is called
+// with the text of every message written by the player, once it has been
+// sanitised.
+func newChatBox(pos gogl.Vec, width float64, expiry time.Duration, send func(text string)) *chatBox {
+	const height = 36
+	c := &chatBox{send: send, expiry: expiry}
+
+	c.entry = common.NewEntryBox(width, height, pos, "")
+	c.entry.TextBox.SetTextSize(18).SetTextOffset(gogl.Vec{X: 0, Y: 7})
+
+	c.hint = gogl.NewText(
+		"Click to chat, F1-F4 for emotes",
+		gogl.Vec{X: pos.X + width/2, Y: pos.Y + height/2},
+		common.FontPathMedium,
+	).
+		SetColour(common.LightGreyTextColour).
+		SetAlignment(gogl.AlignCentre).
+		SetSize(16)
+
+	c.log = gogl.NewText(
+		"",
+		gogl.Vec{X: pos.X, Y: pos.Y - 6},
+		common.FontPathMedium,
+	).
+		SetColour(common.GreyTextColour).
+		SetAlignment(gogl.AlignBottomLeft).
+		SetSize(16)
+
+	return c
+}
+
+// registerKeybinds sets the keys for sending messages and emotes.
+func (c *chatBox) registerKeybinds(win *gogl.Window) {
+	win.RegisterKeybind(gogl.KeyReturn, gogl.KeyRelease, c.submit)
+	win.RegisterKeybind(gogl.KeyF1, gogl.KeyRelease, func() { c.send(emotes[0]) })
+	win.RegisterKeybind(gogl.KeyF2, gogl.KeyRelease, func() { c.send(emotes[1]) })
+	win.RegisterKeybind(gogl.KeyF3, gogl.KeyRelease, func() { c.send(emotes[2]) })
+	win.RegisterKeybind(gogl.KeyF4, gogl.KeyRelease, func() { c.send(emotes[3]) })
+}
+
+// unregisterKeybinds removes the keys set by registerKeybinds.
+func (c *chatBox) unregisterKeybinds(win *gogl.Window) {
+	win.UnregisterKeybind(gogl.KeyReturn, gogl.KeyRelease)
+	win.UnregisterKeybind(gogl.KeyF1, gogl.KeyRelease)
+	win.UnregisterKeybind(gogl.KeyF2, gogl.KeyRelease)
+	win.UnregisterKeybind(gogl.KeyF3, gogl.KeyRelease)
+	win.UnregisterKeybind(gogl.KeyF4, gogl.KeyRelease)
+}
+
+// typing returns whether the player is writing a message, in which case the keys
+// they press shouldn't do anything else.
+func (c *chatBox) typing() bool {
+	return c.entry.TextBox.IsEditing()
+}
+
+// stopTyping abandons the message being written.
+func (c *chatBox) stopTyping() {
+	c.entry.Deselect()
+	c.entry.SetText("")
+}
+
+// submit sends the message being written.
+func (c *chatBox) submit() {
+	if !c.typing() {
+		return
+	}
+	text := comms.SanitiseChat(c.entry.Text())
+	c.stopTyping()
+	if text != "" {
+		c.send(text)
+	}
+}
+
+// add shows a message from a player.
+func (c *chatBox) add(name, text string) {
+	text = comms.SanitiseChat(text)
+	if text == "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.messages = append(c.messages, chatMessage{text: name + ": " + text, at: time.Now()})
+	if len(c.messages) > chatLines {
+		c.messages = c.messages[len(c.messages)-chatLines:]
+	}
+}
+
+// update updates the chat so it's interactive.
+func (c *chatBox) update(win *gogl.Window) {
+	c.entry.Update(win)
+
+	c.mu.Lock()
+	lines := make([]string, 0, len(c.messages))
+	for _, m := range c.messages {
+		if c.expiry == 0 || time.Since(m.at) < c.expiry {
+			lines = append(lines, m.text)
+		}
+	}
+	c.mu.Unlock()
+	c.log.SetText(strings.Join(lines, "\n"))
+}
+
+// draw draws the chat.
+func (c *chatBox) draw(win *gogl.Window) {
+	win.Draw(c.log)
+	win.Draw(c.entry)
+	if !c.typing() && c.entry.Text() == "" {
+		win.Draw(c.hint)
+	}
+}
@@ -0,0 +1,234 @@
+package screens
+
+import (
+	"strconv"
+
+	"github.com/z-riley/go-2048-battle/common"
+	"github.com/z-riley/go-2048-battle/common/backend"
+	"github.com/z-riley/go-2048-battle/config"
+	"github.com/z-riley/go-2048-battle/log"
+	"github.com/z-riley/gogl"
+)
+
+type LeaderboardScreen struct {
+	win *gogl.Window
+
+	board  *backend.Leaderboard
+	byTime bool          // whether the runs are sorted by the fastest time to 2048
+	runs   []backend.Run // the runs shown, in order
+
+	title    *gogl.Text
+	headings []*gogl.Text
+	rows     []*leaderboardRow
+	empty    *gogl.Text
+	sort     *gogl.Button
+	back     *gogl.Button
+}
+
+// leaderboardRow contains the widgets which show one run on the leaderboard. The
+// row is a button which plays the run's replay.
+type leaderboardRow struct {
+	button *gogl.Button
+	cells  []*gogl.Text
+}
+
+// leaderboardColumn is a column of the leaderboard.
+type leaderboardColumn struct {
+	heading string
+	x       float64
+	align   gogl.Alignment
+	value   func(rank int, r backend.Run) string
+}
+
+// leaderboardColumns are the columns of the leaderboard, from left to right.
+var leaderboardColumns = []leaderboardColumn{
+	{"#", 120, gogl.AlignCentreLeft, func(rank int, _ backend.Run) string { return strconv.Itoa(rank) }},
+	{"Name", 170, gogl.AlignCentreLeft, func(_ int, r backend.Run) string { return runName(r) }},
+	{"Score", 510, gogl.AlignCentreRight, func(_ int, r backend.Run) string { return strconv.Itoa(r.Score) }},
+	{"Tile", 610, gogl.AlignCentreRight, func(_ int, r backend.Run) string { return strconv.Itoa(r.HighestTile) }},
+	{"Moves", 710, gogl.AlignCentreRight, func(_ int, r backend.Run) string { return strconv.Itoa(r.Moves) }},
+	{"Time", 820, gogl.AlignCentreRight, func(_ int, r backend.Run) string { return r.Duration.String() }},
+	{"2048 in", 930, gogl.AlignCentreRight, func(_ int, r backend.Run) string { return wonAtText(r) }},
+	{"Date", 1080, gogl.AlignCentreRight, func(_ int, r backend.Run) string { return r.At.Format("2 Jan 2006") }},
+}
+
+const (
+	// leaderboardTop is the vertical position of the first row of the leaderboard.
+	leaderboardTop = 180
+	// leaderboardRowHeight is the vertical distance between rows of the leaderboard.
+	leaderboardRowHeight = 46
+)
+
+// NewLeaderboardScreen constructs an uninitialised leaderboard screen.
+func NewLeaderboardScreen(win *gogl.Window) *LeaderboardScreen {
+	return &LeaderboardScreen{win: win}
+}
+
+// Enter initialises the screen.
+func (s *LeaderboardScreen) Enter(_ InitData) {
+	board, err := backend.LoadLeaderboard(backend.NewLeaderboardStore())
+	if err != nil {
+		log.Println("Failed to read leaderboard:", err)
+		board = backend.NewLeaderboard()
+	}
+	s.board = board
+
+	s.title = gogl.NewText("Top runs", gogl.Vec{X: config.WinWidth / 2, Y: 80}, common.FontPathMedium).
+		SetColour(common.GreyTextColour).
+		SetAlignment(gogl.AlignCentre).
+		SetSize(80)
+
+	s.headings = s.headings[:0]
+	for _, c := range leaderboardColumns {
+		s.headings = append(s.headings, gogl.NewText(
+			c.heading,
+			gogl.Vec{X: c.x, Y: leaderboardTop - 30},
+			common.FontPathMedium,
+		).
+			SetColour(common.GreyTextColour).
+			SetAlignment(c.align).
+			SetSize(22))
+	}
+
+	s.rows = s.rows[:0]
+	for i := range backend.LeaderboardSize {
+		y := leaderboardTop + float64(i)*leaderboardRowHeight
+		row := &leaderboardRow{
+			button: common.NewMenuButton(
+				1000, leaderboardRowHeight-6,
+				gogl.Vec{X: 100, Y: y},
+				func() { s.watch(i) },
+			).SetLabelText(""),
+		}
+		for _, c := range leaderboardColumns {
+			row.cells = append(row.cells, gogl.NewText(
+				"",
+				gogl.Vec{X: c.x, Y: y + (leaderboardRowHeight-6)/2},
+				common.FontPathMedium,
+			).
+				SetColour(common.WhiteFontColour).
+				SetAlignment(c.align).
+				SetSize(22))
+		}
+		s.rows = append(s.rows, row)
+	}
+
+	s.empty = gogl.NewText(
+		"",
+		gogl.Vec{X: config.WinWidth / 2, Y: leaderboardTop + 2*leaderboardRowHeight},
+		common.FontPathMedium,
+	).
+		SetColour(common.GreyTextColour).
+		SetAlignment(gogl.AlignCentre).
+		SetSize(30)
+
+	s.sort = common.NewMenuButton(
+		300, 60,
+		gogl.Vec{X: config.WinWidth/2 - 310, Y: 670},
+		s.toggleSort,
+	)
+	s.back = common.NewMenuButton(
+		300, 60,
+		gogl.Vec{X: config.WinWidth/2 + 10, Y: 670},
+		func() {
+			SetScreen(Stats, nil)
+		},
+	).SetLabelText("Back")
+
+	s.byTime = false
+	s.showRuns()
+
+	s.win.RegisterKeybind(gogl.KeyS, gogl.KeyRelease, s.toggleSort)
+	s.win.RegisterKeybind(gogl.KeyEscape, gogl.KeyRelease, func() {
+		SetScreen(Stats, nil)
+	})
+}
+
+// Exit deinitialises the screen.
+func (s *LeaderboardScreen) Exit() {
+	s.win.UnregisterKeybind(gogl.KeyS, gogl.KeyRelease)
+	s.win.UnregisterKeybind(gogl.KeyEscape, gogl.KeyRelease)
+}
+
+// Update updates and draws the leaderboard screen.
+func (s *LeaderboardScreen) Update() {
+	s.win.SetBackground(common.BackgroundColour)
+
+	s.win.Draw(s.title)
+	for _, h := range s.headings {
+		s.win.Draw(h)
+	}
+
+	for _, row := range s.rows[:len(s.runs)] {
+		row.button.Update(s.win)
+		s.win.Draw(row.button)
+		for _, c := range row.cells {
+			s.win.Draw(c)
+		}
+	}
+	if len(s.runs) == 0 {
+		s.win.Draw(s.empty)
+	}
+
+	for _, b := range []*gogl.Button{
+		s.sort,
+		s.back,
+	} {
+		b.Update(s.win)
+		s.win.Draw(b)
+	}
+}
+
+// toggleSort switches between sorting the runs by score and by the fastest time to
+// 2048.
+func (s *LeaderboardScreen) toggleSort() {
+	s.byTime = !s.byTime
+	s.showRuns()
+}
+
+// showRuns fills in the rows with the runs in the current order.
+func (s *LeaderboardScreen) showRuns() {
+	if s.byTime {
+		s.runs = s.board.ByTime()
+		s.sort.SetLabelText("By time")
+		s.empty.SetText("Nobody has reached 2048 yet")
+	} else {
+		s.runs = s.board.ByScore()
+		s.sort.SetLabelText("By score")
+		s.empty.SetText("No games have been finished yet")
+	}
+
+	for i, r := range s.runs {
+		for j, c := range leaderboardColumns {
+			s.rows[i].cells[j].SetText(c.value(i+1, r))
+		}
+	}
+}
+
+// watch plays the replay of the run in a row, if it has one.
+func (s *LeaderboardScreen) watch(row int) {
+	if row >= len(s.runs) || s.runs[row].Replay == nil {
+		return
+	}
+	SetScreen(Replay, InitData{
+		replayKey: s.runs[row].Replay,
+		returnKey: Leaderboard,
+	})
+}
+
+// runName returns the name shown for a run. Runs finished without a profile have
+// no name.
+func runName(r backend.Run) string {
+	if r.Name == "" {
+		return "You"
+	}
+	return r.Name
+}
+
+// wonAtText returns the time a run took to reach 2048, or a dash if it didn't.
+func wonAtText(r backend.Run) string {
+	if r.WonAt == 0 {
+		return "-"
+	}
+	return r.WonAt.String()
+}
@@ -0,0 +1,120 @@
+package screens
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/z-riley/go-2048-battle/common"
+	"github.com/z-riley/go-2048-battle/common/comms"
+	"github.com/z-riley/gogl"
+)
+
+// lobbyX is the horizontal centre of the list of players in a lobby.
+const lobbyX = 185
+
+// newLobbyWidgets constructs the heading and list of players in a lobby.
+func newLobbyWidgets() (heading, list *gogl.Text) {
+	heading = gogl.NewText(
+		"Lobby:",
+		gogl.Vec{X: lobbyX, Y: 250},
+		common.FontPathMedium,
+	).
+		SetColour(common.GreyTextColour).
+		SetAlignment(gogl.AlignCentre).
+		SetSize(30)
+
+	list = gogl.NewText(
+		"",
+		gogl.Vec{X: lobbyX, Y: 290},
+		common.FontPathMedium,
+	).
+		SetColour(common.GreyTextColour).
+		SetAlignment(gogl.AlignTopCentre).
+		SetSize(24)
+
+	return heading, list
+}
+
+// sortedPlayers returns the players in a lobby in order of ID.
+func sortedPlayers(players map[comms.PlayerID]comms.PlayerData) []comms.PlayerData {
+	sorted := make([]comms.PlayerData, 0, len(players))
+	for _, id := range slices.Sorted(maps.Keys(players)) {
+		sorted = append(sorted, players[id])
+	}
+	return sorted
+}
+
+// lobbyText lists the players in a lobby with their ratings, one per line.
+func lobbyText(players []comms.PlayerData, self comms.PlayerID) string {
+	lines := make([]string, 0, len(players))
+	for _, p := range players {
+		line := p.Username
+		if p.Rating > 0 {
+			line += fmt.Sprintf(" [%d]", p.Rating)
+		}
+		switch p.ID {
+		case self:
+			line += " (you)"
+		case comms.HostID:
+			line += " (host)"
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// rulePresets are the rules which the host can choose between in the lobby. The
+// mode is chosen separately.
+var rulePresets = []comms.RulesData{
+	{Target: 2048, Rounds: 1},
+	{Target: 1024, Rounds: 1},
+	{Target: 512, Rounds: 1},
+	{Target: 2048, TimeLimit: 3 * time.Minute, Rounds: 1},
+	{Target: 2048, TimeLimit: 5 * time.Minute, Rounds: 1},
+	{Target: 512, Rounds: 3},
+	{Target: 256, Rounds: 5},
+}
+
+// newRulesWidgets constructs the heading and description of the rules in a lobby,
+// below the list of players.
+func newRulesWidgets() (heading, description *gogl.Text) {
+	heading = gogl.NewText(
+		"Rules:",
+		gogl.Vec{X: lobbyX, Y: 440},
+		common.FontPathMedium,
+	).
+		SetColour(common.GreyTextColour).
+		SetAlignment(gogl.AlignCentre).
+		SetSize(30)
+
+	description = gogl.NewText(
+		rulesText(comms.DefaultRules()),
+		gogl.Vec{X: lobbyX, Y: 480},
+		common.FontPathMedium,
+	).
+		SetColour(common.GreyTextColour).
+		SetAlignment(gogl.AlignTopCentre).
+		SetSize(24)
+
+	return heading, description
+}
+
+// rulesText describes the rules of a match, one rule per line.
+func rulesText(rules comms.RulesData) string {
+	var lines []string
+	if rules.TimeLimit > 0 {
+		lines = append(lines, fmt.Sprintf("Highest score in %d min", int(rules.TimeLimit.Minutes())))
+	} else {
+		lines = append(lines, fmt.Sprintf("First to %d", rules.Target))
+	}
+	if rules.Rounds > 1 {
+		lines = append(lines, fmt.Sprintf("Best of %d rounds", rules.Rounds))
+	}
+	if rules.Mode == comms.ModeAttack {
+		lines = append(lines, "Attack mode")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -1,15 +1,22 @@
 package screens
 
 import (
+	"context"
 	"fmt"
 	"image/color"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
-	"github.com/brunoga/deep"
 	"github.com/z-riley/go-2048-battle/common"
 	"github.com/z-riley/go-2048-battle/common/backend"
 	"github.com/z-riley/go-2048-battle/common/backend/grid"
+	"github.com/z-riley/go-2048-battle/common/backend/grid/ai"
 	"github.com/z-riley/go-2048-battle/common/comms"
+	"github.com/z-riley/go-2048-battle/common/host"
+	"github.com/z-riley/go-2048-battle/common/match"
+	"github.com/z-riley/go-2048-battle/common/profile"
 	"github.com/z-riley/go-2048-battle/config"
 	"github.com/z-riley/go-2048-battle/log"
 	"github.com/z-riley/gogl"
@@ -22,26 +29,167 @@ type MultiplayerScreen struct {
 	logo2048         *gogl.TextBox
 
 	newGame       *gogl.Button
+	rematch       *gogl.Button // replaces the new game button once the match is over
 	menu          *gogl.Button
 	score         *common.ScoreBox
 	guide         *gogl.Text
 	timer         *gogl.Text
-	backend       *backend.Game
+	backend       *backend.Game // the player's game in the match
 	arena         *common.Arena
 	arenaInputCh  chan func()
 	endGameDialog *gogl.Text
+	countdown     *gogl.Text
+	chat          *chatBox
 	debugGrid     *gogl.Text
 
-	opponentScore     *common.ScoreBox
-	opponentName      string
-	opponentGuide     *gogl.Text
-	opponentArena     *common.Arena
-	opponentBackend   *backend.Game
-	opponentDebugGrid *gogl.Text
+	match           *match.Match
+	id              comms.PlayerID                      // the player's ID in the match
+	players         map[comms.PlayerID]comms.PlayerData // everyone's player data from the lobby
+	profile         *profile.Profile
+	opponents       []*opponent
+	opponentInputCh chan func()
+
+	// EITHER server, client or bot will exist
+	server   *servesyouright.Server
+	client   *servesyouright.Client
+	hostAddr hostAddr // used by the client to reconnect
+	bot      *ai.Bot
+	botDone  chan struct{}
+
+	// host drives the match for the host, or the player against a bot. It is nil
+	// for a guest, who is sent every change by the host
+	host *host.Match
+	// peers are the connections to human opponents. The host has one for each
+	// guest, and a guest has one for the host
+	peers         map[comms.PlayerID]*host.Peer
+	heartbeatDone chan struct{}
+
+	// token is what the guest was given to rejoin the match with
+	token string
+	// rejoining is set whilst a guest on a new connection waits for the host to
+	// welcome it back, and rejoinSentAt is when it last asked
+	rejoining    bool
+	rejoinSentAt time.Time
+	// inputSeq is the sequence number of the last input sent by the guest
+	inputSeq int
+	// resyncing is set whilst the guest waits for the host to send the state of
+	// the match, after a delta couldn't be applied
+	resyncing bool
+
+	// loaded is set once the guest has received the match from the host, and
+	// sentReady once it has told the host that it's ready to start
+	loaded, sentReady bool
+	// startAt is when the match starts on the local clock, or zero until the host
+	// has decided. Nobody can play until then
+	startAt time.Time
+	// started is set once the countdown has finished and the timer is running
+	started bool
+
+	// roundStart is when the current round started, for the countdown
+	roundStart time.Time
+	// roundOverAt is when the current round finished, or zero if it's being played
+	roundOverAt time.Time
+	// matchStart is when the countdown to the match finished, or zero until it has
+	matchStart time.Time
+	// result is the player's result in the match, once it has been recorded in
+	// their profile
+	result *profile.Result
+}
+
+// opponent contains the widgets which show an opponent's game.
+type opponent struct {
+	id        comms.PlayerID
+	name      string
+	score     *common.ScoreBox
+	guide     *gogl.Text
+	arena     *common.Arena
+	latency   *gogl.Text
+	debugGrid *gogl.Text
+}
+
+// newOpponent constructs the widgets for an opponent's game. pos and scale are the
+// position and scale of the arena.
+func newOpponent(p comms.PlayerData, game *backend.Game, pos gogl.Vec, scale float64) *opponent {
+	o := &opponent{
+		id:    p.ID,
+		name:  p.Username,
+		arena: common.NewScaledArena(pos, game.Grid.Width(), game.Grid.Height(), scale),
+	}
+
+	// Everything is positioned relative to the arena grid
+	const unit = common.TileSizePx
+	anchor := o.arena.Pos()
+
+	o.score = common.NewScoreBox(
+		90, 90,
+		gogl.Vec{X: anchor.X, Y: anchor.Y - 2.58*unit},
+		common.ArenaBackgroundColour,
+	).SetHeading("SCORE")
+
+	o.guide = common.NewGameText(
+		o.name+"'s grid",
+		gogl.Vec{X: anchor.X, Y: anchor.Y - 0.67*unit},
+	)
+
+	o.latency = common.NewGameText(
+		"",
+		gogl.Vec{X: anchor.X + 90 + 0.1*unit, Y: anchor.Y - 2.58*unit + 45},
+	).SetAlignment(gogl.AlignCentreLeft)
+
+	o.debugGrid = gogl.NewText(
+		game.Grid.Debug(),
+		gogl.Vec{X: anchor.X, Y: 50},
+		common.FontPathMedium,
+	)
+
+	return o
+}
+
+// update updates the opponent's widgets from the match. self is the ID of the
+// player watching, or zero for a spectator.
+func (o *opponent) update(m *match.Match, self comms.PlayerID) {
+	o.score.SetBody(strconv.Itoa(m.Game(o.id).Score))
+	o.arena.SetIncoming(m.Incoming(o.id))
+
+	rank := m.Rank(o.id)
+	if !m.Over() {
+		rank = m.RoundRank(o.id)
+	}
+
+	switch {
+	case m.RoundOver():
+		if rank == 1 {
+			o.arena.SetWin()
+		} else {
+			o.arena.SetLose()
+		}
+		o.guide.SetText(resultText(m, o.id, self, o.name))
+	case m.Left(o.id):
+		o.arena.SetLose()
+		o.guide.SetText(o.name + " left the game!")
+	case m.Eliminated(o.id):
+		o.arena.SetLose()
+		o.guide.SetText(o.name + " is out!")
+	}
+}
+
+// newRound shows the opponent's new game at the start of a round. The new game
+// can't be animated from the previous one.
+func (o *opponent) newRound(game *backend.Game) {
+	o.arena.SetNormal()
+	o.arena.Reload(game.Copy())
+	o.guide.SetText(o.name + "'s grid")
+}
 
-	// EITHER server or client will exist
-	server *servesyouright.Server
-	client *servesyouright.Client
+// draw draws the opponent's widgets.
+func (o *opponent) draw(win *gogl.Window) {
+	for _, d := range []gogl.Drawable{
+		o.score,
+		o.guide,
+		o.arena,
+	} {
+		win.Draw(d)
+	}
 }
 
 // NewMultiplayerScreen constructs a new singleplayer menu screen.
@@ -53,21 +201,48 @@ func NewMultiplayerScreen(win *gogl.Window) *MultiplayerScreen {
 }
 
 const (
-	// usernameKey is used for indentifying the player's username in InitData.
-	usernameKey = "username"
-	// usernameKey is used for indentifying the opponent's username in InitData.
-	opponentUsernameKey = "opponentUsername"
+	// playersKey is used for indentifying everyone in the lobby in InitData.
+	playersKey = "players"
+	// playerIDKey is used for indentifying the player's ID in InitData.
+	playerIDKey = "playerID"
+	// lobbyKey is used for indentifying the host's lobby in InitData.
+	lobbyKey = "lobby"
+	// botKey is used for indentifying the difficulty of a bot opponent in InitData.
+	botKey = "bot"
+	// rulesKey is used for indentifying the rules of the match in InitData. The
+	// guests also receive them with the state of the match.
+	rulesKey = "rules"
+	// sessionKey is used for indentifying what a guest agreed with the host in
+	// InitData.
+	sessionKey = "session"
 )
 
 // Enter initialises the screen.
 func (s *MultiplayerScreen) Enter(initData InitData) {
+	players := initData[playersKey].([]comms.PlayerData)
+	s.id = initData[playerIDKey].(comms.PlayerID)
+	s.players = make(map[comms.PlayerID]comms.PlayerData, len(players))
+	ids := make([]comms.PlayerID, 0, len(players))
+	for _, p := range players {
+		s.players[p.ID] = p
+		ids = append(ids, p.ID)
+	}
+	s.match = match.New(ids, 0)
+	s.match.WaitToStart() // until the countdown finishes
+	if rules, ok := initData[rulesKey]; ok {
+		s.match.SetRules(rules.(comms.RulesData))
+	}
+	s.backend = s.match.Game(s.id)
+	s.profile = loadProfile()
+	s.matchStart, s.result = time.Time{}, nil
+
 	// UI widgets
 	{
-		s.arena = common.NewArena(
-			gogl.Vec{X: config.WinWidth/3 - 249, Y: 300},
-		)
-		s.opponentArena = common.NewArena(
-			gogl.Vec{X: config.WinWidth*2/3 - 71, Y: 300},
+		positions, scale := arenaLayout(len(players))
+		s.arena = common.NewScaledArena(
+			positions[0],
+			s.backend.Grid.Width(), s.backend.Grid.Height(),
+			scale,
 		)
 
 		// Everything is sized relative to the tile size and arena position
@@ -75,25 +250,42 @@ func (s *MultiplayerScreen) Enter(initData InitData) {
 		anchor := s.arena.Pos()
 
 		const logoSize = 1.36 * unit
-		s.logo2048 = common.NewLogoBox(
-			logoSize,
-			gogl.Vec{X: (config.WinWidth - logoSize) / 2, Y: anchor.Y - 2.58*unit},
-		)
+		logoPos := gogl.Vec{X: (config.WinWidth - logoSize) / 2, Y: anchor.Y - 2.58*unit}
+		dialogPos := gogl.Vec{X: config.WinWidth / 2, Y: anchor.Y - 2.5*unit}
+		timerPos := gogl.Vec{X: config.WinWidth / 2, Y: anchor.Y - 0.67*unit}
+		if len(players) > 2 {
+			// There's no room between the arenas, so everything shared goes above them
+			logoPos.Y, timerPos.Y, dialogPos.Y = 30, 140, 165
+		}
+
+		s.logo2048 = common.NewLogoBox(logoSize, logoPos)
 
 		s.endGameDialog = common.NewGameText(
 			"Press MENU to\nplay again",
-			gogl.Vec{X: config.WinWidth / 2, Y: anchor.Y - 2.5*unit},
+			dialogPos,
 		).SetAlignment(gogl.AlignTopCentre).SetSize(25)
 
+		s.countdown = common.NewGameText(
+			"",
+			gogl.Vec{X: config.WinWidth / 2, Y: anchor.Y + s.arena.Height()/2},
+		).SetAlignment(gogl.AlignCentre).SetSize(80)
+
 		// Player's grid
 		{
 			const widgetWidth = unit * 1.27
+			newGamePos := gogl.Vec{X: anchor.X + s.arena.Width() - 2.74*unit, Y: anchor.Y - 1.21*unit}
 			s.newGame = common.NewGameButton(
 				widgetWidth, 0.4*unit,
-				gogl.Vec{X: anchor.X + s.arena.Width() - 2.74*unit, Y: anchor.Y - 1.21*unit},
-				func() { s.Reset() },
+				newGamePos,
+				func() { s.arenaInputCh <- s.Reset },
 			).SetLabelText("NEW")
 
+			s.rematch = common.NewGameButton(
+				widgetWidth, 0.4*unit,
+				newGamePos,
+				func() { s.arenaInputCh <- s.requestRematch },
+			).SetLabelText("REMATCH")
+
 			s.menu = common.NewGameButton(
 				widgetWidth, 0.4*unit,
 				gogl.Vec{X: anchor.X + s.arena.Width() - widgetWidth, Y: anchor.Y - 1.21*unit},
@@ -114,128 +306,201 @@ func (s *MultiplayerScreen) Enter(initData InitData) {
 				gogl.Vec{X: anchor.X + s.arena.Width(), Y: anchor.Y - 0.67*unit},
 			).SetAlignment(gogl.AlignTopRight)
 
-			s.backend = backend.NewGame(&backend.Opts{
-				SaveToDisk: false,
-			})
 			s.arenaInputCh = make(chan func(), 100)
+			s.opponentInputCh = make(chan func(), 100)
 
-			s.timer = common.NewGameText("",
-				gogl.Vec{X: config.WinWidth / 2, Y: anchor.Y - 0.67*unit},
-			).SetAlignment(gogl.AlignTopCentre)
+			s.timer = common.NewGameText("", timerPos).SetAlignment(gogl.AlignTopCentre)
 		}
 
-		// Opponent's grid
-		{
-			// Everything is positioned relative to the arena grid
-			opponentAnchor := s.opponentArena.Pos()
-
-			s.opponentScore = common.NewScoreBox(
-				90, 90,
-				gogl.Vec{X: opponentAnchor.X, Y: opponentAnchor.Y - 2.58*unit},
-				common.ArenaBackgroundColour,
-			).SetHeading("SCORE")
-
-			s.opponentName = initData[opponentUsernameKey].(string)
-			s.opponentGuide = common.NewGameText(
-				s.opponentName+"'s grid",
-				gogl.Vec{X: opponentAnchor.X, Y: opponentAnchor.Y - 0.67*unit},
-			)
-
-			s.opponentBackend = backend.NewGame(&backend.Opts{
-				SaveToDisk: false,
-			})
+		// Opponents' grids, in the order they joined
+		s.opponents = s.opponents[:0]
+		for _, p := range players {
+			if p.ID != s.id {
+				pos := positions[len(s.opponents)+1]
+				s.opponents = append(s.opponents, newOpponent(p, s.match.Game(p.ID), pos, scale))
+			}
 		}
 
+		s.chat = newChatBox(gogl.Vec{X: 20, Y: config.WinHeight - 56}, 360, chatExpiry, s.sendChat)
+
 		// Debug widgets
 		s.debugGrid = gogl.NewText(
 			s.backend.Grid.Debug(),
 			gogl.Vec{X: 100, Y: 50},
 			common.FontPathMedium,
 		)
-
-		s.opponentDebugGrid = gogl.NewText(
-			s.opponentBackend.Grid.Debug(),
-			gogl.Vec{X: 850, Y: 50},
-			common.FontPathMedium,
-		)
 	}
 
 	// Initialise server/client
 	{
+		s.inputSeq, s.resyncing = 0, false
+		s.loaded, s.sentReady = false, false
+		s.startAt, s.started = time.Time{}, false
+		s.rejoining, s.rejoinSentAt = false, time.Time{}
+		s.host = nil
 		if server, ok := initData[serverKey]; ok {
-			// Host mode - initialise server
+			// Host mode - initialise server. The host plays every game, and sends the
+			// changes to the guests
 			s.server = server.(*servesyouright.Server)
-			s.server.SetCallback(func(_ int, b []byte) {
-				if err := s.handleOpponentData(b); err != nil {
-					log.Println("Failed to handle opponent data as server", err)
+			s.hostMatch(initData[lobbyKey].(*host.Lobby))
+			s.server.SetCallback(func(conn int, b []byte) {
+				if err := s.handleGuestData(conn, b); err != nil {
+					log.Println("Failed to handle guest data as server", err)
 				}
-			}).SetDisconnectCallback(func(_ int) {
-				log.Println("Opponent has left the game")
+			}).SetDisconnectCallback(func(conn int) {
+				s.opponentInputCh <- func() { s.host.Disconnect(conn) }
 			})
 		} else if client, ok := initData[clientKey]; ok {
-			// Guest mode - initialise client
+			// Guest mode - initialise client. Every game is replaced by the host's
+			// state as soon as it arrives
+			s.peers = map[comms.PlayerID]*host.Peer{
+				comms.HostID: host.NewPeer(initData[sessionKey].(comms.Session)),
+			}
 			s.client = client.(*servesyouright.Client)
+			s.hostAddr = initData[hostAddrKey].(hostAddr)
+			s.token = initData[tokenKey].(string)
 			s.client.SetCallback(func(b []byte) {
-				if err := s.handleOpponentData(b); err != nil {
-					log.Println("Failed to handle opponent data as client", err)
+				if err := s.handleHostData(b); err != nil {
+					log.Println("Failed to handle host data as client", err)
 				}
 			})
+			s.startHeartbeat()
+		} else if difficulty, ok := initData[botKey]; ok {
+			// Bot mode - the match spawns the same tiles in both games so it is fair.
+			// The player hosts the match without any guests
+			s.bot = ai.NewBot(difficulty.(ai.Difficulty), s.backend.Grid.Seed)
+			s.startBot()
+			s.hostMatch(host.NewLobby(nil, s.players[s.id], s.match.Rules()))
 		} else {
-			panic("neither server or client was passed to MultiplayerScreen Init")
+			panic("neither server, client or bot was passed to MultiplayerScreen Init")
 		}
 
-		// Tell the opponent that the local server/client is ready to receive data
-		if err := s.sendScreenLoadedEvent(); err != nil {
-			log.Println("Failed to send game update", err)
+		// Tell the opponents that the local server/client is ready to receive data
+		if s.bot == nil {
+			if err := s.sendScreenLoadedEvent(); err != nil {
+				log.Println("Failed to send game update", err)
+			}
 		}
 	}
 
 	// Set keybinds. User inputs are sent to the backend via a buffered channel
 	// so the backend game cannot execute multiple moves before the frontend has
-	// finished animating the first one
+	// finished animating the first one. Keys typed into the chat are ignored
 	{
 		s.win.RegisterKeybind(gogl.KeyUp, gogl.KeyPress, func() {
-			s.arenaInputCh <- func() {
-				s.backend.ExecuteMove(grid.DirUp)
+			if !s.chat.typing() {
+				s.arenaInputCh <- func() {
+					s.move(grid.DirUp)
+				}
 			}
 		})
 		s.win.RegisterKeybind(gogl.KeyDown, gogl.KeyPress, func() {
-			s.arenaInputCh <- func() {
-				s.backend.ExecuteMove(grid.DirDown)
+			if !s.chat.typing() {
+				s.arenaInputCh <- func() {
+					s.move(grid.DirDown)
+				}
 			}
 		})
 		s.win.RegisterKeybind(gogl.KeyLeft, gogl.KeyPress, func() {
-			s.arenaInputCh <- func() {
-				s.backend.ExecuteMove(grid.DirLeft)
+			if !s.chat.typing() {
+				s.arenaInputCh <- func() {
+					s.move(grid.DirLeft)
+				}
 			}
 		})
 		s.win.RegisterKeybind(gogl.KeyRight, gogl.KeyPress, func() {
-			s.arenaInputCh <- func() {
-				s.backend.ExecuteMove(grid.DirRight)
+			if !s.chat.typing() {
+				s.arenaInputCh <- func() {
+					s.move(grid.DirRight)
+				}
 			}
 		})
 		s.win.RegisterKeybind(gogl.KeyR, gogl.KeyRelease, func() {
-			s.Reset()
+			if !s.chat.typing() {
+				s.arenaInputCh <- s.Reset
+			}
 		})
 		s.win.RegisterKeybind(gogl.KeyEscape, gogl.KeyRelease, func() {
+			if s.chat.typing() {
+				s.chat.stopTyping()
+				return
+			}
 			SetScreen(Title, nil)
 		})
+		s.chat.registerKeybinds(s.win)
 	}
 
-	// Start the game timer immediately, rather than wait for the first move like
-	// in singleplayer mode
-	s.backend.Timer.Resume()
+	s.roundStart, s.roundOverAt = time.Now(), time.Time{}
+}
+
+// arenaLayout returns the position of every arena for a match between n players,
+// and how much the arenas are scaled. The player's own arena comes first.
+func arenaLayout(n int) (positions []gogl.Vec, scale float64) {
+	if n <= 2 {
+		return []gogl.Vec{
+			{X: config.WinWidth/3 - 249, Y: 300},
+			{X: config.WinWidth*2/3 - 71, Y: 300},
+		}, 1
+	}
+
+	// Shrink the arenas so they fit side by side, with equal gaps between them
+	scale = 0.72
+	size := common.ArenaSizePx * scale
+	gap := (config.WinWidth - float64(n)*size) / float64(n+1)
+	for i := range n {
+		positions = append(positions, gogl.Vec{
+			X: gap + float64(i)*(size+gap) + common.TileSizePx*common.TileBoundryFactor*scale,
+			Y: 430,
+		})
+	}
+	return positions, scale
+}
+
+// canPlay returns whether the player can currently change their game.
+func (s *MultiplayerScreen) canPlay() bool {
+	return s.started && !s.disconnected() && !s.rejoining && !s.match.RoundOver() && !s.match.Eliminated(s.id)
 }
 
-// Reset resets the multiplayer screen.
+// Reset resets the player's game. A guest asks the host to reset it for them.
 func (s *MultiplayerScreen) Reset() {
-	s.backend.ResetKeepTimer()
-	s.arena.Reset()
+	if !s.canPlay() {
+		return
+	}
+	if s.client != nil {
+		if err := s.sendInput(comms.ActionReset, ""); err != nil {
+			log.Println("Failed to send reset:", err)
+		}
+		return
+	}
+
+	if err := s.host.Play(s.id, comms.ActionReset, ""); err != nil {
+		log.Println("Failed to reset game:", err)
+	}
+}
+
+// move moves the player's grid. A guest asks the host to move it for them.
+func (s *MultiplayerScreen) move(dir grid.Direction) {
+	if !s.canPlay() {
+		return
+	}
+	if s.client != nil {
+		if err := s.sendInput(comms.ActionMove, dir); err != nil {
+			log.Println("Failed to send move:", err)
+		}
+		return
+	}
+
+	if err := s.host.Play(s.id, comms.ActionMove, dir); err != nil {
+		log.Println("Failed to move grid:", err)
+	}
 }
 
 // Exit deinitialises the screen.
 func (s *MultiplayerScreen) Exit() {
-	s.backend.Timer.Pause()
+	if s.started && !s.match.Over() {
+		s.recordResult(true)
+	}
+	s.match.Close()
 
 	if err := s.backend.Save(); err != nil {
 		panic(err)
@@ -246,14 +511,28 @@ func (s *MultiplayerScreen) Exit() {
 	s.win.UnregisterKeybind(gogl.KeyLeft, gogl.KeyPress)
 	s.win.UnregisterKeybind(gogl.KeyRight, gogl.KeyPress)
 	s.win.UnregisterKeybind(gogl.KeyEscape, gogl.KeyRelease)
+	s.chat.unregisterKeybinds(s.win)
+
+	if s.heartbeatDone != nil {
+		close(s.heartbeatDone)
+		s.heartbeatDone = nil
+	}
 
 	if s.server != nil {
 		s.server.Destroy()
+		s.server = nil
 	} else if s.client != nil {
 		s.client.Destroy()
+		s.client = nil
+	} else if s.bot != nil {
+		close(s.botDone)
+		s.bot = nil
 	}
 
 	s.arena.Destroy()
+	for _, o := range s.opponents {
+		o.arena.Destroy()
+	}
 }
 
 // Update updates and draws the multiplayer screen.
@@ -265,91 +544,128 @@ func (s *MultiplayerScreen) Update() {
 	select {
 	case inputFunc := <-s.arenaInputCh:
 		inputFunc()
-		if err := s.sendGameData(); err != nil {
-			log.Println("Failed to send game update:", err)
-		}
 	default:
 		// No user input; continue
 	}
+	select {
+	case inputFunc := <-s.opponentInputCh:
+		inputFunc()
+	default:
+		// No opponent input; continue
+	}
+
+	if s.host != nil {
+		s.updateHost()
+	} else {
+		s.updateConnection()
+		s.updateCountdown()
+		s.updateRound()
+	}
 
 	// Deep copy so front-end has time to animate itself whilst allowing the back
 	// end to update
-	s.arena.Update(deep.MustCopy(*s.backend))
-	s.opponentArena.Update(deep.MustCopy(*s.opponentBackend))
+	s.arena.Update(s.backend.Copy())
+	for _, o := range s.opponents {
+		o.arena.Update(s.match.Game(o.id).Copy())
+	}
 
-	// Check for win or lose
-	isLoss := s.backend.Grid.Outcome() == grid.Lose || s.opponentBackend.Grid.Outcome() == grid.Win
-	isWin := s.backend.Grid.Outcome() == grid.Win || s.opponentBackend.Grid.Outcome() == grid.Lose
-	switch {
-	case isLoss:
-		s.updateLose()
-	case isWin:
-		s.updateWin()
-	default:
+	if s.match.Over() {
+		s.recordResult(false)
+		s.updateGameEnd()
+	} else {
 		s.updateNormal()
 	}
 
+	if s.canChat() {
+		s.chat.update(s.win)
+		s.chat.draw(s.win)
+	}
+
 	if config.Debug {
 		s.debugGrid.SetText(s.backend.Grid.Debug())
-		s.opponentDebugGrid.SetText(s.opponentBackend.Grid.Debug())
 		s.win.Draw(s.debugGrid)
-		s.win.Draw(s.opponentDebugGrid)
+		for _, o := range s.opponents {
+			o.debugGrid.SetText(s.match.Game(o.id).Grid.Debug())
+			s.win.Draw(o.debugGrid)
+		}
 	}
 }
 
-// Update updates and draws the singleplayer screen in a normal state.
+// updateNormal updates and draws the multiplayer screen whilst the match is being
+// played. Players who have been knocked out are shown in a losing state.
 func (s *MultiplayerScreen) updateNormal() {
-	s.newGame.Update(s.win)
 	s.menu.Update(s.win)
 	s.score.SetBody(strconv.Itoa(s.backend.Score))
-	s.timer.SetText(s.backend.Timer.Time.String())
-	s.opponentScore.SetBody(strconv.Itoa(s.opponentBackend.Score))
+	s.timer.SetText(s.timerText())
+	s.arena.SetIncoming(s.match.Incoming(s.id))
+
+	switch {
+	case s.match.RoundOver():
+		// The next round starts shortly
+		if s.match.RoundRank(s.id) == 1 {
+			s.arena.SetWin()
+		} else {
+			s.arena.SetLose()
+		}
+		s.guide.SetText(resultText(s.match, s.id, s.id, ""))
+	case s.match.Eliminated(s.id):
+		s.arena.SetLose()
+		s.guide.SetText("You're out!")
+	default:
+		s.newGame.Update(s.win)
+		s.win.Draw(s.newGame)
+	}
 
 	for _, d := range []gogl.Drawable{
 		s.logo2048,
-		s.newGame,
 		s.menu,
 		s.score,
 		s.guide,
 		s.timer,
 		s.arena,
-		s.opponentScore,
-		s.opponentGuide,
-		s.opponentArena,
 	} {
 		s.win.Draw(d)
 	}
-}
-
-// updateWin updates and draws the singleplayer screen in a winning state.
-func (s *MultiplayerScreen) updateWin() {
-	s.arena.SetWin()
-	s.opponentArena.SetLose()
-
-	s.guide.SetText("You win!")
-	s.opponentGuide.SetText(s.opponentName + " loses!")
 
-	s.updateGameEnd()
-}
-
-// updateLose updates and draws the singleplayer screen in a losing state.
-func (s *MultiplayerScreen) updateLose() {
-	s.arena.SetLose()
-	s.opponentArena.SetWin()
+	for _, o := range s.opponents {
+		o.update(s.match, s.id)
+		o.draw(s.win)
 
-	s.guide.SetText("You lose!")
-	s.opponentGuide.SetText(s.opponentName + " wins!")
+		if _, ok := s.peers[o.id]; ok {
+			o.latency.SetText(s.latencyText(o.id))
+			s.win.Draw(o.latency)
+		}
+	}
 
-	s.updateGameEnd()
+	if !s.started {
+		s.countdown.SetText(s.countdownText())
+		s.win.Draw(s.countdown)
+	}
 }
 
-// updateGameEnd draws the appropriate game widgets for when the game has ended.
+// updateGameEnd draws the appropriate game widgets for when the match has ended,
+// showing where everybody finished.
 func (s *MultiplayerScreen) updateGameEnd() {
+	if s.match.Rank(s.id) == 1 {
+		s.arena.SetWin()
+	} else {
+		s.arena.SetLose()
+	}
+
 	s.menu.Update(s.win)
 	s.score.SetBody(strconv.Itoa(s.backend.Score))
-	s.timer.SetText(s.backend.Timer.Time.String())
+	s.guide.SetText(resultText(s.match, s.id, s.id, ""))
+	s.timer.SetText(s.timerText())
 	s.backend.Timer.Pause()
-	s.opponentScore.SetBody(strconv.Itoa(s.opponentBackend.Score))
+
+	canRematch := s.canRematch()
+	if canRematch {
+		s.endGameDialog.SetText("Press REMATCH to\nplay again\n" + s.seriesText() + s.ratingText())
+		s.rematch.SetLabelText(s.rematchLabel())
+		s.rematch.Update(s.win)
+	} else {
+		s.endGameDialog.SetText("Press MENU to\nplay again\n" + s.seriesText() + s.ratingText())
+	}
 
 	for _, d := range []gogl.Drawable{
 		s.menu,
@@ -358,16 +674,574 @@ func (s *MultiplayerScreen) updateGameEnd() {
 		s.timer,
 		s.arena,
 		s.endGameDialog,
-		s.opponentScore,
-		s.opponentGuide,
-		s.opponentArena,
 	} {
 		s.win.Draw(d)
 	}
+	if canRematch {
+		s.win.Draw(s.rematch)
+	}
+
+	for _, o := range s.opponents {
+		o.update(s.match, s.id)
+		o.draw(s.win)
+	}
+}
+
+// seriesText returns the text describing how many matches each player has won
+// against the same opponents, in the same order as the arenas.
+func (s *MultiplayerScreen) seriesText() string {
+	wins := []string{strconv.Itoa(s.match.Series(s.id))}
+	for _, o := range s.opponents {
+		wins = append(wins, strconv.Itoa(s.match.Series(o.id)))
+	}
+	return "Series: " + strings.Join(wins, " - ")
+}
+
+// ratingText returns the line describing the player's new rating, or nothing if
+// the match wasn't against anybody rated.
+func (s *MultiplayerScreen) ratingText() string {
+	if s.result == nil || !slices.ContainsFunc(s.result.Opponents, profile.Opponent.Rated) {
+		return ""
+	}
+	return fmt.Sprintf("\nRating: %d (%+d)", s.profile.Rating, s.result.Change)
+}
+
+// recordResult records the player's result in their profile, once the match is
+// over or when they leave it. Nobody wins a match which was abandoned by the host,
+// so it isn't recorded.
+func (s *MultiplayerScreen) recordResult(left bool) {
+	if s.result != nil || s.match.Abandoned() {
+		return
+	}
+
+	ranking := s.match.Ranking()
+	if left {
+		// Leaving counts as coming last
+		ranking = append(slices.DeleteFunc(ranking, func(id comms.PlayerID) bool {
+			return id == s.id
+		}), s.id)
+	}
+	rank := func(id comms.PlayerID) int {
+		return slices.Index(ranking, id) + 1
+	}
+
+	result := profile.Result{
+		At:      time.Now(),
+		Score:   s.backend.Score,
+		Rank:    rank(s.id),
+		Outcome: profile.OutcomeLoss,
+	}
+	switch {
+	case left:
+		result.Outcome = profile.OutcomeLeft
+	case result.Rank == 1:
+		result.Outcome = profile.OutcomeWin
+	}
+	if !s.matchStart.IsZero() {
+		result.Duration = time.Since(s.matchStart)
+	}
+
+	// Everybody's rating from before the match, so the opponents' new ratings can be
+	// worked out for a rematch
+	everyone := []profile.Opponent{{ID: s.profile.ID, Rating: s.profile.Rating, Rank: result.Rank}}
+	for _, o := range s.opponents {
+		p := s.players[o.id]
+		opponent := profile.Opponent{ID: p.ProfileID, Name: p.Username, Rating: p.Rating, Rank: rank(o.id)}
+		result.Opponents = append(result.Opponents, opponent)
+		everyone = append(everyone, opponent)
+	}
+
+	r := s.profile.Record(result)
+	s.result = &r
+	saveProfile(s.profile)
+
+	self := s.players[s.id]
+	self.Rating = s.profile.Rating
+	s.players[s.id] = self
+	for i, o := range everyone[1:] {
+		if !o.Rated() {
+			continue
+		}
+		others := slices.Delete(slices.Clone(everyone), i+1, i+2)
+		p := s.players[s.opponents[i].id]
+		p.Rating += profile.RatingChange(o.Rating, o.Rank, others)
+		s.players[s.opponents[i].id] = p
+	}
+}
+
+// canRematch returns whether a rematch can be played, which needs everybody still
+// in the match to be connected.
+func (s *MultiplayerScreen) canRematch() bool {
+	if s.match.Left(comms.HostID) {
+		// Guests can't play without the host
+		return false
+	}
+
+	standing := 0
+	for _, id := range s.match.Players() {
+		if !s.match.Left(id) {
+			standing++
+		}
+	}
+	for id, p := range s.peers {
+		if !s.match.Left(id) && p.Heartbeat.SinceSeen() >= comms.HeartbeatTimeout {
+			return false
+		}
+	}
+	return standing > 1
+}
+
+// rematchRequested returns whether any opponent has asked for a rematch.
+func (s *MultiplayerScreen) rematchRequested() bool {
+	for _, o := range s.opponents {
+		if s.match.WantsRematch(o.id) {
+			return true
+		}
+	}
+	return false
+}
+
+// rematchLabel returns the text of the rematch button.
+func (s *MultiplayerScreen) rematchLabel() string {
+	switch {
+	case s.match.WantsRematch(s.id):
+		return "WAITING"
+	case s.rematchRequested():
+		return "ACCEPT"
+	default:
+		return "REMATCH"
+	}
+}
+
+// requestRematch asks the opponents for a rematch, or accepts a rematch which one
+// of them has asked for. The host starts the rematch once everybody wants one.
+func (s *MultiplayerScreen) requestRematch() {
+	if !s.canRematch() || s.match.WantsRematch(s.id) {
+		return
+	}
+	event := comms.EventRematchRequest
+	if s.rematchRequested() {
+		event = comms.EventRematchAccept
+	}
+
+	if s.client != nil {
+		if err := s.match.WantRematch(s.id); err != nil {
+			log.Println("Failed to request rematch:", err)
+			return
+		}
+		if err := s.sendRematchEvent(event); err != nil {
+			log.Println("Failed to send rematch request:", err)
+		}
+		return
+	}
+
+	if err := s.host.WantRematch(s.id, event); err != nil {
+		log.Println("Failed to request rematch:", err)
+		return
+	}
+	if s.bot != nil {
+		// The bot is always up for a rematch
+		if err := s.host.WantRematch(s.opponents[0].id, comms.EventRematchAccept); err != nil {
+			log.Println("Failed to accept rematch for bot:", err)
+		}
+	}
+}
+
+// sendRematchEvent tells the host that the guest wants a rematch. The host relays
+// it to everyone else.
+func (s *MultiplayerScreen) sendRematchEvent(event comms.Event) error {
+	msg, err := comms.EventData{
+		Event:  event,
+		Player: s.id,
+	}.Serialise()
+	if err != nil {
+		return fmt.Errorf("failed to serialise event data: %w", err)
+	}
+
+	return s.sendToOpponents(msg)
+}
+
+// startMatch shows the new games at the start of a rematch, and waits for the
+// countdown to start the player's timer again.
+func (s *MultiplayerScreen) startMatch() {
+	s.startRound()
+	s.startAt, s.started = time.Time{}, false
+	s.matchStart, s.result = time.Time{}, nil
+}
+
+// resultText returns the text describing where the player with the given ID and
+// name finished in a match, or in the round which has just finished if there are
+// more to play. self is the ID of the player viewing it, or zero for a spectator.
+func resultText(m *match.Match, id, self comms.PlayerID, name string) string {
+	rank, suffix := m.Rank(id), "!"
+	if !m.Over() {
+		rank, suffix = m.RoundRank(id), " the round!"
+	}
+	headToHead := len(m.Players()) == 2
+	if id == self {
+		switch {
+		case rank == 1:
+			return "You win" + suffix
+		case headToHead:
+			return "You lose" + suffix
+		default:
+			return "You came " + ordinal(rank)
+		}
+	}
+
+	switch {
+	case rank == 1:
+		return name + " wins" + suffix
+	case m.Left(id):
+		return name + " left the game!"
+	case headToHead:
+		return name + " loses" + suffix
+	default:
+		return name + " came " + ordinal(rank)
+	}
+}
+
+// timerText returns the text of the timer above the arenas. A match with a time
+// limit counts down to the end of the round instead of showing the game time.
+func (s *MultiplayerScreen) timerText() string {
+	end := s.roundOverAt
+	if !s.started {
+		// The round hasn't begun yet
+		end = s.roundStart
+	}
+	return roundTimerText(s.match, s.backend.Timer.Time, s.roundStart, end)
+}
+
+// countdownText returns the text of the countdown to the start of the match.
+func (s *MultiplayerScreen) countdownText() string {
+	if s.startAt.IsZero() {
+		return "Get ready..."
+	}
+	remaining := time.Until(s.startAt)
+	return strconv.Itoa(int(remaining.Seconds()) + 1)
+}
+
+// roundTimerText returns the text describing how far through a match is. elapsed
+// is the game time, which is shown when the rounds have no time limit. start and
+// end are when the current round started and finished, or zero if it's still
+// being played.
+func roundTimerText(m *match.Match, elapsed time.Duration, start, end time.Time) string {
+	rules := m.Rules()
+	text := elapsed.String()
+	if rules.TimeLimit > 0 {
+		if end.IsZero() {
+			end = time.Now()
+		}
+		text = max(0, rules.TimeLimit-end.Sub(start)).Round(time.Second).String()
+	}
+	if rules.Rounds > 1 {
+		text = fmt.Sprintf("Round %d of %d\n%s", m.Round(), rules.Rounds, text)
+	}
+	return text
+}
+
+// updateRound stops the guest's round timer when the host ends the round.
+func (s *MultiplayerScreen) updateRound() {
+	if s.match.RoundOver() && s.roundOverAt.IsZero() {
+		s.roundOverAt = time.Now()
+	}
+}
+
+// startRound shows the new games at the start of a round.
+func (s *MultiplayerScreen) startRound() {
+	s.roundStart, s.roundOverAt = time.Now(), time.Time{}
+
+	// The new games can't be animated from the previous ones
+	s.arena.SetNormal()
+	s.arena.Reload(s.backend.Copy())
+	s.guide.SetText("Your grid")
+	for _, o := range s.opponents {
+		o.newRound(s.match.Game(o.id))
+	}
+}
+
+// ordinal returns a place in a match as text, e.g. "2nd".
+func ordinal(n int) string {
+	switch n {
+	case 1:
+		return "1st"
+	case 2:
+		return "2nd"
+	case 3:
+		return "3rd"
+	default:
+		return strconv.Itoa(n) + "th"
+	}
+}
+
+// arenaFor returns the arena showing a player's game.
+func (s *MultiplayerScreen) arenaFor(id comms.PlayerID) *common.Arena {
+	for _, o := range s.opponents {
+		if o.id == id {
+			return o.arena
+		}
+	}
+	return s.arena
+}
+
+// startBot makes the bot play moves on the opponent's game until the screen exits.
+// Moves are sent to the opponent's backend via a channel so they are executed and
+// animated one at a time, in the same way as the player's moves.
+func (s *MultiplayerScreen) startBot() {
+	s.botDone = make(chan struct{})
+	go func(bot *ai.Bot, done chan struct{}) {
+		ticker := time.NewTicker(bot.Delay())
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				select {
+				case s.opponentInputCh <- func() { s.playBotMove(bot) }:
+				default:
+					// The last move hasn't been played yet
+				}
+			}
+		}
+	}(s.bot, s.botDone)
+}
+
+// playBotMove makes the bot play a move on the opponent's game, unless the round
+// hasn't started or has finished.
+func (s *MultiplayerScreen) playBotMove(bot *ai.Bot) {
+	if !s.started || s.match.RoundOver() {
+		return
+	}
+	id := s.opponents[0].id
+	dir, err := bot.Move(s.match.Game(id).Grid)
+	if err != nil {
+		return
+	}
+	if err := s.host.Play(id, comms.ActionMove, dir); err != nil {
+		log.Println("Failed to play bot move:", err)
+	}
+}
+
+// hostMatch starts hosting the match for the guests in a lobby, or against the
+// bot.
+func (s *MultiplayerScreen) hostMatch(l *host.Lobby) {
+	s.host = host.NewMatch(s.match, l).
+		SetDeltaCallback(s.showDelta).
+		SetStartCallback(s.start).
+		SetChatCallback(s.chat.add)
+	s.peers = s.host.Peers()
+}
+
+// updateHost advances the match hosted by the player, then shows how far through
+// it is.
+func (s *MultiplayerScreen) updateHost() {
+	s.host.Update()
+	s.startAt, s.started = s.host.StartAt(), s.host.Started()
+	s.roundStart, s.roundOverAt = s.host.RoundStart(), s.host.RoundOverAt()
+}
+
+// start starts the player's timer when the countdown to the match finishes.
+func (s *MultiplayerScreen) start(at time.Time) {
+	if s.matchStart.IsZero() {
+		s.matchStart = at
+	}
+	s.backend.Timer.Resume()
+}
+
+// updateCountdown tells the host once the guest is ready for the match, then lets
+// the guest play when the countdown reaches zero. The guest is ready once it has
+// loaded the match and measured the host's clock.
+func (s *MultiplayerScreen) updateCountdown() {
+	switch {
+	case s.started:
+		return
+
+	case !s.startAt.IsZero():
+		if !time.Now().Before(s.startAt) {
+			s.started = true
+			s.roundStart = s.startAt
+			s.match.Start()
+			s.start(s.startAt)
+		}
+
+	case s.loaded && !s.sentReady:
+		if _, ok := s.peers[comms.HostID].Heartbeat.LocalTime(time.Now()); !ok {
+			// The start time can't be converted to the local clock yet
+			return
+		}
+		msg, err := comms.EventData{Event: comms.EventReady}.Serialise()
+		if err != nil {
+			log.Println("Failed to serialise event data:", err)
+			return
+		}
+		if err := s.sendToOpponents(msg); err != nil {
+			log.Println("Failed to tell host that the match is ready:", err)
+			return
+		}
+		s.sentReady = true
+	}
+}
+
+// handleStartData starts the countdown to the time decided by the host, converted
+// to the local clock.
+func (s *MultiplayerScreen) handleStartData(data comms.StartData) {
+	at, ok := s.peers[comms.HostID].Heartbeat.LocalTime(data.At)
+	if !ok {
+		// Assume the clocks are the same, rather than never starting
+		log.Println("Starting countdown without measuring host's clock")
+	}
+	s.startAt, s.started = at, false
 }
 
-// sendToOpponent sends bytes to the opponent.
-func (s *MultiplayerScreen) sendToOpponent(b []byte) error {
+// startHeartbeat pings the host regularly until the screen exits, so a lost
+// connection can be noticed and the latency measured. The host pings its guests as
+// it updates the match.
+func (s *MultiplayerScreen) startHeartbeat() {
+	s.heartbeatDone = make(chan struct{})
+	go func(peers map[comms.PlayerID]*host.Peer, done chan struct{}) {
+		ping := func() {
+			msg, err := comms.PingData{Sent: time.Now()}.Serialise()
+			if err != nil {
+				log.Println("Failed to serialise ping data:", err)
+				return
+			}
+			// Pings are expected to fail whilst a connection is lost
+			err = s.sendToOpponents(msg)
+			healthy := true
+			for _, p := range peers {
+				healthy = healthy && p.Heartbeat.SinceSeen() < comms.HeartbeatTimeout
+			}
+			if err != nil && healthy {
+				log.Println("Failed to ping host:", err)
+			}
+		}
+
+		// Ping straight away so the clocks can be compared before the match starts
+		ping()
+		ticker := time.NewTicker(comms.PingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				ping()
+			}
+		}
+	}(s.peers, s.heartbeatDone)
+}
+
+// updateConnection checks whether the host is still connected, and tries to
+// reconnect if it isn't. The match is abandoned if the host doesn't come back
+// within the grace period.
+func (s *MultiplayerScreen) updateConnection() {
+	if s.match.Over() {
+		return
+	}
+
+	if s.rejoining && time.Since(s.rejoinSentAt) >= comms.PingInterval {
+		// The host only lets the guest back in once it has noticed that the old
+		// connection was lost, so keep asking until it does
+		s.rejoinSentAt = time.Now()
+		if err := s.sendPlayerData(); err != nil {
+			log.Println("Failed to send player data:", err)
+		}
+	}
+
+	p := s.peers[comms.HostID]
+	if p.Heartbeat.SinceSeen() < comms.HeartbeatTimeout {
+		if !p.DisconnectedAt.IsZero() {
+			log.Println("Reconnected to", s.players[comms.HostID].Username)
+			p.DisconnectedAt = time.Time{}
+		}
+		return
+	}
+
+	if p.DisconnectedAt.IsZero() {
+		log.Println("Lost connection to", s.players[comms.HostID].Username)
+		p.DisconnectedAt = time.Now()
+		s.reconnect(p.Heartbeat)
+	}
+	if time.Since(p.DisconnectedAt) > comms.ReconnectGrace {
+		log.Println(s.players[comms.HostID].Username, "didn't reconnect in time")
+		s.match.Abandon()
+	}
+}
+
+// disconnected returns whether the connection to any opponent has been lost.
+// Nobody can play until every connection is restored or given up on.
+func (s *MultiplayerScreen) disconnected() bool {
+	for id, p := range s.peers {
+		if !p.DisconnectedAt.IsZero() && !s.match.Left(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// reconnect tries to reconnect the guest to the host in the background. Once it
+// has reconnected, the guest asks to rejoin the match, then resynchronises it.
+func (s *MultiplayerScreen) reconnect(heartbeat *comms.Heartbeat) {
+	go reconnectToHost(s.client, s.hostAddr, heartbeat, s.heartbeatDone, func() {
+		s.opponentInputCh <- func() {
+			s.resyncing = true
+			s.rejoining, s.rejoinSentAt = true, time.Time{}
+		}
+	})
+}
+
+// reconnectToHost repeatedly tries to reconnect a client to the host until it
+// succeeds, the grace period ends, the old connection recovers or done is closed.
+// onReconnect is called once the client has reconnected.
+func reconnectToHost(client *servesyouright.Client, addr hostAddr, heartbeat *comms.Heartbeat, done chan struct{}, onReconnect func()) {
+	deadline := time.Now().Add(comms.ReconnectGrace)
+	for time.Now().Before(deadline) {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		if heartbeat.SinceSeen() < comms.HeartbeatTimeout {
+			// The old connection has recovered by itself
+			return
+		}
+
+		errCh := make(chan error, 1)
+		go func() {
+			for err := range errCh {
+				log.Println("Client error:", err)
+			}
+		}()
+		if err := client.Connect(context.Background(), addr.ip, addr.port, errCh); err != nil {
+			time.Sleep(comms.PingInterval)
+			continue
+		}
+
+		onReconnect()
+		return
+	}
+}
+
+// latencyText returns the text describing the connection to an opponent.
+func (s *MultiplayerScreen) latencyText(id comms.PlayerID) string {
+	p := s.peers[id]
+	if !p.DisconnectedAt.IsZero() {
+		remaining := comms.ReconnectGrace - time.Since(p.DisconnectedAt)
+		return fmt.Sprintf("Reconnecting... %ds", int(remaining.Seconds()))
+	}
+	latency, ok := p.Heartbeat.Latency()
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%d ms", latency.Milliseconds())
+}
+
+// sendToOpponents sends bytes to every opponent. Nothing is sent to a bot.
+func (s *MultiplayerScreen) sendToOpponents(b []byte) error {
+	if s.bot != nil {
+		return nil
+	}
 	if s.server != nil {
 		for _, id := range s.server.GetClientIDs() {
 			if err := s.server.WriteToClient(id, b); err != nil {
@@ -382,20 +1256,90 @@ func (s *MultiplayerScreen) sendToOpponent(b []byte) error {
 	return nil
 }
 
-// handleOpponentData handles data from the opponent.
-func (s *MultiplayerScreen) handleOpponentData(data []byte) error {
+// handleGuestData handles data from a guest or spectator. Pings and pongs are
+// answered straight away, so the latency isn't affected by the frame rate, whereas
+// everything else changes the match so it's handled with the player's inputs.
+func (s *MultiplayerScreen) handleGuestData(conn int, data []byte) error {
+	msg, err := comms.ParseMessage(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse message: %w", err)
+	}
+	if handled, err := s.host.HandleHeartbeat(conn, msg); handled {
+		return err
+	}
+
+	s.opponentInputCh <- func() {
+		if err := s.host.HandleMessage(conn, msg); err != nil {
+			log.Println("Failed to handle guest data:", err)
+		}
+	}
+	return nil
+}
+
+// handleHostData handles data from the host.
+func (s *MultiplayerScreen) handleHostData(data []byte) error {
 	msg, err := comms.ParseMessage(data)
 	if err != nil {
 		return fmt.Errorf("failed to parse message: %w", err)
 	}
+	p := s.peers[comms.HostID]
+	p.Heartbeat.Seen()
 
 	switch msg.Type {
-	case comms.TypeGameData:
-		gameData, err := comms.ParseGameData(msg.Content)
+	case comms.TypePingData:
+		pingData, err := comms.ParsePingData(msg.Content)
 		if err != nil {
-			return fmt.Errorf("failed to parse game data: %w", err)
+			return fmt.Errorf("failed to parse ping data: %w", err)
 		}
-		return s.handleGameData(gameData)
+		pong, err := comms.PongData{Ping: pingData, Received: time.Now()}.Serialise()
+		if err != nil {
+			return fmt.Errorf("failed to serialise pong data: %w", err)
+		}
+		return s.sendToOpponents(pong)
+
+	case comms.TypePongData:
+		pongData, err := comms.ParsePongData(msg.Content)
+		if err != nil {
+			return fmt.Errorf("failed to parse pong data: %w", err)
+		}
+		p.Heartbeat.Pong(pongData)
+		return nil
+
+	case comms.TypeWelcomeData:
+		s.opponentInputCh <- s.handleWelcomeData
+		return nil
+
+	case comms.TypeStateData:
+		stateData, err := comms.ParseStateData(msg.Content)
+		if err != nil {
+			return fmt.Errorf("failed to parse state data: %w", err)
+		}
+		s.opponentInputCh <- func() { s.handleStateData(stateData) }
+		return nil
+
+	case comms.TypeDeltaData:
+		deltaData, err := comms.ParseDeltaData(msg.Content)
+		if err != nil {
+			return fmt.Errorf("failed to parse delta data: %w", err)
+		}
+		s.opponentInputCh <- func() { s.handleDeltaData(deltaData) }
+		return nil
+
+	case comms.TypeStartData:
+		startData, err := comms.ParseStartData(msg.Content)
+		if err != nil {
+			return fmt.Errorf("failed to parse start data: %w", err)
+		}
+		s.opponentInputCh <- func() { s.handleStartData(startData) }
+		return nil
+
+	case comms.TypeAttackData:
+		attackData, err := comms.ParseAttackData(msg.Content)
+		if err != nil {
+			return fmt.Errorf("failed to parse attack data: %w", err)
+		}
+		s.opponentInputCh <- func() { s.handleAttackData(attackData) }
+		return nil
 
 	case comms.TypeEventData:
 		eventData, err := comms.ParseEventData(msg.Content)
@@ -404,37 +1348,187 @@ func (s *MultiplayerScreen) handleOpponentData(data []byte) error {
 		}
 		return s.handleEventData(eventData)
 
-	case comms.TypeRequestData:
-		requestData, err := comms.ParseRequestData(msg.Content)
+	case comms.TypeChatData:
+		chatData, err := comms.ParseChatData(msg.Content)
 		if err != nil {
-			return fmt.Errorf("failed to parse request data: %w", err)
+			return fmt.Errorf("failed to parse chat data: %w", err)
 		}
-		return s.handleRequest(requestData)
+		s.chat.add(chatData.Name, chatData.Text)
+		return nil
 
 	default:
 		return fmt.Errorf("unsupported message type \"%s\"", msg.Type)
 	}
 }
 
-// sendGameData sends the local game state to the opponent.
-func (s *MultiplayerScreen) sendGameData() error {
-	msg, err := comms.GameData{
-		Game: *s.backend,
+// sendChat sends a message written by the player. A guest's message is shown once
+// the host has relayed it back.
+func (s *MultiplayerScreen) sendChat(text string) {
+	if !s.canChat() {
+		return
+	}
+	if s.client != nil {
+		msg, err := comms.ChatData{Text: text}.Serialise()
+		if err != nil {
+			log.Println("Failed to serialise chat data:", err)
+			return
+		}
+		if err := s.sendToOpponents(msg); err != nil {
+			log.Println("Failed to send chat message:", err)
+		}
+		return
+	}
+
+	// Only the host sends messages to everyone, so nobody can pretend to be
+	// somebody else
+	name := s.players[s.id].Username
+	text, err := s.host.SendChat(name, text)
+	if text != "" {
+		s.chat.add(name, text)
+	}
+	if err != nil {
+		log.Println("Failed to send chat message:", err)
+	}
+}
+
+// canChat returns whether the chat is shown. It's hidden if none of the
+// opponents' games support it.
+func (s *MultiplayerScreen) canChat() bool {
+	if s.bot != nil {
+		return true
+	}
+	for _, p := range s.peers {
+		if p.Session.Has(comms.FeatureChat) {
+			return true
+		}
+	}
+	return false
+}
+
+// sendPlayerData sends the player data to the host, with the token which proves who
+// has reconnected.
+func (s *MultiplayerScreen) sendPlayerData() error {
+	msg, err := comms.PlayerData{
+		Version:   config.Version,
+		Handshake: comms.LocalHandshake(),
+		Username:  s.players[s.id].Username,
+		ID:        s.id,
+		Token:     s.token,
+		ProfileID: s.profile.ID,
+		Rating:    s.players[s.id].Rating,
 	}.Serialise()
 	if err != nil {
-		return fmt.Errorf("failed to serialise game data: %w", err)
+		return fmt.Errorf("failed to serialise player data: %w", err)
 	}
 
-	return s.sendToOpponent(msg)
+	return s.sendToOpponents(msg)
 }
 
-// handleGameData handles incoming game data from the opponent.
-func (s *MultiplayerScreen) handleGameData(data comms.GameData) error {
-	s.opponentBackend = &data.Game
-	return nil
+// handleWelcomeData handles the host letting the guest rejoin the match on a new
+// connection. The match is resynchronised, as changes may have been missed.
+func (s *MultiplayerScreen) handleWelcomeData() {
+	if !s.rejoining {
+		return
+	}
+	s.rejoining = false
+	if err := s.requestStateData(); err != nil {
+		log.Println("Failed to request state data:", err)
+	}
+}
+
+// sendInput sends an input for the player's game to the host.
+func (s *MultiplayerScreen) sendInput(action comms.Action, dir grid.Direction) error {
+	s.inputSeq++
+	msg, err := comms.InputData{
+		Seq:    s.inputSeq,
+		Action: action,
+		Dir:    dir,
+	}.Serialise()
+	if err != nil {
+		return fmt.Errorf("failed to serialise input data: %w", err)
+	}
+
+	return s.sendToOpponents(msg)
+}
+
+// handleDeltaData applies a change from the host to the guest's copy of the match.
+// The state of the whole match is requested instead if a delta was missed, or if it
+// had a different result to the host's.
+func (s *MultiplayerScreen) handleDeltaData(data comms.DeltaData) {
+	if s.resyncing {
+		// The state on its way from the host already includes this change
+		return
+	}
+
+	if err := s.match.ApplyDelta(data); err != nil {
+		log.Println("Resynchronising with host:", err)
+		s.resyncing = true
+		if err := s.requestStateData(); err != nil {
+			log.Println("Failed to request state data:", err)
+		}
+		return
+	}
+
+	s.showDelta(data)
+}
+
+// showDelta shows a change which has just been made to the match, where the arenas
+// can't animate it by themselves.
+func (s *MultiplayerScreen) showDelta(d comms.DeltaData) {
+	switch d.Action {
+	case comms.ActionReset:
+		s.arenaFor(d.Player).Reset()
+	case comms.ActionNextRound:
+		s.startRound()
+	case comms.ActionRematch:
+		s.startMatch()
+	}
+}
+
+// handleAttackData applies an attack from the host to the guest's copy of the
+// match. Like deltas, the state is requested instead if a change was missed.
+func (s *MultiplayerScreen) handleAttackData(data comms.AttackData) {
+	if s.resyncing {
+		return
+	}
+
+	if err := s.match.ApplyAttack(data); err != nil {
+		log.Println("Resynchronising with host:", err)
+		s.resyncing = true
+		if err := s.requestStateData(); err != nil {
+			log.Println("Failed to request state data:", err)
+		}
+	}
 }
 
-// sendScreenLoadedEvent sends the screen loaded event to the opponent.
+// handleStateData replaces the whole match with the state decided by the host. The
+// player's own timer keeps running locally.
+func (s *MultiplayerScreen) handleStateData(data comms.StateData) {
+	round, over := s.match.Round(), s.match.Over()
+	if err := s.match.Load(data); err != nil {
+		log.Println("Failed to load state from host:", err)
+		return
+	}
+	s.resyncing = false
+	s.loaded = true
+
+	switch {
+	case over && !s.match.Over():
+		s.startMatch()
+		return
+	case s.match.Round() != round:
+		s.startRound()
+		return
+	}
+
+	// The new state can't be animated from the previous one
+	s.arena.Reload(s.backend.Copy())
+	for _, o := range s.opponents {
+		o.arena.Reload(s.match.Game(o.id).Copy())
+	}
+}
+
+// sendScreenLoadedEvent sends the screen loaded event to the opponents.
 func (s *MultiplayerScreen) sendScreenLoadedEvent() error {
 	msg, err := comms.EventData{
 		Event: comms.EventScreenLoaded,
@@ -443,50 +1537,42 @@ func (s *MultiplayerScreen) sendScreenLoadedEvent() error {
 		return fmt.Errorf("failed to serialise event data: %w", err)
 	}
 
-	return s.sendToOpponent(msg)
+	return s.sendToOpponents(msg)
 }
 
-// handleEventData handles incoming game data from the opponent.
+// handleEventData handles events from the host.
 func (s *MultiplayerScreen) handleEventData(data comms.EventData) error {
 	switch data.Event {
-	case comms.EventScreenLoaded:
-		// Send game data to opponent
-		if err := s.sendGameData(); err != nil {
-			return fmt.Errorf("failed to send game data: %w", err)
+	case comms.EventRematchRequest, comms.EventRematchAccept:
+		// The host has relayed somebody's request
+		s.opponentInputCh <- func() {
+			if err := s.match.WantRematch(data.Player); err != nil {
+				log.Println("Failed to record rematch request:", err)
+			}
 		}
 
-		// Request for opponent to send their game data
-		if err := s.requestOpponentGameData(); err != nil {
-			return fmt.Errorf("failed to request opponent's game data: %w", err)
+	case comms.EventScreenLoaded:
+		// Request the starting state from the host
+		if err := s.requestStateData(); err != nil {
+			return fmt.Errorf("failed to request state data: %w", err)
 		}
 	}
 
 	return nil
 }
 
-// requestOpponentData sends a request for the opponent to send their game data.
-func (s *MultiplayerScreen) requestOpponentGameData() error {
+// requestStateData sends a request for the host to send the state of the match.
+func (s *MultiplayerScreen) requestStateData() error {
 	msg, err := comms.RequestData{
-		Request: comms.TypeGameData,
+		Request: comms.TypeStateData,
 	}.Serialise()
 	if err != nil {
 		return fmt.Errorf("failed to serialise request data: %w", err)
 	}
 
-	if err := s.sendToOpponent(msg); err != nil {
+	if err := s.sendToOpponents(msg); err != nil {
 		return fmt.Errorf("failed to send data to opponent: %w", err)
 	}
 
 	return nil
 }
-
-// handleRequest handles an incoming request for data.
-func (s *MultiplayerScreen) handleRequest(data comms.RequestData) error {
-	switch data.Request {
-	case comms.TypeGameData:
-		if err := s.sendGameData(); err != nil {
-			return fmt.Errorf("failed to send game data: %w", err)
-		}
-	}
-	return nil
-}
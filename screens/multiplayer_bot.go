@@ -0,0 +1,176 @@
+package screens
+
+import (
+	"github.com/z-riley/go-2048-battle/common"
+	"github.com/z-riley/go-2048-battle/common/backend/grid/ai"
+	"github.com/z-riley/go-2048-battle/common/comms"
+	"github.com/z-riley/go-2048-battle/config"
+	"github.com/z-riley/gogl"
+)
+
+type MultiplayerBotScreen struct {
+	win *gogl.Window
+
+	title *gogl.Text
+
+	hint             *gogl.Text
+	buttonBackground *gogl.CurvedRect
+	easy             *gogl.Button
+	medium           *gogl.Button
+	hard             *gogl.Button
+	back             *gogl.Button
+}
+
+// NewMultiplayerBotScreen constructs a new screen for choosing the difficulty of a
+// bot opponent.
+func NewMultiplayerBotScreen(win *gogl.Window) *MultiplayerBotScreen {
+	return &MultiplayerBotScreen{win: win}
+}
+
+// Enter initialises the screen.
+func (s *MultiplayerBotScreen) Enter(_ InitData) {
+	s.title = gogl.NewText("Versus Bot", gogl.Vec{X: config.WinWidth / 2, Y: 260}, common.FontPathMedium).
+		SetColour(common.GreyTextColour).
+		SetAlignment(gogl.AlignCentre).
+		SetSize(100)
+
+	s.hint = gogl.NewText("", gogl.Vec{X: config.WinWidth / 2, Y: 375}, common.FontPathMedium).
+		SetColour(common.GreyTextColour).
+		SetAlignment(gogl.AlignBottomCentre).
+		SetSize(20)
+
+	// Adjustable settings for buttons
+	const (
+		TileSizePx        float64 = 170
+		TileCornerRadius  float64 = 6
+		TileBoundryFactor float64 = 0.15
+	)
+
+	// Background for buttons
+	const w = TileSizePx * (4 + 5*TileBoundryFactor)
+	s.buttonBackground = gogl.NewCurvedRect(
+		w, TileSizePx*(1+2*TileBoundryFactor), TileCornerRadius,
+		gogl.Vec{X: (config.WinWidth - w) / 2, Y: 400},
+	)
+	s.buttonBackground.SetStyle(gogl.Style{Colour: common.ArenaBackgroundColour})
+
+	for i, b := range []struct {
+		button     **gogl.Button
+		label      string
+		hint       string
+		difficulty ai.Difficulty
+	}{
+		{&s.easy, "Easy", "A slow bot which only thinks one move ahead", ai.Easy},
+		{&s.medium, "Medium", "A bot which thinks a couple of moves ahead", ai.Medium},
+		{&s.hard, "Hard", "A fast bot which plans carefully", ai.Hard},
+	} {
+		difficulty := b.difficulty
+		button := common.NewMenuButton(
+			TileSizePx, TileSizePx,
+			gogl.Vec{
+				X: s.buttonBackground.Pos.X + TileSizePx*(float64(i)+float64(i+1)*TileBoundryFactor),
+				Y: s.buttonBackground.Pos.Y + TileSizePx*TileBoundryFactor,
+			}.Round(),
+			func() { startBotGame(difficulty) },
+		).SetLabelText(b.label)
+		s.setHoverHint(button, b.hint)
+		*b.button = button
+	}
+
+	s.back = common.NewMenuButton(
+		TileSizePx, TileSizePx,
+		gogl.Vec{
+			X: s.buttonBackground.Pos.X + TileSizePx*(3+4*TileBoundryFactor),
+			Y: s.buttonBackground.Pos.Y + TileSizePx*TileBoundryFactor,
+		}.Round(),
+		func() { SetScreen(MultiplayerMenu, nil) },
+	).SetLabelText("Back")
+	s.setHoverHint(s.back, "Go back to versus menu")
+
+	s.win.RegisterKeybind(gogl.Key1, gogl.KeyRelease, func() {
+		startBotGame(ai.Easy)
+	})
+	s.win.RegisterKeybind(gogl.Key2, gogl.KeyRelease, func() {
+		startBotGame(ai.Medium)
+	})
+	s.win.RegisterKeybind(gogl.Key3, gogl.KeyRelease, func() {
+		startBotGame(ai.Hard)
+	})
+	s.win.RegisterKeybind(gogl.Key4, gogl.KeyRelease, func() {
+		SetScreen(MultiplayerMenu, nil)
+	})
+	s.win.RegisterKeybind(gogl.KeyEscape, gogl.KeyRelease, func() {
+		SetScreen(MultiplayerMenu, nil)
+	})
+}
+
+// Exit deinitialises the screen.
+func (s *MultiplayerBotScreen) Exit() {
+	s.win.UnregisterKeybind(gogl.Key1, gogl.KeyRelease)
+	s.win.UnregisterKeybind(gogl.Key2, gogl.KeyRelease)
+	s.win.UnregisterKeybind(gogl.Key3, gogl.KeyRelease)
+	s.win.UnregisterKeybind(gogl.Key4, gogl.KeyRelease)
+	s.win.UnregisterKeybind(gogl.KeyEscape, gogl.KeyRelease)
+}
+
+// Update updates and draws the bot difficulty screen.
+func (s *MultiplayerBotScreen) Update() {
+	s.win.SetBackground(common.BackgroundColour)
+
+	s.win.Draw(s.title)
+	s.win.Draw(s.hint)
+	s.win.Draw(s.buttonBackground)
+
+	for _, b := range []*gogl.Button{
+		s.easy,
+		s.medium,
+		s.hard,
+		s.back,
+	} {
+		b.Update(s.win)
+		s.win.Draw(b)
+	}
+}
+
+// setHoverHint makes the hint show the given text whilst a button is hovered over.
+func (s *MultiplayerBotScreen) setHoverHint(b *gogl.Button, hint string) {
+	b.SetCallback(
+		gogl.ButtonTrigger{State: gogl.NoClick, Behaviour: gogl.OnHold},
+		func() {
+			b.Label.SetColour(common.WhiteFontColour)
+			b.Shape.(*gogl.CurvedRect).SetStyle(common.ButtonStyleHovering)
+			s.hint.SetText(hint)
+		},
+	).SetCallback(
+		gogl.ButtonTrigger{State: gogl.NoClick, Behaviour: gogl.OnRelease},
+		func() {
+			b.Label.SetColour(common.WhiteFontColour)
+			b.Shape.(*gogl.CurvedRect).SetStyle(common.ButtonStyleUnpressed)
+			s.hint.SetText("")
+		},
+	)
+}
+
+// startBotGame starts a versus game against a bot of the given difficulty.
+func startBotGame(difficulty ai.Difficulty) {
+	SetScreen(Multiplayer, InitData{
+		botKey: difficulty,
+		playersKey: []comms.PlayerData{
+			{Username: "You", ID: comms.HostID},
+			{Username: botName(difficulty), ID: comms.HostID + 1},
+		},
+		playerIDKey: comms.HostID,
+	})
+}
+
+// botName returns the display name of a bot of the given difficulty.
+func botName(difficulty ai.Difficulty) string {
+	switch difficulty {
+	case ai.Easy:
+		return "Easy bot"
+	case ai.Medium:
+		return "Medium bot"
+	default:
+		return "Hard bot"
+	}
+}
@@ -1,13 +1,15 @@
 package screens
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"time"
 
-	"github.com/moby/moby/pkg/namesgenerator"
 	"github.com/z-riley/go-2048-battle/common"
 	"github.com/z-riley/go-2048-battle/common/comms"
+	"github.com/z-riley/go-2048-battle/common/host"
+	"github.com/z-riley/go-2048-battle/common/profile"
 	"github.com/z-riley/go-2048-battle/config"
 	"github.com/z-riley/go-2048-battle/log"
 	"github.com/z-riley/gogl"
@@ -15,7 +17,9 @@ import (
 )
 
 const (
-	serverPort = 8080
+	serverPort    = 8080
+	maxSpectators = 8
+	maxClients    = comms.MaxPlayers - 1 + maxSpectators
 )
 
 type MultiplayerHostScreen struct {
@@ -25,14 +29,22 @@ type MultiplayerHostScreen struct {
 	tooltip          *gogl.TextBox
 	nameHeading      *gogl.Text
 	nameEntry        *common.EntryBox
-	opponentName     string
 	opponentStatus   *gogl.Text
+	lobbyHeading     *gogl.Text
+	lobbyList        *gogl.Text
+	rulesHeading     *gogl.Text
+	rulesText        *gogl.Text
 	start            *gogl.Button
+	mode             *gogl.Button
+	rules            *gogl.Button
 	back             *gogl.Button
 	buttonBackground *gogl.CurvedRect
+	chat             *chatBox
 
-	server            *servesyouright.Server
-	opponentIsInLobby bool
+	profile        *profile.Profile
+	server         *servesyouright.Server
+	stopAnnouncing context.CancelFunc
+	lobby          *host.Lobby // the rules are only changed by the host's buttons
 }
 
 // NewMultiplayerHostScreen constructs an uninitialised multiplayer host screen.
@@ -48,6 +60,7 @@ func (s *MultiplayerHostScreen) Enter(_ InitData) {
 		SetSize(100)
 
 	s.tooltip = common.NewTooltip()
+	s.profile = loadProfile()
 
 	s.nameHeading = gogl.NewText(
 		"Your name:",
@@ -61,17 +74,22 @@ func (s *MultiplayerHostScreen) Enter(_ InitData) {
 	s.nameEntry = common.NewEntryBox(
 		440, 60,
 		gogl.Vec{X: (config.WinWidth - 440) / 2, Y: s.nameHeading.Pos().Y + 30},
-		namesgenerator.GetRandomName(0),
+		s.profile.Name,
 	).
 		SetModifiedCB(func() {
-			// Update guest with new username
-			if err := s.sendPlayerData(); err != nil {
+			s.profile.Name = s.nameEntry.Text()
+			saveProfile(s.profile)
+
+			// Update guests with new username
+			err := s.lobby.SetHost(s.hostPlayerData())
+			s.updateLobby()
+			if err != nil {
 				log.Println("Failed to send username update to guests:", err)
 			}
 		})
 
 	s.opponentStatus = gogl.NewText(
-		fmt.Sprintf("Waiting for opponent to join \"%s\"", getIPAddr()),
+		"",
 		gogl.Vec{X: config.WinWidth / 2, Y: 510},
 		common.FontPathMedium,
 	).
@@ -79,6 +97,16 @@ func (s *MultiplayerHostScreen) Enter(_ InitData) {
 		SetAlignment(gogl.AlignCentre).
 		SetSize(24)
 
+	s.rulesHeading, s.rulesText = newRulesWidgets()
+	rules := rulePresets[0]
+	rules.Mode = comms.ModeRace
+	s.rulesText.SetText(rulesText(rules))
+
+	s.server = servesyouright.NewServer(maxClients)
+	s.lobbyHeading, s.lobbyList = newLobbyWidgets()
+	s.lobby = host.NewLobby(s.server, s.hostPlayerData(), rules)
+	s.updateLobby()
+
 	// Adjustable settings for buttons
 	const (
 		TileSizePx        float64 = 120
@@ -87,7 +115,7 @@ func (s *MultiplayerHostScreen) Enter(_ InitData) {
 	)
 
 	// Background for buttons
-	const w = TileSizePx * (2 + 3*TileBoundryFactor)
+	const w = TileSizePx * (4 + 5*TileBoundryFactor)
 	s.buttonBackground = gogl.NewCurvedRect(
 		w, TileSizePx*(1+2*TileBoundryFactor), TileCornerRadius,
 		gogl.Vec{X: (config.WinWidth - w) / 2, Y: 560},
@@ -101,7 +129,7 @@ func (s *MultiplayerHostScreen) Enter(_ InitData) {
 			Y: s.buttonBackground.Pos.Y + TileSizePx*TileBoundryFactor,
 		},
 		func() {
-			if !s.opponentIsInLobby {
+			if !s.opponentIsInLobby() {
 				// Make the opponent status text briefly change colour
 				s.opponentStatus.SetColour(common.Tile64Colour)
 				go func() {
@@ -119,31 +147,72 @@ func (s *MultiplayerHostScreen) Enter(_ InitData) {
 		},
 	).SetLabelText("Start")
 
-	s.back = common.NewMenuButton(
+	s.mode = common.NewMenuButton(
 		TileSizePx, TileSizePx,
 		gogl.Vec{
 			X: s.buttonBackground.Pos.X + TileSizePx*(1+2*TileBoundryFactor),
 			Y: s.buttonBackground.Pos.Y + TileSizePx*TileBoundryFactor,
 		},
+		func() {
+			// Merges only send obstacles in attack mode
+			rules := s.lobby.Rules()
+			if rules.Mode == comms.ModeRace {
+				rules.Mode = comms.ModeAttack
+				s.mode.SetLabelText("Attack")
+			} else {
+				rules.Mode = comms.ModeRace
+				s.mode.SetLabelText("Race")
+			}
+			s.setRules(rules)
+		},
+	).SetLabelText("Race")
+
+	preset := 0
+	s.rules = common.NewMenuButton(
+		TileSizePx, TileSizePx,
+		gogl.Vec{
+			X: s.buttonBackground.Pos.X + TileSizePx*(2+3*TileBoundryFactor),
+			Y: s.buttonBackground.Pos.Y + TileSizePx*TileBoundryFactor,
+		},
+		func() {
+			// Cycle through the presets, keeping the chosen mode
+			preset = (preset + 1) % len(rulePresets)
+			rules := rulePresets[preset]
+			rules.Mode = s.lobby.Rules().Mode
+			s.setRules(rules)
+		},
+	).SetLabelText("Rules")
+
+	s.back = common.NewMenuButton(
+		TileSizePx, TileSizePx,
+		gogl.Vec{
+			X: s.buttonBackground.Pos.X + TileSizePx*(3+4*TileBoundryFactor),
+			Y: s.buttonBackground.Pos.Y + TileSizePx*TileBoundryFactor,
+		},
 		func() {
 			s.server.Destroy()
 			SetScreen(MultiplayerMenu, nil)
 		},
 	).SetLabelText("Back")
 
+	s.chat = newChatBox(gogl.Vec{X: 900, Y: 700}, 280, 0, s.sendChat)
+
 	s.win.RegisterKeybind(gogl.KeyEscape, gogl.KeyRelease, func() {
+		if s.chat.typing() {
+			s.chat.stopTyping()
+			return
+		}
 		s.server.Destroy()
 		SetScreen(MultiplayerMenu, nil)
 	})
+	s.chat.registerKeybinds(s.win)
 
 	// Set up server
-	const maxClients = 1
-	s.server = servesyouright.NewServer(maxClients).
-		SetCallback(func(_ int, b []byte) {
-			if err := s.handleClientData(b); err != nil {
-				log.Println("Host screen failed to handle data from client:", err)
-			}
-		}).SetDisconnectCallback(func(_ int) { s.handleOpponentDisconnect() })
+	s.server.SetCallback(func(conn int, b []byte) {
+		if err := s.handleClientData(conn, b); err != nil {
+			log.Println("Host screen failed to handle data from client:", err)
+		}
+	}).SetDisconnectCallback(s.handleOpponentDisconnect)
 
 	// Start server to allow other players to connect
 	errCh := make(chan error)
@@ -158,12 +227,20 @@ func (s *MultiplayerHostScreen) Enter(_ InitData) {
 	if err := s.server.Start("0.0.0.0", serverPort, errCh); err != nil {
 		panic(err)
 	}
+
+	// Announce the game on the LAN so guests don't need to type in the IP address
+	ctx, cancel := context.WithCancel(context.Background())
+	s.stopAnnouncing = cancel
+	if err := comms.Announce(ctx, s.announcement); err != nil {
+		log.Println("Failed to announce game on the LAN:", err)
+	}
 }
 
 // Exit deinitialises the screen.
 func (s *MultiplayerHostScreen) Exit() {
 	s.win.UnregisterKeybind(gogl.KeyEscape, gogl.KeyRelease)
-	s.opponentIsInLobby = false
+	s.chat.unregisterKeybinds(s.win)
+	s.stopAnnouncing()
 }
 
 // Update updates and draws multiplayer host screen.
@@ -176,12 +253,18 @@ func (s *MultiplayerHostScreen) Update() {
 	for _, l := range []*gogl.Text{
 		s.opponentStatus,
 		s.nameHeading,
+		s.lobbyHeading,
+		s.lobbyList,
+		s.rulesHeading,
+		s.rulesText,
 	} {
 		s.win.Draw(l)
 	}
 
 	for _, b := range []*gogl.Button{
 		s.start,
+		s.mode,
+		s.rules,
 		s.back,
 	} {
 		b.Update(s.win)
@@ -191,6 +274,9 @@ func (s *MultiplayerHostScreen) Update() {
 	s.win.Draw(s.nameEntry)
 	s.nameEntry.Update(s.win)
 
+	s.chat.update(s.win)
+	s.chat.draw(s.win)
+
 	mouseLoc := s.win.MouseLocation()
 	if s.nameEntry.TextBox.Shape.IsWithin(mouseLoc) && !s.nameEntry.TextBox.IsEditing() {
 		s.tooltip.SetPos(gogl.Vec{X: mouseLoc.X, Y: mouseLoc.Y - s.tooltip.Shape.Height()})
@@ -198,8 +284,65 @@ func (s *MultiplayerHostScreen) Update() {
 	}
 }
 
+// announcement returns the current details of the game, for guests on the LAN.
+func (s *MultiplayerHostScreen) announcement() comms.Announcement {
+	return comms.Announcement{
+		Name:        s.nameEntry.Text(),
+		Version:     config.Version,
+		MinProtocol: comms.MinProtocol,
+		MaxProtocol: comms.MaxProtocol,
+		Port:        serverPort,
+		FreeSlots:   s.lobby.FreeSlots(),
+	}
+}
+
+// hostPlayerData returns the player data of the host.
+func (s *MultiplayerHostScreen) hostPlayerData() comms.PlayerData {
+	return comms.PlayerData{
+		Version:   config.Version,
+		Handshake: comms.LocalHandshake(),
+		Username:  s.nameEntry.Text(),
+		ID:        comms.HostID,
+		ProfileID: s.profile.ID,
+		Rating:    s.profile.Rating,
+	}
+}
+
+// opponentIsInLobby returns whether any guests have joined the lobby.
+func (s *MultiplayerHostScreen) opponentIsInLobby() bool {
+	return s.lobby.Guests() > 0
+}
+
+// updateLobby shows the players in the lobby, and how many people are watching.
+func (s *MultiplayerHostScreen) updateLobby() {
+	players := s.lobby.Players()
+	spectators := s.lobby.Spectators()
+
+	s.lobbyList.SetText(lobbyText(players, comms.HostID))
+
+	var status string
+	if len(players) > 1 {
+		status = fmt.Sprintf("%d of %d players have joined. Press Start to begin", len(players), comms.MaxPlayers)
+	} else {
+		status = fmt.Sprintf("Waiting for opponents to join \"%s\"", getIPAddr())
+	}
+	if spectators > 0 {
+		status += fmt.Sprintf(" (%d watching)", spectators)
+	}
+	s.opponentStatus.SetText(status)
+}
+
+// flashStatus briefly shows a message in place of the lobby status.
+func (s *MultiplayerHostScreen) flashStatus(msg string) {
+	s.opponentStatus.SetText(msg)
+	go func() {
+		time.Sleep(3 * time.Second)
+		s.updateLobby()
+	}()
+}
+
 // handleClientData handles all data received from a client.
-func (s *MultiplayerHostScreen) handleClientData(data []byte) error {
+func (s *MultiplayerHostScreen) handleClientData(conn int, data []byte) error {
 	msg, err := comms.ParseMessage(data)
 	if err != nil {
 		return fmt.Errorf("failed to parse message: %w", err)
@@ -211,7 +354,14 @@ func (s *MultiplayerHostScreen) handleClientData(data []byte) error {
 		if err != nil {
 			return fmt.Errorf("failed to parse player data: %w", err)
 		}
-		return s.handlePlayerData(data)
+		return s.handlePlayerData(conn, data)
+
+	case comms.TypeChatData:
+		data, err := comms.ParseChatData(msg.Content)
+		if err != nil {
+			return fmt.Errorf("failed to parse chat data: %w", err)
+		}
+		return s.handleChatData(conn, data)
 
 	default:
 		// Ignore other message type - don't return error
@@ -219,51 +369,67 @@ func (s *MultiplayerHostScreen) handleClientData(data []byte) error {
 	}
 }
 
-// sendPlayerData sends the player data to all connected guests.
-func (s *MultiplayerHostScreen) sendPlayerData() error {
-	msg, err := comms.PlayerData{
-		Version:  config.Version,
-		Username: s.nameEntry.Text(),
-	}.Serialise()
-	if err != nil {
-		return fmt.Errorf("failed to serialise player data: %w", err)
+// handleChatData relays a message from a guest to everyone in the lobby, with the
+// name of the player or spectator who wrote it.
+func (s *MultiplayerHostScreen) handleChatData(conn int, data comms.ChatData) error {
+	name, ok := s.lobby.Name(conn)
+	if !ok {
+		return fmt.Errorf("chat from unknown connection %d", conn)
 	}
 
-	// Send data to client
-	for _, id := range s.server.GetClientIDs() {
-		if err := s.server.WriteToClient(id, msg); err != nil {
-			return fmt.Errorf("failed to send message to client: %w", err)
-		}
-	}
-
-	return nil
+	return s.relayChat(name, data.Text)
 }
 
-// handlePlayerData handles incoming player data.
-func (s *MultiplayerHostScreen) handlePlayerData(data comms.PlayerData) error {
-	// Make sure versions are compatible
-	if data.Version != config.Version {
-		return fmt.Errorf("incompatible versions (peer %s, local %s)", data.Version, config.Version)
+// sendChat sends a message written by the host.
+func (s *MultiplayerHostScreen) sendChat(text string) {
+	if err := s.relayChat(s.nameEntry.Text(), text); err != nil {
+		log.Println("Failed to send chat message:", err)
 	}
+}
 
-	s.opponentName = data.Username
-	s.opponentStatus.SetText(
-		fmt.Sprintf("\"%s\" has joined the game. Press Start to begin", s.opponentName),
-	)
-	s.opponentIsInLobby = true
+// relayChat shows a message, then sends it to every guest.
+func (s *MultiplayerHostScreen) relayChat(name, text string) error {
+	text, err := s.lobby.SendChat(name, text)
+	if text != "" {
+		s.chat.add(name, text)
+	}
+	return err
+}
 
-	// Send host player data to client
-	if err := s.sendPlayerData(); err != nil {
-		return fmt.Errorf("failed to send player data to client: %w", err)
+// handlePlayerData handles incoming player data from a guest or spectator joining
+// the lobby, or a guest changing their details.
+func (s *MultiplayerHostScreen) handlePlayerData(conn int, data comms.PlayerData) error {
+	_, err := s.lobby.Join(conn, data)
+	switch {
+	case errors.Is(err, comms.ErrIncompatible):
+		s.flashStatus(fmt.Sprintf("\"%s\" can't join: %v", data.Username, err))
+		return nil
+	case errors.Is(err, host.ErrFull):
+		// The guest has been told, and can watch instead
+		return nil
 	}
+	s.updateLobby()
+	return err
+}
 
-	return nil
+// setRules changes the rules of the match, and tells every guest about them.
+func (s *MultiplayerHostScreen) setRules(rules comms.RulesData) {
+	s.rulesText.SetText(rulesText(rules))
+	if err := s.lobby.SetRules(rules); err != nil {
+		log.Println("Failed to send rules to guests:", err)
+	}
 }
 
-// handleOpponentDisconnect handles the opponent disconnecting from the server.
-func (s *MultiplayerHostScreen) handleOpponentDisconnect() {
-	s.opponentStatus.SetText(fmt.Sprintf("Waiting for opponent to join \"%s\"", getIPAddr()))
-	s.opponentIsInLobby = false
+// handleOpponentDisconnect handles a guest disconnecting from the server.
+func (s *MultiplayerHostScreen) handleOpponentDisconnect(conn int) {
+	_, ok, err := s.lobby.Leave(conn)
+	if !ok {
+		return
+	}
+	s.updateLobby()
+	if err != nil {
+		log.Println(err)
+	}
 }
 
 // getIPAddr returns the IP address of the host.
@@ -284,29 +450,29 @@ const serverKey = "server"
 
 // startGame attempts to start a multiplayer game.
 func (s *MultiplayerHostScreen) startGame() error {
-	// Check opponent is connected
-	if !s.opponentIsInLobby {
+	// Check opponents are connected
+	if s.lobby.Guests() == 0 {
 		return errors.New("opponent is not connected")
 	}
 
-	// Inform other players that game is starting
-	msg, err := comms.EventData{
-		Event: comms.EventHostStartGame,
-	}.Serialise()
-	if err != nil {
-		return fmt.Errorf("failed to serialise event data: %w", err)
+	// Guests who can't be told the rules can only play by the default ones
+	if err := s.lobby.CheckRules(); err != nil {
+		s.flashStatus(err.Error())
+		return err
 	}
-	for _, id := range s.server.GetClientIDs() {
-		if err := s.server.WriteToClient(id, msg); err != nil {
-			return fmt.Errorf("failed to send message to server: %w", err)
-		}
+
+	// Inform other players that game is starting
+	if err := s.lobby.Start(); err != nil {
+		return err
 	}
 
-	// Pass server to next screen
+	// Pass server and lobby to next screen
 	SetScreen(Multiplayer, InitData{
-		serverKey:           s.server,
-		usernameKey:         s.nameEntry.Text(),
-		opponentUsernameKey: s.opponentName,
+		serverKey:   s.server,
+		playersKey:  s.lobby.Players(),
+		playerIDKey: comms.HostID,
+		lobbyKey:    s.lobby,
+		rulesKey:    s.lobby.Rules(),
 	})
 	return nil
 }
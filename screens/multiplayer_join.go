@@ -3,13 +3,15 @@ package screens
 import (
 	"context"
 	"fmt"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/moby/moby/pkg/namesgenerator"
 	"github.com/z-riley/go-2048-battle/common"
 	"github.com/z-riley/go-2048-battle/common/backend/store"
 	"github.com/z-riley/go-2048-battle/common/comms"
+	"github.com/z-riley/go-2048-battle/common/profile"
 	"github.com/z-riley/go-2048-battle/config"
 	"github.com/z-riley/go-2048-battle/log"
 	"github.com/z-riley/gogl"
@@ -26,17 +28,43 @@ type MultiplayerJoinScreen struct {
 	ipHeading        *gogl.Text
 	ipStore          *store.Store
 	ipEntry          *common.EntryBox
-	opponentName     string
 	opponentStatus   *gogl.Text
+	lobbyHeading     *gogl.Text
+	lobbyList        *gogl.Text
+	rulesHeading     *gogl.Text
+	rulesText        *gogl.Text
 	join             *gogl.Button
+	watch            *gogl.Button
 	back             *gogl.Button
 	buttonBackground *gogl.CurvedRect
+	chat             *chatBox
 
+	lanHeading *gogl.Text
+	lanStatus  *gogl.Text
+	lanButtons []*gogl.Button
+	browser    *comms.Browser
+	lanHosts   []comms.Host // hosts shown on the buttons
+
+	profile     *profile.Profile
 	client      *servesyouright.Client
+	connected   bool
+	spectating  bool // whether the player joined to watch, rather than play
 	hostIsReady chan bool
 	done        chan struct{}
+
+	mu          sync.Mutex
+	id          comms.PlayerID                      // given by the host once the lobby has been joined
+	token       string                              // given with the ID, to rejoin the match with
+	players     map[comms.PlayerID]comms.PlayerData // everyone in the lobby, including the player
+	rules       comms.RulesData                     // the rules chosen by the host
+	host        comms.PlayerData                    // the host, who is only in the lobby if they're playing
+	hostSession comms.Session                       // what was agreed with the host
+	turnedAway  string                              // why the host turned the player away, if it did
 }
 
+// maxListedHosts is the maximum number of hosts on the LAN which are listed.
+const maxListedHosts = 5
+
 // NewTitle Screen constructs an uninitialised multiplayer join screen.
 func NewMultiplayerJoinScreen(win *gogl.Window) *MultiplayerJoinScreen {
 	return &MultiplayerJoinScreen{win: win}
@@ -50,6 +78,7 @@ func (s *MultiplayerJoinScreen) Enter(_ InitData) {
 		SetSize(100)
 
 	s.tooltip = common.NewTooltip()
+	s.profile = loadProfile()
 
 	s.nameHeading = gogl.NewText(
 		"Your name:",
@@ -63,9 +92,12 @@ func (s *MultiplayerJoinScreen) Enter(_ InitData) {
 	s.nameEntry = common.NewEntryBox(
 		440, 60,
 		gogl.Vec{X: config.WinWidth/2 - 440/2, Y: s.nameHeading.Pos().Y + 30},
-		namesgenerator.GetRandomName(0),
+		s.profile.Name,
 	).
 		SetModifiedCB(func() {
+			s.profile.Name = s.nameEntry.Text()
+			saveProfile(s.profile)
+
 			// Update host with new username
 			if s.client != nil {
 				if err := s.sendPlayerData(); err != nil {
@@ -109,6 +141,12 @@ func (s *MultiplayerJoinScreen) Enter(_ InitData) {
 		SetAlignment(gogl.AlignCentre).
 		SetSize(24)
 
+	s.lobbyHeading, s.lobbyList = newLobbyWidgets()
+	s.id, s.token = 0, ""
+	s.players = map[comms.PlayerID]comms.PlayerData{}
+	s.rulesHeading, s.rulesText = newRulesWidgets()
+	s.rules = comms.DefaultRules()
+
 	// Adjustable settings for buttons
 	const (
 		TileSizePx        float64 = 120
@@ -117,7 +155,7 @@ func (s *MultiplayerJoinScreen) Enter(_ InitData) {
 	)
 
 	// Background for buttons
-	const w = TileSizePx * (2 + 3*TileBoundryFactor)
+	const w = TileSizePx * (3 + 4*TileBoundryFactor)
 	s.buttonBackground = gogl.NewCurvedRect(
 		w, TileSizePx*(1+2*TileBoundryFactor), TileCornerRadius,
 		gogl.Vec{X: (config.WinWidth - w) / 2, Y: 560},
@@ -134,25 +172,81 @@ func (s *MultiplayerJoinScreen) Enter(_ InitData) {
 		s.joinButtonHandler,
 	).SetLabelText("Join")
 
-	s.back = common.NewMenuButton(
+	s.watch = common.NewMenuButton(
 		TileSizePx, TileSizePx,
 		gogl.Vec{
 			X: s.buttonBackground.Pos.X + TileSizePx*(1+2*TileBoundryFactor),
 			Y: s.buttonBackground.Pos.Y + TileSizePx*TileBoundryFactor,
 		},
+		s.watchButtonHandler,
+	).SetLabelText("Watch")
+
+	s.back = common.NewMenuButton(
+		TileSizePx, TileSizePx,
+		gogl.Vec{
+			X: s.buttonBackground.Pos.X + TileSizePx*(2+3*TileBoundryFactor),
+			Y: s.buttonBackground.Pos.Y + TileSizePx*TileBoundryFactor,
+		},
 		func() {
 			s.join.SetLabelText("Join")
 			s.client.Destroy()
 			SetScreen(MultiplayerMenu, nil)
 		}).SetLabelText("Back")
 
+	// Games found on the LAN can be joined with one click
+	const (
+		lanX     = 870
+		lanWidth = 290
+	)
+	s.lanHeading = gogl.NewText(
+		"Local games:",
+		gogl.Vec{X: lanX + lanWidth/2, Y: 250},
+		common.FontPathMedium,
+	).
+		SetColour(common.GreyTextColour).
+		SetAlignment(gogl.AlignCentre).
+		SetSize(30)
+
+	s.lanStatus = gogl.NewText(
+		"Searching...",
+		gogl.Vec{X: lanX + lanWidth/2, Y: 310},
+		common.FontPathMedium,
+	).
+		SetColour(common.GreyTextColour).
+		SetAlignment(gogl.AlignCentre).
+		SetSize(24)
+
+	s.lanButtons = make([]*gogl.Button, maxListedHosts)
+	for i := range s.lanButtons {
+		s.lanButtons[i] = common.NewMenuButton(
+			lanWidth, 50,
+			gogl.Vec{X: lanX, Y: 280 + float64(i)*60},
+			func() { s.joinListedHost(i) },
+		).SetLabelSize(24)
+	}
+	s.lanHosts = nil
+
+	s.browser, err = comms.NewBrowser(comms.DiscoveryPort)
+	if err != nil {
+		log.Println("Failed to search for games on the LAN:", err)
+		s.lanStatus.SetText("Search unavailable.\nEnter the host's IP\nto join instead")
+	}
+
 	// Set up client
+	s.connected, s.spectating = false, false
 	s.client = servesyouright.NewClient()
 	s.client.ConnectTimeout = 200 * time.Millisecond
 
+	s.chat = newChatBox(gogl.Vec{X: 870, Y: 700}, 290, 0, s.sendChat)
+
 	s.win.RegisterKeybind(gogl.KeyEscape, gogl.KeyRelease, func() {
+		if s.chat.typing() {
+			s.chat.stopTyping()
+			return
+		}
 		SetScreen(MultiplayerMenu, nil)
 	})
+	s.chat.registerKeybinds(s.win)
 
 	s.done = make(chan struct{}, 1)
 }
@@ -160,7 +254,15 @@ func (s *MultiplayerJoinScreen) Enter(_ InitData) {
 // Exit deinitialises the screen.
 func (s *MultiplayerJoinScreen) Exit() {
 	s.win.UnregisterKeybind(gogl.KeyEscape, gogl.KeyRelease)
+	s.chat.unregisterKeybinds(s.win)
 	s.done <- struct{}{}
+
+	if s.browser != nil {
+		if err := s.browser.Close(); err != nil {
+			log.Println("Failed to stop searching for games on the LAN:", err)
+		}
+		s.browser = nil
+	}
 }
 
 // Update updates and draws multiplayer join screen.
@@ -176,6 +278,7 @@ func (s *MultiplayerJoinScreen) Update() {
 	for _, b := range []*gogl.Button{
 		s.back,
 		s.join,
+		s.watch,
 	} {
 		b.Update(s.win)
 		s.win.Draw(b)
@@ -189,6 +292,23 @@ func (s *MultiplayerJoinScreen) Update() {
 		s.win.Draw(e)
 	}
 
+	s.updateLANHosts()
+
+	s.mu.Lock()
+	inLobby := len(s.players) > 0
+	canChat := s.hostSession.Has(comms.FeatureChat)
+	s.mu.Unlock()
+	if inLobby {
+		s.win.Draw(s.lobbyHeading)
+		s.win.Draw(s.lobbyList)
+		s.win.Draw(s.rulesHeading)
+		s.win.Draw(s.rulesText)
+	}
+	if canChat {
+		s.chat.update(s.win)
+		s.chat.draw(s.win)
+	}
+
 	mouseLoc := s.win.MouseLocation()
 	isHoveringNameEntry := s.nameEntry.TextBox.Shape.IsWithin(mouseLoc) && !s.nameEntry.TextBox.IsEditing()
 	isHoveringIPEntry := s.ipEntry.TextBox.Shape.IsWithin(mouseLoc) && !s.ipEntry.TextBox.IsEditing()
@@ -198,17 +318,134 @@ func (s *MultiplayerJoinScreen) Update() {
 	}
 }
 
-// clientKey is used for indentifying the server in InitData.
-const clientKey = "client"
+const (
+	// clientKey is used for indentifying the server in InitData.
+	clientKey = "client"
+	// hostAddrKey is used for indentifying the host's address in InitData.
+	hostAddrKey = "hostAddr"
+	// tokenKey is used for indentifying the token which the guest rejoins the
+	// match with in InitData.
+	tokenKey = "token"
+)
+
+// hostAddr is the address of a host's server.
+type hostAddr struct {
+	ip   string
+	port uint16
+}
+
+// updateLANHosts updates and draws the list of games found on the LAN.
+func (s *MultiplayerJoinScreen) updateLANHosts() {
+	s.win.Draw(s.lanHeading)
+
+	if s.browser != nil {
+		hosts := s.browser.Hosts()
+		if len(hosts) > maxListedHosts {
+			hosts = hosts[:maxListedHosts]
+		}
+		if !slices.EqualFunc(hosts, s.lanHosts, func(a, b comms.Host) bool {
+			return a.Announcement == b.Announcement && a.IP == b.IP
+		}) {
+			s.lanHosts = hosts
+			for i, h := range hosts {
+				s.lanButtons[i].SetLabelText(hostLabel(h))
+			}
+		}
+	}
+
+	if len(s.lanHosts) == 0 {
+		s.win.Draw(s.lanStatus)
+		return
+	}
+	for _, b := range s.lanButtons[:len(s.lanHosts)] {
+		b.Update(s.win)
+		s.win.Draw(b)
+	}
+}
+
+// hostLabel returns the text shown on the button for a host found on the LAN.
+func hostLabel(h comms.Host) string {
+	switch {
+	case h.Compatible() != nil:
+		return fmt.Sprintf("%s (v%s)", h.Name, h.Version)
+	case h.FreeSlots <= 0:
+		return h.Name + " (full)"
+	default:
+		return h.Name
+	}
+}
+
+// joinListedHost joins the game of the host at the given position in the list of
+// games found on the LAN.
+func (s *MultiplayerJoinScreen) joinListedHost(i int) {
+	if i >= len(s.lanHosts) || s.connected {
+		return
+	}
+	h := s.lanHosts[i]
+
+	err := h.Compatible()
+	switch {
+	case err != nil:
+		s.flashStatus(fmt.Sprintf("Can't join \"%s\": %v", h.Name, err))
+	case h.FreeSlots <= 0:
+		// Spectators can still join a full lobby
+		s.ipEntry.SetText(h.IP)
+		s.flashStatus(fmt.Sprintf("\"%s\" is full. Press Watch to spectate", h.Name))
+	default:
+		// Remember the address in case the game needs to be joined manually later
+		s.ipEntry.SetText(h.IP)
+		if err := s.ipStore.SaveBytes([]byte(h.IP)); err != nil {
+			log.Println("Failed to save IP address to store")
+		}
+		s.spectating = false
+		s.joinHost(h.IP, h.Port)
+	}
+}
+
+// flashStatus briefly shows a message in place of the opponent status.
+func (s *MultiplayerJoinScreen) flashStatus(msg string) {
+	s.opponentStatus.SetText(msg)
+	go func() {
+		time.Sleep(2 * time.Second)
+		s.opponentStatus.SetText("")
+	}()
+}
 
 // joinButtonHandler handles presses of the join button.
 func (s *MultiplayerJoinScreen) joinButtonHandler() {
+	if s.connected {
+		return
+	}
+	s.spectating = false
+	s.joinHost(s.ipEntry.Text(), serverPort)
+}
+
+// watchButtonHandler handles presses of the watch button.
+func (s *MultiplayerJoinScreen) watchButtonHandler() {
+	if s.connected {
+		return
+	}
+	s.spectating = true
+	s.joinHost(s.ipEntry.Text(), serverPort)
+}
+
+// joinHost attempts to join the game hosted at the given address, either as a
+// player or a spectator.
+func (s *MultiplayerJoinScreen) joinHost(ip string, port uint16) {
 	// Handle asynchronous errors from client
 	errCh := make(chan error)
 	go func() {
 		for err := range errCh {
 			if err != nil {
 				log.Println("Client error:", err)
+				s.connected = false
+				s.mu.Lock()
+				s.id, s.token = 0, ""
+				clear(s.players)
+				turnedAway := s.turnedAway
+				s.turnedAway = ""
+				s.host, s.hostSession = comms.PlayerData{}, comms.Session{}
+				s.mu.Unlock()
 
 				// Re-enable button
 				s.join.SetCallback(
@@ -217,7 +454,11 @@ func (s *MultiplayerJoinScreen) joinButtonHandler() {
 				)
 
 				// Display error to user
-				s.opponentStatus.SetText("Lost connection with host")
+				if turnedAway != "" {
+					s.opponentStatus.SetText(turnedAway)
+				} else {
+					s.opponentStatus.SetText("Lost connection with host")
+				}
 				go func() {
 					timer := time.NewTimer(2 * time.Second)
 					<-timer.C
@@ -227,7 +468,7 @@ func (s *MultiplayerJoinScreen) joinButtonHandler() {
 		}
 	}()
 
-	err := s.joinGame(errCh)
+	err := s.joinGame(ip, port, errCh)
 	if err != nil {
 		s.opponentStatus.SetText("Failed to connect to host")
 		go func() {
@@ -238,7 +479,8 @@ func (s *MultiplayerJoinScreen) joinButtonHandler() {
 		return
 	}
 
-	// Disable the button so user can't connect again
+	// Disable the buttons so user can't connect again
+	s.connected = true
 	s.join.SetCallback(
 		gogl.ButtonTrigger{State: gogl.LeftClick, Behaviour: gogl.OnRelease},
 		func() {},
@@ -246,10 +488,26 @@ func (s *MultiplayerJoinScreen) joinButtonHandler() {
 
 	go func() {
 		if <-s.hostIsReady {
+			s.mu.Lock()
+			players, id, token, rules, session := sortedPlayers(s.players), s.id, s.token, s.rules, s.hostSession
+			s.mu.Unlock()
+
+			if s.spectating {
+				SetScreen(Spectate, InitData{
+					clientKey:   s.client,
+					hostAddrKey: hostAddr{ip: ip, port: port},
+					playersKey:  players,
+				})
+				return
+			}
 			SetScreen(Multiplayer, InitData{
-				clientKey:           s.client,
-				usernameKey:         s.nameEntry.Text(),
-				opponentUsernameKey: s.opponentName,
+				clientKey:   s.client,
+				hostAddrKey: hostAddr{ip: ip, port: port},
+				playersKey:  players,
+				playerIDKey: id,
+				tokenKey:    token,
+				rulesKey:    rules,
+				sessionKey:  session,
 			})
 			return
 		}
@@ -257,9 +515,8 @@ func (s *MultiplayerJoinScreen) joinButtonHandler() {
 }
 
 // joinGame attempts to join a multiplayer game.
-func (s *MultiplayerJoinScreen) joinGame(errCh chan error) error {
-	// Connect using the user-specified IP address
-	if err := s.client.Connect(context.Background(), s.ipEntry.Text(), serverPort, errCh); err != nil {
+func (s *MultiplayerJoinScreen) joinGame(ip string, port uint16, errCh chan error) error {
+	if err := s.client.Connect(context.Background(), ip, port, errCh); err != nil {
 		return fmt.Errorf("failed to connect to server: %w", err)
 	}
 
@@ -299,6 +556,29 @@ func (s *MultiplayerJoinScreen) handleServerData(data []byte) error {
 		}
 		return s.handlePlayerData(playerData)
 
+	case comms.TypeWelcomeData:
+		welcomeData, err := comms.ParseWelcomeData(msg.Content)
+		if err != nil {
+			return fmt.Errorf("failed to parse welcome data: %w", err)
+		}
+		s.handleWelcomeData(welcomeData)
+		return nil
+
+	case comms.TypeRulesData:
+		rulesData, err := comms.ParseRulesData(msg.Content)
+		if err != nil {
+			return fmt.Errorf("failed to parse rules data: %w", err)
+		}
+		return s.handleRulesData(rulesData)
+
+	case comms.TypeChatData:
+		chatData, err := comms.ParseChatData(msg.Content)
+		if err != nil {
+			return fmt.Errorf("failed to parse chat data: %w", err)
+		}
+		s.chat.add(chatData.Name, chatData.Text)
+		return nil
+
 	default:
 		return fmt.Errorf("unsupported message type \"%s\"", msg.Type)
 	}
@@ -306,17 +586,59 @@ func (s *MultiplayerJoinScreen) handleServerData(data []byte) error {
 
 // handleEventData handles incoming event data.
 func (s *MultiplayerJoinScreen) handleEventData(data comms.EventData) error {
-	if data.Event == comms.EventHostStartGame {
+	switch data.Event {
+	case comms.EventHostStartGame:
 		s.hostIsReady <- true
+	case comms.EventLobbyFull:
+		s.turnAway("The lobby is full. Press Watch to spectate")
+	case comms.EventIncompatible:
+		s.turnAway("Can't join: " + data.Reason)
 	}
 	return nil
 }
 
+// turnAway leaves the lobby, after the host has turned the player away.
+func (s *MultiplayerJoinScreen) turnAway(reason string) {
+	s.mu.Lock()
+	s.turnedAway = reason
+	s.mu.Unlock()
+	s.client.Destroy()
+}
+
+// sendChat sends a message written by the player to the host, which shows it to
+// everyone in the lobby.
+func (s *MultiplayerJoinScreen) sendChat(text string) {
+	s.mu.Lock()
+	canChat := s.hostSession.Has(comms.FeatureChat)
+	s.mu.Unlock()
+	if !canChat {
+		return
+	}
+
+	msg, err := comms.ChatData{Text: text}.Serialise()
+	if err != nil {
+		log.Println("Failed to serialise chat data:", err)
+		return
+	}
+	if err := s.client.Write(msg); err != nil {
+		log.Println("Failed to send chat message:", err)
+	}
+}
+
 // sendPlayerData sends the player data to the host.
 func (s *MultiplayerJoinScreen) sendPlayerData() error {
+	s.mu.Lock()
+	id := s.id
+	s.mu.Unlock()
+
 	msg, err := comms.PlayerData{
-		Version:  config.Version,
-		Username: s.nameEntry.Text(),
+		Version:   config.Version,
+		Handshake: comms.LocalHandshake(),
+		Username:  s.nameEntry.Text(),
+		ID:        id,
+		ProfileID: s.profile.ID,
+		Rating:    s.profile.Rating,
+		Spectator: s.spectating,
 	}.Serialise()
 	if err != nil {
 		return fmt.Errorf("failed to serialise player data: %w", err)
@@ -325,17 +647,60 @@ func (s *MultiplayerJoinScreen) sendPlayerData() error {
 	return s.client.Write(msg)
 }
 
-// handleEventData handles incoming player data.
+// handlePlayerData handles incoming player data, which the host relays for
+// everyone in the lobby.
 func (s *MultiplayerJoinScreen) handlePlayerData(data comms.PlayerData) error {
-	// Make sure versions are compatible
-	if data.Version != config.Version {
-		return fmt.Errorf("incompatible versions (peer %s, local %s)", data.Version, config.Version)
+	// Make sure the host's version is compatible. The host has already checked
+	// everybody else
+	if data.ID == comms.HostID {
+		session, err := comms.Negotiate(comms.LocalHandshake(), data.Handshake)
+		if err != nil {
+			s.turnAway("Can't join: " + err.Error())
+			return err
+		}
+		s.mu.Lock()
+		s.host, s.hostSession = data, session
+		s.mu.Unlock()
+
+		if data.Dedicated {
+			// The host isn't playing, so isn't in the lobby
+			return nil
+		}
+	}
+
+	s.mu.Lock()
+	if data.Left {
+		delete(s.players, data.ID)
+	} else {
+		s.players[data.ID] = data
 	}
+	s.lobbyList.SetText(lobbyText(sortedPlayers(s.players), s.id))
+	s.mu.Unlock()
+
+	return nil
+}
+
+// handleRulesData handles the host choosing the rules of the match.
+func (s *MultiplayerJoinScreen) handleRulesData(data comms.RulesData) error {
+	if err := data.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.rules = data
+	s.mu.Unlock()
+	s.rulesText.SetText(rulesText(data))
+
+	return nil
+}
+
+// handleWelcomeData handles the host accepting the player into the lobby.
+func (s *MultiplayerJoinScreen) handleWelcomeData(data comms.WelcomeData) {
+	s.mu.Lock()
+	s.id, s.token = data.ID, data.Token
+	s.mu.Unlock()
 
 	// Animate status message
-	s.opponentName = data.Username
-	msg := fmt.Sprintf("Waiting for \"%s\" to start the game", s.opponentName)
-	s.opponentStatus.SetText(msg)
 	go func() {
 		n := 0
 		for {
@@ -343,6 +708,15 @@ func (s *MultiplayerJoinScreen) handlePlayerData(data comms.PlayerData) error {
 			case <-s.done:
 				return
 			default:
+				s.mu.Lock()
+				host := s.host.Username
+				s.mu.Unlock()
+
+				msg := "Waiting for the host to start the game"
+				if host != "" {
+					msg = fmt.Sprintf("Waiting for \"%s\" to start the game", host)
+				}
+
 				s.opponentStatus.SetText(msg + strings.Repeat(".", n))
 				time.Sleep(time.Second)
 				n++
@@ -352,6 +726,4 @@ func (s *MultiplayerJoinScreen) handlePlayerData(data comms.PlayerData) error {
 			}
 		}
 	}()
-
-	return nil
 }
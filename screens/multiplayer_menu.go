@@ -15,6 +15,7 @@ type MultiplayerMenuScreen struct {
 	buttonBackground *gogl.CurvedRect
 	join             *gogl.Button
 	host             *gogl.Button
+	bot              *gogl.Button
 	back             *gogl.Button
 }
 
@@ -43,7 +44,7 @@ func (s *MultiplayerMenuScreen) Enter(_ InitData) {
 	)
 
 	// Background for buttons
-	const w = TileSizePx * (3 + 4*TileBoundryFactor)
+	const w = TileSizePx * (4 + 5*TileBoundryFactor)
 	s.buttonBackground = gogl.NewCurvedRect(
 		w, TileSizePx*(1+2*TileBoundryFactor), TileCornerRadius,
 		gogl.Vec{X: (config.WinWidth - w) / 2, Y: 400},
@@ -98,12 +99,36 @@ func (s *MultiplayerMenuScreen) Enter(_ InitData) {
 		},
 	)
 
-	s.back = common.NewMenuButton(
+	s.bot = common.NewMenuButton(
 		TileSizePx, TileSizePx,
 		gogl.Vec{
 			X: s.buttonBackground.Pos.X + TileSizePx*(2+3*TileBoundryFactor),
 			Y: s.buttonBackground.Pos.Y + TileSizePx*TileBoundryFactor,
 		}.Round(),
+		func() { SetScreen(MultiplayerBot, nil) },
+	).SetLabelText("Bot")
+	s.bot.SetCallback(
+		gogl.ButtonTrigger{State: gogl.NoClick, Behaviour: gogl.OnHold},
+		func() {
+			s.bot.Label.SetColour(common.WhiteFontColour)
+			s.bot.Shape.(*gogl.CurvedRect).SetStyle(common.ButtonStyleHovering)
+			s.hint.SetText("Play against the computer")
+		},
+	).SetCallback(
+		gogl.ButtonTrigger{State: gogl.NoClick, Behaviour: gogl.OnRelease},
+		func() {
+			s.bot.Label.SetColour(common.WhiteFontColour)
+			s.bot.Shape.(*gogl.CurvedRect).SetStyle(common.ButtonStyleUnpressed)
+			s.hint.SetText("")
+		},
+	)
+
+	s.back = common.NewMenuButton(
+		TileSizePx, TileSizePx,
+		gogl.Vec{
+			X: s.buttonBackground.Pos.X + TileSizePx*(3+4*TileBoundryFactor),
+			Y: s.buttonBackground.Pos.Y + TileSizePx*TileBoundryFactor,
+		}.Round(),
 		func() { SetScreen(Title, nil) },
 	).SetLabelText("Back")
 	s.back.SetCallback(
@@ -129,6 +154,9 @@ func (s *MultiplayerMenuScreen) Enter(_ InitData) {
 		SetScreen(MultiplayerHost, nil)
 	})
 	s.win.RegisterKeybind(gogl.Key3, gogl.KeyRelease, func() {
+		SetScreen(MultiplayerBot, nil)
+	})
+	s.win.RegisterKeybind(gogl.Key4, gogl.KeyRelease, func() {
 		SetScreen(Title, nil)
 	})
 	s.win.RegisterKeybind(gogl.KeyEscape, gogl.KeyRelease, func() {
@@ -141,6 +169,7 @@ func (s *MultiplayerMenuScreen) Exit() {
 	s.win.UnregisterKeybind(gogl.Key1, gogl.KeyRelease)
 	s.win.UnregisterKeybind(gogl.Key2, gogl.KeyRelease)
 	s.win.UnregisterKeybind(gogl.Key3, gogl.KeyRelease)
+	s.win.UnregisterKeybind(gogl.Key4, gogl.KeyRelease)
 	s.win.UnregisterKeybind(gogl.KeyEscape, gogl.KeyRelease)
 }
 
@@ -155,6 +184,7 @@ func (s *MultiplayerMenuScreen) Update() {
 	for _, b := range []*gogl.Button{
 		s.join,
 		s.host,
+		s.bot,
 		s.back,
 	} {
 		b.Update(s.win)
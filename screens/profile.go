@@ -0,0 +1,31 @@
+package screens
+
+import (
+	"github.com/moby/moby/pkg/namesgenerator"
+	"github.com/z-riley/go-2048-battle/common/profile"
+	"github.com/z-riley/go-2048-battle/log"
+)
+
+// profileStore is where the player's profile is saved between games.
+var profileStore = profile.NewStore()
+
+// loadProfile loads the player's profile. A new one with a random name is created
+// the first time it's needed.
+func loadProfile() *profile.Profile {
+	p, err := profile.Load(profileStore)
+	if err == nil {
+		return p
+	}
+
+	log.Println("Creating new profile:", err)
+	p = profile.New(namesgenerator.GetRandomName(0))
+	saveProfile(p)
+	return p
+}
+
+// saveProfile saves the player's profile.
+func saveProfile(p *profile.Profile) {
+	if err := p.Save(profileStore); err != nil {
+		log.Println("Failed to save profile:", err)
+	}
+}
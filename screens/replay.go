@@ -0,0 +1,346 @@
+package screens
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/z-riley/go-2048-battle/common"
+	"github.com/z-riley/go-2048-battle/common/backend"
+	"github.com/z-riley/go-2048-battle/log"
+	"github.com/z-riley/gogl"
+)
+
+type ReplayScreen struct {
+	win *gogl.Window
+
+	player   *backend.ReplayPlayer
+	returnTo ID
+	playing  bool
+	speed    int       // index of replaySpeeds
+	lastStep time.Time // when the last step was played
+	inputCh  chan func()
+
+	arena    *common.Arena
+	heading  *gogl.Text
+	dialog   *gogl.Text
+	logo2048 *gogl.TextBox
+	score    *common.ScoreBox
+	moves    *common.ScoreBox
+	play     *gogl.Button
+	step     *gogl.Button
+	speedBtn *gogl.Button
+	menu     *gogl.Button
+	timer    *gogl.Text
+	guide    *gogl.Text
+}
+
+const (
+	// replayKey is used for indentifying the replay to watch in InitData.
+	replayKey = "replay"
+	// returnKey is used for indentifying the screen to return to in InitData.
+	returnKey = "return"
+)
+
+var (
+	// replaySpeeds are the available playback speeds.
+	replaySpeeds = []float64{0.5, 1, 2, 4, 8}
+	// replayMinDelay is the shortest time between steps, so that each move can
+	// finish animating before the next.
+	replayMinDelay = 250 * time.Millisecond
+	// replayMaxDelay is the longest time between steps when playing at 1x speed.
+	replayMaxDelay = 2 * time.Second
+)
+
+// NewReplayScreen constructs an uninitialised replay screen.
+func NewReplayScreen(win *gogl.Window) *ReplayScreen {
+	return &ReplayScreen{win: win}
+}
+
+// Enter initialises the screen.
+func (s *ReplayScreen) Enter(initData InitData) {
+	s.returnTo = Title
+	if id, ok := initData[returnKey].(ID); ok {
+		s.returnTo = id
+	}
+
+	s.player = nil
+	if replay, ok := initData[replayKey].(*backend.Replay); ok {
+		player, err := backend.NewReplayPlayer(replay)
+		if err != nil {
+			log.Println("Failed to load replay:", err)
+		} else {
+			s.player = player
+		}
+	}
+	s.playing = false
+	s.speed = 1
+	s.inputCh = make(chan func(), 100)
+
+	// Arena
+	{
+		cols, rows := 4, 4
+		if s.player != nil {
+			cols, rows = s.player.Game().Grid.Width(), s.player.Game().Grid.Height()
+		}
+		s.arena = common.NewArena(gogl.Vec{X: 440, Y: 300}, cols, rows)
+		if s.player != nil {
+			s.arena.Load(*s.player.Game())
+		}
+	}
+
+	// UI components
+	{
+		// Everything is sized relative to the tile size
+		const unit = common.TileSizePx
+
+		// Everything is positioned relative to the arena grid
+		anchor := s.arena.Pos()
+
+		s.heading = gogl.NewText(
+			"", // to be set and drawn when the replay has finished
+			gogl.Vec{X: anchor.X + s.arena.Width()/2, Y: anchor.Y - 2.8*unit},
+			common.FontPathBold,
+		).SetSize(40).SetColour(common.GreyTextColour).SetAlignment(gogl.AlignTopCentre)
+
+		s.dialog = gogl.NewText(
+			"", // to be set and drawn when the replay has finished
+			gogl.Vec{X: anchor.X + s.arena.Width()/2, Y: anchor.Y - 1.9*unit},
+			common.FontPathBold,
+		).SetSize(20).SetColour(common.GreyTextColour).SetAlignment(gogl.AlignTopCentre)
+
+		s.logo2048 = common.NewLogoBox(
+			1.36*unit,
+			gogl.Vec{X: anchor.X, Y: anchor.Y - 2.58*unit},
+		)
+
+		const wScore = 90
+		s.moves = common.NewScoreBox(
+			wScore, wScore,
+			gogl.Vec{X: anchor.X + s.arena.Width() - 2.74*unit, Y: anchor.Y - 2.58*unit},
+			common.ArenaBackgroundColour,
+		).SetHeading("MOVE")
+
+		s.score = common.NewScoreBox(
+			wScore, wScore,
+			gogl.Vec{X: anchor.X + s.arena.Width() - wScore, Y: anchor.Y - 2.58*unit},
+			common.ArenaBackgroundColour,
+		).SetHeading("SCORE")
+
+		const buttonWidth = unit * 1.27
+		s.play = common.NewGameButton(
+			buttonWidth, 0.4*unit,
+			gogl.Vec{X: anchor.X, Y: anchor.Y + s.arena.Height() + 0.2*unit},
+			func() {
+				s.inputCh <- s.togglePlaying
+			},
+		).SetLabelText("PLAY")
+
+		s.step = common.NewGameButton(
+			buttonWidth, 0.4*unit,
+			gogl.Vec{X: anchor.X + buttonWidth + 0.2*unit, Y: anchor.Y + s.arena.Height() + 0.2*unit},
+			func() {
+				s.inputCh <- s.stepOnce
+			},
+		).SetLabelText("STEP")
+
+		s.speedBtn = common.NewGameButton(
+			buttonWidth, 0.4*unit,
+			gogl.Vec{X: anchor.X + 2*(buttonWidth+0.2*unit), Y: anchor.Y + s.arena.Height() + 0.2*unit},
+			func() {
+				s.inputCh <- func() { s.setSpeed((s.speed + 1) % len(replaySpeeds)) }
+			},
+		).SetLabelText(speedLabel(s.speed))
+
+		s.menu = common.NewGameButton(
+			buttonWidth, 0.4*unit,
+			gogl.Vec{X: anchor.X + s.arena.Width() - buttonWidth, Y: anchor.Y - 1.21*unit},
+			func() {
+				SetScreen(s.returnTo, nil)
+			},
+		).SetLabelText("BACK")
+
+		s.guide = gogl.NewText(
+			"Space to play/pause, arrows to step and change speed",
+			gogl.Vec{X: anchor.X, Y: anchor.Y - 0.60*unit},
+			common.FontPathBold,
+		).SetSize(16).SetColour(common.GreyTextColour)
+
+		s.timer = common.NewGameText("",
+			gogl.Vec{X: anchor.X + s.arena.Width(), Y: anchor.Y + s.arena.Height()*1.1 + 0.6*unit},
+		).SetSize(16).SetAlignment(gogl.AlignBottomRight)
+	}
+
+	// Set keybinds
+	{
+		s.win.RegisterKeybind(gogl.KeySpace, gogl.KeyPress, func() {
+			s.inputCh <- s.togglePlaying
+		})
+		s.win.RegisterKeybind(gogl.KeyRight, gogl.KeyPress, func() {
+			s.inputCh <- s.stepOnce
+		})
+		s.win.RegisterKeybind(gogl.KeyUp, gogl.KeyPress, func() {
+			s.inputCh <- func() { s.setSpeed(min(s.speed+1, len(replaySpeeds)-1)) }
+		})
+		s.win.RegisterKeybind(gogl.KeyDown, gogl.KeyPress, func() {
+			s.inputCh <- func() { s.setSpeed(max(s.speed-1, 0)) }
+		})
+		s.win.RegisterKeybind(gogl.KeyR, gogl.KeyRelease, func() {
+			s.inputCh <- s.restart
+		})
+		s.win.RegisterKeybind(gogl.KeyEscape, gogl.KeyRelease, func() {
+			SetScreen(s.returnTo, nil)
+		})
+	}
+}
+
+// Exit deinitialises the screen.
+func (s *ReplayScreen) Exit() {
+	s.win.UnregisterKeybind(gogl.KeySpace, gogl.KeyPress)
+	s.win.UnregisterKeybind(gogl.KeyRight, gogl.KeyPress)
+	s.win.UnregisterKeybind(gogl.KeyUp, gogl.KeyPress)
+	s.win.UnregisterKeybind(gogl.KeyDown, gogl.KeyPress)
+	s.win.UnregisterKeybind(gogl.KeyR, gogl.KeyRelease)
+	s.win.UnregisterKeybind(gogl.KeyEscape, gogl.KeyRelease)
+
+	if s.player != nil {
+		s.player.Stop()
+	}
+	s.arena.Destroy()
+}
+
+// Update updates and draws the replay screen.
+func (s *ReplayScreen) Update() {
+	s.win.SetBackground(common.BackgroundColour)
+
+	// Handle one input per update cycle, so the arena can animate each step
+	select {
+	case inputFunc := <-s.inputCh:
+		inputFunc()
+	default:
+		// No user input; continue
+	}
+
+	if s.player == nil {
+		s.heading.SetText("No replay")
+		s.dialog.SetText("Play a game to record one!")
+		s.menu.Update(s.win)
+		for _, d := range []gogl.Drawable{s.heading, s.dialog, s.menu, s.arena} {
+			s.win.Draw(d)
+		}
+		return
+	}
+
+	if s.playing && time.Since(s.lastStep) >= s.nextDelay() {
+		s.stepOnce()
+	}
+
+	// Deep copy so front-end has time to animate itself whilst allowing the
+	// back-end to update
+	game := s.player.Game().Copy()
+	s.arena.Update(game)
+
+	done, total := s.player.Position()
+	s.moves.SetBody(fmt.Sprintf("%d/%d", done, total))
+	s.score.SetBody(strconv.Itoa(game.Score))
+	s.timer.SetText(game.Timer.Duration().String())
+
+	s.menu.Update(s.win)
+	s.play.Update(s.win)
+	s.step.Update(s.win)
+	s.speedBtn.Update(s.win)
+
+	if s.player.Done() {
+		s.heading.SetText("Replay over!")
+		s.dialog.SetText("Press R to watch again.")
+		s.win.Draw(s.heading)
+		s.win.Draw(s.dialog)
+	} else {
+		s.win.Draw(s.logo2048)
+		s.win.Draw(s.moves)
+		s.win.Draw(s.score)
+		s.win.Draw(s.guide)
+	}
+
+	for _, d := range []gogl.Drawable{
+		s.menu,
+		s.play,
+		s.step,
+		s.speedBtn,
+		s.timer,
+		s.arena,
+	} {
+		s.win.Draw(d)
+	}
+}
+
+// stepOnce plays the next step of the replay.
+func (s *ReplayScreen) stepOnce() {
+	if s.player == nil || s.player.Done() {
+		s.setPlaying(false)
+		return
+	}
+
+	step, err := s.player.Step()
+	if err != nil {
+		log.Println("Failed to play replay:", err)
+		s.setPlaying(false)
+		return
+	}
+	s.lastStep = time.Now()
+
+	// Undos and redos aren't moves, so they can't be animated
+	if step.Action != backend.ActionMove {
+		s.arena.Reload(s.player.Game().Copy())
+	}
+
+	if s.player.Done() {
+		s.setPlaying(false)
+	}
+}
+
+// nextDelay returns how long to wait before playing the next step.
+func (s *ReplayScreen) nextDelay() time.Duration {
+	speed := replaySpeeds[s.speed]
+	delay := time.Duration(float64(s.player.Delay(replayMaxDelay)) / speed)
+	return max(delay, replayMinDelay)
+}
+
+// restart returns to the start of the replay.
+func (s *ReplayScreen) restart() {
+	if s.player == nil {
+		return
+	}
+	s.player.Restart()
+	s.arena.Reload(s.player.Game().Copy())
+	s.setPlaying(false)
+}
+
+// togglePlaying plays or pauses the replay.
+func (s *ReplayScreen) togglePlaying() {
+	if s.player != nil && s.player.Done() {
+		s.restart()
+	}
+	s.setPlaying(!s.playing)
+}
+
+// setPlaying plays or pauses the replay.
+func (s *ReplayScreen) setPlaying(playing bool) {
+	s.playing = playing
+	if playing {
+		s.play.SetLabelText("PAUSE")
+	} else {
+		s.play.SetLabelText("PLAY")
+	}
+}
+
+// setSpeed sets the playback speed to the replaySpeeds entry at index i.
+func (s *ReplayScreen) setSpeed(i int) {
+	s.speed = i
+	s.speedBtn.SetLabelText(speedLabel(i))
+}
+
+// speedLabel returns the button label for the replaySpeeds entry at index i.
+func speedLabel(i int) string {
+	return fmt.Sprintf("%gx", replaySpeeds[i])
+}
@@ -23,9 +23,15 @@ const (
 	Title           ID = "title"
 	Singleplayer    ID = "singleplayer"
 	MultiplayerMenu ID = "multiplayerMenu"
+	MultiplayerBot  ID = "multiplayerBot"
 	MultiplayerJoin ID = "multiplayerJoin"
 	MultiplayerHost ID = "multiplayerHost"
 	Multiplayer     ID = "multiplayer"
+	Spectate        ID = "spectate"
+	Replay          ID = "replay"
+	Stats           ID = "stats"
+	Leaderboard     ID = "leaderboard"
+	Achievements    ID = "achievements"
 )
 
 func (id ID) String() string {
@@ -41,9 +47,15 @@ func Init(win *gogl.Window) {
 		Title:           NewTitleScreen(win),
 		Singleplayer:    NewSingleplayerScreen(win),
 		MultiplayerMenu: NewMultiplayerMenuScreen(win),
+		MultiplayerBot:  NewMultiplayerBotScreen(win),
 		MultiplayerJoin: NewMultiplayerJoinScreen(win),
 		MultiplayerHost: NewMultiplayerHostScreen(win),
 		Multiplayer:     NewMultiplayerScreen(win),
+		Spectate:        NewSpectateScreen(win),
+		Replay:          NewReplayScreen(win),
+		Stats:           NewStatsScreen(win),
+		Leaderboard:     NewLeaderboardScreen(win),
+		Achievements:    NewAchievementsScreen(win),
 	}
 }
 
@@ -80,7 +92,7 @@ func CurrentScreen() Screen {
 // SetScreen changes the current screen to the given ID next time Update is called.
 func SetScreen(id ID, data InitData) {
 	switch id {
-	case Title, Singleplayer, MultiplayerMenu, MultiplayerJoin, MultiplayerHost, Multiplayer:
+	case Title, Singleplayer, MultiplayerMenu, MultiplayerBot, MultiplayerJoin, MultiplayerHost, Multiplayer, Spectate, Replay, Stats, Leaderboard, Achievements:
 		screenChangeChan <- screenChange{id, data}
 	default:
 		panic("invalid screen: " + id)
@@ -4,10 +4,11 @@ import (
 	"fmt"
 	"strconv"
 
-	"github.com/brunoga/deep"
 	"github.com/z-riley/go-2048-battle/common"
+	"github.com/z-riley/go-2048-battle/common/achievements"
 	"github.com/z-riley/go-2048-battle/common/backend"
 	"github.com/z-riley/go-2048-battle/common/backend/grid"
+	"github.com/z-riley/go-2048-battle/common/backend/grid/ai"
 	"github.com/z-riley/go-2048-battle/config"
 	"github.com/z-riley/gogl"
 )
@@ -18,6 +19,10 @@ type SingleplayerScreen struct {
 	backend      *backend.Game
 	arena        *common.Arena
 	arenaInputCh chan func()
+	solver       *ai.Solver
+
+	achievements     *achievements.Evaluator
+	stopAchievements func()
 
 	heading    *gogl.Text
 	loseDialog *gogl.Text
@@ -28,12 +33,18 @@ type SingleplayerScreen struct {
 	newGame    *gogl.Button
 	guide      *gogl.Text
 	timer      *gogl.Text
+	undos      *gogl.Text
+	toast      *common.Toast
 
 	debugGrid  *gogl.Text
 	debugTime  *gogl.Text
 	debugScore *gogl.Text
 }
 
+// singleplayerHintDepth is the number of moves which the solver searches ahead
+// when giving a hint.
+const singleplayerHintDepth = 3
+
 // NewSingleplayerScreen constructs an uninitialised new singleplayer menu screen.
 func NewSingleplayerScreen(win *gogl.Window) *SingleplayerScreen {
 	return &SingleplayerScreen{win: win}
@@ -43,9 +54,21 @@ func NewSingleplayerScreen(win *gogl.Window) *SingleplayerScreen {
 func (s *SingleplayerScreen) Enter(_ InitData) {
 	// Arena and supporting data structures
 	{
-		s.arena = common.NewArena(gogl.Vec{X: 440, Y: 300})
-		s.backend = backend.NewGame(nil)
+		s.backend = backend.NewGame(&backend.Opts{
+			SaveToDisk:   true,
+			UndoHistory:  config.UndoHistory,
+			UndoLimit:    config.UndoLimit,
+			RecordReplay: true,
+			PlayerName:   loadProfile().Name,
+		})
+		s.arena = common.NewArena(
+			gogl.Vec{X: 440, Y: 300},
+			s.backend.Grid.Width(), s.backend.Grid.Height(),
+		)
 		s.arenaInputCh = make(chan func(), 100)
+		s.solver = ai.NewSolver(singleplayerHintDepth, s.backend.Grid.Seed)
+		s.achievements = achievements.NewEvaluator(achievements.NewStore())
+		s.stopAchievements = s.achievements.Watch(s.backend)
 	}
 
 	// UI components
@@ -115,6 +138,16 @@ func (s *SingleplayerScreen) Enter(_ InitData) {
 		s.timer = common.NewGameText("",
 			gogl.Vec{X: anchor.X + s.arena.Width(), Y: anchor.Y + s.arena.Height()*1.1},
 		).SetSize(16).SetAlignment(gogl.AlignBottomRight)
+
+		s.undos = common.NewGameText("", // only drawn if undos are limited
+			gogl.Vec{X: anchor.X, Y: anchor.Y + s.arena.Height()*1.1},
+		).SetSize(16).SetAlignment(gogl.AlignBottomLeft)
+
+		const toastWidth = 7 * unit
+		s.toast = common.NewToast(
+			toastWidth, 0.7*unit,
+			gogl.Vec{X: anchor.X + (s.arena.Width()-toastWidth)/2, Y: anchor.Y - 3.9*unit},
+		)
 	}
 
 	// Debug UI
@@ -153,12 +186,39 @@ func (s *SingleplayerScreen) Enter(_ InitData) {
 				s.debugGrid.SetText(s.backend.Grid.Debug())
 			}
 		})
+		s.win.RegisterKeybind(gogl.KeyZ, gogl.KeyPress, func() {
+			s.arenaInputCh <- func() {
+				if s.backend.Undo() {
+					s.arena.Reload(s.backend.Copy())
+				}
+			}
+		})
+		s.win.RegisterKeybind(gogl.KeyY, gogl.KeyPress, func() {
+			s.arenaInputCh <- func() {
+				if s.backend.Redo() {
+					s.arena.Reload(s.backend.Copy())
+				}
+			}
+		})
+		s.win.RegisterKeybind(gogl.KeyH, gogl.KeyPress, func() {
+			s.arenaInputCh <- func() {
+				if dir, _, err := s.solver.Best(s.backend.Grid); err == nil {
+					s.arena.SetHint(dir)
+				}
+			}
+		})
 		s.win.RegisterKeybind(gogl.KeyR, gogl.KeyRelease, func() {
 			s.arenaInputCh <- func() {
 				s.backend.Reset()
 				s.arena.Reset()
 			}
 		})
+		s.win.RegisterKeybind(gogl.KeyP, gogl.KeyRelease, func() {
+			SetScreen(Replay, InitData{
+				replayKey: s.backend.Replay,
+				returnKey: Singleplayer,
+			})
+		})
 		s.win.RegisterKeybind(gogl.KeyEscape, gogl.KeyRelease, func() {
 			SetScreen(Title, nil)
 		})
@@ -168,15 +228,22 @@ func (s *SingleplayerScreen) Enter(_ InitData) {
 // Exit deinitialises the screen.
 func (s *SingleplayerScreen) Exit() {
 	s.backend.Timer.Pause()
+	s.stopAchievements()
 
 	if err := s.backend.Save(); err != nil {
 		panic(err)
 	}
+	// A new game is made each time the screen is entered
+	s.backend.Timer.Stop()
 
 	s.win.UnregisterKeybind(gogl.KeyUp, gogl.KeyPress)
 	s.win.UnregisterKeybind(gogl.KeyDown, gogl.KeyPress)
 	s.win.UnregisterKeybind(gogl.KeyLeft, gogl.KeyPress)
 	s.win.UnregisterKeybind(gogl.KeyRight, gogl.KeyPress)
+	s.win.UnregisterKeybind(gogl.KeyZ, gogl.KeyPress)
+	s.win.UnregisterKeybind(gogl.KeyY, gogl.KeyPress)
+	s.win.UnregisterKeybind(gogl.KeyH, gogl.KeyPress)
+	s.win.UnregisterKeybind(gogl.KeyP, gogl.KeyRelease)
 	s.win.UnregisterKeybind(gogl.KeyEscape, gogl.KeyRelease)
 
 	s.arena.Destroy()
@@ -193,9 +260,14 @@ func (s *SingleplayerScreen) Update() {
 		// No user input; continue
 	}
 
+	for _, a := range s.achievements.New() {
+		s.toast.Show("Achievement unlocked: " + a.Name)
+	}
+	s.toast.Update()
+
 	// Deep copy so front-end has time to animate itself whilst allowing the
 	// back-end to update
-	game := deep.MustCopy(*s.backend)
+	game := s.backend.Copy()
 
 	// Check for win or lose
 	switch game.Grid.Outcome() {
@@ -206,6 +278,7 @@ func (s *SingleplayerScreen) Update() {
 	default:
 		s.updateNormal(game)
 	}
+	s.win.Draw(s.toast)
 
 	// Draw debug grid
 	if config.Debug {
@@ -234,6 +307,11 @@ func (s *SingleplayerScreen) updateNormal(game backend.Game) {
 	s.arena.SetNormal()
 	s.arena.Update(game)
 
+	if left := s.backend.UndosLeft(); left >= 0 {
+		s.undos.SetText(fmt.Sprint("Undos left: ", left))
+		s.win.Draw(s.undos)
+	}
+
 	for _, d := range []gogl.Drawable{
 		s.logo2048,
 		s.score,
@@ -263,7 +341,7 @@ func (s *SingleplayerScreen) updateLose(game backend.Game) {
 
 	s.heading.SetText("Game over!")
 	s.loseDialog.SetText(fmt.Sprintf(
-		"You earned %d points in %v.", game.Score, game.Timer.Duration(),
+		"You earned %d points in %v.\nPress P to watch the replay.", game.Score, game.Timer.Duration(),
 	))
 
 	s.menu.Update(s.win)
@@ -0,0 +1,370 @@
+package screens
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/z-riley/go-2048-battle/common"
+	"github.com/z-riley/go-2048-battle/common/comms"
+	"github.com/z-riley/go-2048-battle/common/match"
+	"github.com/z-riley/go-2048-battle/config"
+	"github.com/z-riley/go-2048-battle/log"
+	"github.com/z-riley/gogl"
+	"github.com/z-riley/servesyouright"
+)
+
+// SpectateScreen shows a versus match being played on another machine. Spectators
+// can't change any of the games.
+type SpectateScreen struct {
+	win *gogl.Window
+
+	logo2048      *gogl.TextBox
+	heading       *gogl.Text
+	status        *gogl.Text
+	menu          *gogl.Button
+	endGameDialog *gogl.Text
+	players       []*opponent
+	inputCh       chan func()
+
+	match     *match.Match
+	client    *servesyouright.Client
+	hostAddr  hostAddr // used to reconnect
+	heartbeat *comms.Heartbeat
+	done      chan struct{}
+	// disconnectedAt is when the connection to the host was lost, or zero if the
+	// host is connected
+	disconnectedAt time.Time
+	// resyncing is set whilst waiting for the host to send the state of the match,
+	// after a delta couldn't be applied
+	resyncing bool
+}
+
+// NewSpectateScreen constructs an uninitialised spectate screen.
+func NewSpectateScreen(win *gogl.Window) *SpectateScreen {
+	return &SpectateScreen{win: win}
+}
+
+// Enter initialises the screen.
+func (s *SpectateScreen) Enter(initData InitData) {
+	players := initData[playersKey].([]comms.PlayerData)
+	ids := make([]comms.PlayerID, 0, len(players))
+	for _, p := range players {
+		ids = append(ids, p.ID)
+	}
+	s.match = match.New(ids, 0)
+
+	// UI widgets
+	{
+		positions, scale := arenaLayout(len(players))
+		s.players = s.players[:0]
+		for i, p := range players {
+			s.players = append(s.players, newOpponent(p, s.match.Game(p.ID), positions[i], scale))
+		}
+
+		// Everything is sized relative to the tile size and arena position
+		const unit = common.TileSizePx
+		first := s.players[0].arena
+		anchor := first.Pos()
+
+		const logoSize = 1.36 * unit
+		logoPos := gogl.Vec{X: (config.WinWidth - logoSize) / 2, Y: anchor.Y - 2.58*unit}
+		dialogPos := gogl.Vec{X: config.WinWidth / 2, Y: anchor.Y - 2.5*unit}
+		headingPos := gogl.Vec{X: config.WinWidth / 2, Y: anchor.Y - 0.67*unit}
+		if len(players) > 2 {
+			// There's no room between the arenas, so everything shared goes above them
+			logoPos.Y, headingPos.Y, dialogPos.Y = 30, 140, 165
+		}
+
+		s.logo2048 = common.NewLogoBox(logoSize, logoPos)
+
+		s.heading = common.NewGameText("Spectating", headingPos).SetAlignment(gogl.AlignTopCentre)
+
+		s.endGameDialog = common.NewGameText(
+			"Press MENU to\nleave",
+			dialogPos,
+		).SetAlignment(gogl.AlignTopCentre).SetSize(25)
+
+		const widgetWidth = unit * 1.27
+		s.menu = common.NewGameButton(
+			widgetWidth, 0.4*unit,
+			gogl.Vec{X: (config.WinWidth - widgetWidth) / 2, Y: anchor.Y + first.Height() + 0.4*unit},
+			func() {
+				SetScreen(MultiplayerMenu, nil)
+			},
+		).SetLabelText("MENU")
+
+		s.status = common.NewGameText(
+			"",
+			gogl.Vec{X: config.WinWidth / 2, Y: anchor.Y + first.Height() + 1.1*unit},
+		).SetAlignment(gogl.AlignTopCentre)
+	}
+
+	// Initialise client
+	{
+		s.inputCh = make(chan func(), 100)
+		s.heartbeat = comms.NewHeartbeat()
+		s.done = make(chan struct{})
+		s.disconnectedAt, s.resyncing = time.Time{}, false
+
+		s.client = initData[clientKey].(*servesyouright.Client)
+		s.hostAddr = initData[hostAddrKey].(hostAddr)
+		s.client.SetCallback(func(b []byte) {
+			if err := s.handleHostData(b); err != nil {
+				log.Println("Failed to handle host data as spectator", err)
+			}
+		})
+
+		// Ask the host for the match so far
+		msg, err := comms.EventData{
+			Event: comms.EventScreenLoaded,
+		}.Serialise()
+		if err != nil {
+			log.Println("Failed to serialise event data:", err)
+		} else if err := s.client.Write(msg); err != nil {
+			log.Println("Failed to send screen loaded event:", err)
+		}
+	}
+
+	s.win.RegisterKeybind(gogl.KeyEscape, gogl.KeyRelease, func() {
+		SetScreen(MultiplayerMenu, nil)
+	})
+}
+
+// Exit deinitialises the screen.
+func (s *SpectateScreen) Exit() {
+	s.win.UnregisterKeybind(gogl.KeyEscape, gogl.KeyRelease)
+
+	close(s.done)
+	s.client.Destroy()
+	s.client = nil
+
+	s.match.Close()
+	for _, p := range s.players {
+		p.arena.Destroy()
+	}
+}
+
+// Update updates and draws the spectate screen.
+func (s *SpectateScreen) Update() {
+	s.win.SetBackground(common.BackgroundColour)
+
+	// Only 1 change is applied per update cycle, because the frontend can only
+	// animate one move at a time
+	select {
+	case inputFunc := <-s.inputCh:
+		inputFunc()
+	default:
+		// No changes; continue
+	}
+
+	s.updateConnection()
+
+	for _, p := range s.players {
+		// Deep copy so front-end has time to animate itself
+		p.arena.Update(s.match.Game(p.id).Copy())
+		p.update(s.match, 0)
+		p.draw(s.win)
+	}
+
+	s.menu.Update(s.win)
+	s.status.SetText(s.statusText())
+	if rules := s.match.Rules(); rules.Rounds > 1 {
+		s.heading.SetText(fmt.Sprintf("Spectating round %d of %d", s.match.Round(), rules.Rounds))
+	}
+	for _, d := range []gogl.Drawable{
+		s.menu,
+		s.status,
+	} {
+		s.win.Draw(d)
+	}
+
+	if s.match.Over() {
+		s.win.Draw(s.endGameDialog)
+	} else {
+		s.win.Draw(s.logo2048)
+		s.win.Draw(s.heading)
+	}
+
+	if config.Debug {
+		for _, p := range s.players {
+			p.debugGrid.SetText(s.match.Game(p.id).Grid.Debug())
+			s.win.Draw(p.debugGrid)
+		}
+	}
+}
+
+// statusText returns the text describing the connection to the host.
+func (s *SpectateScreen) statusText() string {
+	switch {
+	case s.match.Left(comms.HostID):
+		return "The host has left"
+	case !s.disconnectedAt.IsZero():
+		remaining := comms.ReconnectGrace - time.Since(s.disconnectedAt)
+		return fmt.Sprintf("Reconnecting... %ds", int(remaining.Seconds()))
+	default:
+		return ""
+	}
+}
+
+// updateConnection checks whether the host is still connected, and tries to
+// reconnect if it isn't. The match is abandoned if the host can't be reached within
+// the grace period.
+func (s *SpectateScreen) updateConnection() {
+	if s.match.Over() {
+		return
+	}
+
+	if s.heartbeat.SinceSeen() < comms.HeartbeatTimeout {
+		if !s.disconnectedAt.IsZero() {
+			log.Println("Reconnected to host")
+			s.disconnectedAt = time.Time{}
+		}
+		return
+	}
+
+	if s.disconnectedAt.IsZero() {
+		log.Println("Lost connection to host")
+		s.disconnectedAt = time.Now()
+		go reconnectToHost(s.client, s.hostAddr, s.heartbeat, s.done, func() {
+			s.inputCh <- func() {
+				s.resyncing = true
+				if err := s.requestStateData(); err != nil {
+					log.Println("Failed to request state data:", err)
+				}
+			}
+		})
+	}
+	if time.Since(s.disconnectedAt) > comms.ReconnectGrace {
+		log.Println("Host didn't reconnect in time")
+		s.match.Abandon()
+	}
+}
+
+// handleHostData handles data from the host. Only changes to the match are needed;
+// everything else is meant for the players.
+func (s *SpectateScreen) handleHostData(data []byte) error {
+	msg, err := comms.ParseMessage(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse message: %w", err)
+	}
+	s.heartbeat.Seen()
+
+	switch msg.Type {
+	case comms.TypeStateData:
+		stateData, err := comms.ParseStateData(msg.Content)
+		if err != nil {
+			return fmt.Errorf("failed to parse state data: %w", err)
+		}
+		s.inputCh <- func() { s.handleStateData(stateData) }
+		return nil
+
+	case comms.TypeDeltaData:
+		deltaData, err := comms.ParseDeltaData(msg.Content)
+		if err != nil {
+			return fmt.Errorf("failed to parse delta data: %w", err)
+		}
+		s.inputCh <- func() { s.handleDeltaData(deltaData) }
+		return nil
+
+	case comms.TypeAttackData:
+		attackData, err := comms.ParseAttackData(msg.Content)
+		if err != nil {
+			return fmt.Errorf("failed to parse attack data: %w", err)
+		}
+		s.inputCh <- func() { s.handleAttackData(attackData) }
+		return nil
+
+	case comms.TypeEventData:
+		eventData, err := comms.ParseEventData(msg.Content)
+		if err != nil {
+			return fmt.Errorf("failed to parse event data: %w", err)
+		}
+		if eventData.Event == comms.EventScreenLoaded {
+			// The host has only just loaded the match
+			return s.requestStateData()
+		}
+		return nil
+
+	default:
+		return nil
+	}
+}
+
+// handleDeltaData applies a change from the host to the match. The whole match is
+// requested instead if a delta was missed, or had a different result to the
+// host's.
+func (s *SpectateScreen) handleDeltaData(data comms.DeltaData) {
+	if s.resyncing {
+		// The state on its way from the host already includes this change
+		return
+	}
+
+	if err := s.match.ApplyDelta(data); err != nil {
+		log.Println("Resynchronising with host:", err)
+		s.resyncing = true
+		if err := s.requestStateData(); err != nil {
+			log.Println("Failed to request state data:", err)
+		}
+		return
+	}
+
+	for _, p := range s.players {
+		switch {
+		case data.Action == comms.ActionReset && p.id == data.Player:
+			p.arena.Reset()
+		case data.Action == comms.ActionNextRound, data.Action == comms.ActionRematch:
+			p.newRound(s.match.Game(p.id))
+		}
+	}
+}
+
+// handleAttackData applies an attack from the host to the match. Like deltas, the
+// whole match is requested instead if a change was missed.
+func (s *SpectateScreen) handleAttackData(data comms.AttackData) {
+	if s.resyncing {
+		return
+	}
+
+	if err := s.match.ApplyAttack(data); err != nil {
+		log.Println("Resynchronising with host:", err)
+		s.resyncing = true
+		if err := s.requestStateData(); err != nil {
+			log.Println("Failed to request state data:", err)
+		}
+	}
+}
+
+// handleStateData replaces the whole match with the state sent by the host.
+func (s *SpectateScreen) handleStateData(data comms.StateData) {
+	round, over := s.match.Round(), s.match.Over()
+	if err := s.match.Load(data); err != nil {
+		log.Println("Failed to load state from host:", err)
+		return
+	}
+	s.resyncing = false
+
+	// The new state can't be animated from the previous one
+	for _, p := range s.players {
+		if s.match.Round() != round || (over && !s.match.Over()) {
+			p.newRound(s.match.Game(p.id))
+		} else {
+			p.arena.Reload(s.match.Game(p.id).Copy())
+		}
+	}
+}
+
+// requestStateData sends a request for the host to send the state of the match.
+func (s *SpectateScreen) requestStateData() error {
+	msg, err := comms.RequestData{
+		Request: comms.TypeStateData,
+	}.Serialise()
+	if err != nil {
+		return fmt.Errorf("failed to serialise request data: %w", err)
+	}
+
+	if err := s.client.Write(msg); err != nil {
+		return fmt.Errorf("failed to send data to host: %w", err)
+	}
+
+	return nil
+}
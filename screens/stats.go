@@ -0,0 +1,248 @@
+package screens
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/z-riley/go-2048-battle/common"
+	"github.com/z-riley/go-2048-battle/common/backend"
+	"github.com/z-riley/go-2048-battle/config"
+	"github.com/z-riley/go-2048-battle/log"
+	"github.com/z-riley/gogl"
+)
+
+type StatsScreen struct {
+	win *gogl.Window
+
+	title            *gogl.Text
+	labels           *gogl.Text
+	values           *gogl.Text
+	histogramHeading *gogl.Text
+	histogram        []*histogramBar
+	buttonBackground *gogl.CurvedRect
+	leaderboard      *gogl.Button
+	achievements     *gogl.Button
+	back             *gogl.Button
+}
+
+// histogramBar contains the widgets which show one bucket of the score histogram.
+type histogramBar struct {
+	bar   *gogl.CurvedRect
+	count *gogl.Text
+	label *gogl.Text
+}
+
+// NewStatsScreen constructs an uninitialised stats screen.
+func NewStatsScreen(win *gogl.Window) *StatsScreen {
+	return &StatsScreen{win: win}
+}
+
+// Enter initialises the screen.
+func (s *StatsScreen) Enter(_ InitData) {
+	stats, err := backend.LoadStats(backend.NewStatsStore())
+	if err != nil {
+		log.Println("Failed to read stats:", err)
+		stats = backend.NewStats()
+	}
+
+	s.title = gogl.NewText("Stats", gogl.Vec{X: config.WinWidth / 2, Y: 120}, common.FontPathMedium).
+		SetColour(common.GreyTextColour).
+		SetAlignment(gogl.AlignCentre).
+		SetSize(100)
+
+	// Each stat is on its own line, with the labels and values in separate columns
+	labels, values := statsLines(stats)
+	s.labels = gogl.NewText(
+		strings.Join(labels, "\n"),
+		gogl.Vec{X: 120, Y: 240},
+		common.FontPathMedium,
+	).
+		SetColour(common.GreyTextColour).
+		SetAlignment(gogl.AlignTopLeft).
+		SetSize(30)
+	s.values = gogl.NewText(
+		strings.Join(values, "\n"),
+		gogl.Vec{X: 560, Y: 240},
+		common.FontPathMedium,
+	).
+		SetColour(common.GreyTextColour).
+		SetAlignment(gogl.AlignTopRight).
+		SetSize(30)
+
+	// Score histogram, with each bar scaled against the tallest
+	const (
+		histogramX      = 640
+		histogramBottom = 560
+		histogramHeight = 260
+		barWidth        = 50
+		barGap          = 10
+	)
+	s.histogramHeading = gogl.NewText(
+		"Scores:",
+		gogl.Vec{X: histogramX + (barWidth+barGap)*float64(len(backend.HistogramBuckets))/2, Y: 250},
+		common.FontPathMedium,
+	).
+		SetColour(common.GreyTextColour).
+		SetAlignment(gogl.AlignCentre).
+		SetSize(30)
+
+	tallest := max(1, slices.Max(stats.Histogram))
+	s.histogram = s.histogram[:0]
+	for i := range backend.HistogramBuckets {
+		count := stats.Histogram[i]
+		x := histogramX + float64(i)*(barWidth+barGap)
+		height := max(4, histogramHeight*float64(count)/float64(tallest))
+		bar := &histogramBar{
+			bar: gogl.NewCurvedRect(
+				barWidth, height, 4,
+				gogl.Vec{X: x, Y: histogramBottom - height},
+			),
+			count: gogl.NewText(
+				strconv.Itoa(count),
+				gogl.Vec{X: x + barWidth/2, Y: histogramBottom - height - 6},
+				common.FontPathMedium,
+			).
+				SetColour(common.GreyTextColour).
+				SetAlignment(gogl.AlignBottomCentre).
+				SetSize(18),
+			label: gogl.NewText(
+				bucketLabel(i),
+				gogl.Vec{X: x + barWidth/2, Y: histogramBottom + 8},
+				common.FontPathMedium,
+			).
+				SetColour(common.GreyTextColour).
+				SetAlignment(gogl.AlignTopCentre).
+				SetSize(18),
+		}
+		bar.bar.SetStyle(gogl.Style{Colour: common.Tile2048Colour})
+		s.histogram = append(s.histogram, bar)
+	}
+
+	// Adjustable settings for buttons
+	const (
+		TileSizePx        float64 = 120
+		TileCornerRadius  float64 = 6
+		TileBoundryFactor float64 = 0.15
+	)
+
+	// Background for buttons
+	const w = TileSizePx * (3 + 4*TileBoundryFactor)
+	s.buttonBackground = gogl.NewCurvedRect(
+		w, TileSizePx*(1+2*TileBoundryFactor), TileCornerRadius,
+		gogl.Vec{X: (config.WinWidth - w) / 2, Y: 600},
+	)
+	s.buttonBackground.SetStyle(gogl.Style{Colour: common.ArenaBackgroundColour})
+
+	s.leaderboard = common.NewMenuButton(
+		TileSizePx, TileSizePx,
+		gogl.Vec{
+			X: s.buttonBackground.Pos.X + TileSizePx*TileBoundryFactor,
+			Y: s.buttonBackground.Pos.Y + TileSizePx*TileBoundryFactor,
+		},
+		func() {
+			SetScreen(Leaderboard, nil)
+		},
+	).SetLabelText("Best")
+
+	s.achievements = common.NewMenuButton(
+		TileSizePx, TileSizePx,
+		gogl.Vec{
+			X: s.buttonBackground.Pos.X + TileSizePx*(1+2*TileBoundryFactor),
+			Y: s.buttonBackground.Pos.Y + TileSizePx*TileBoundryFactor,
+		},
+		func() {
+			SetScreen(Achievements, nil)
+		},
+	).SetLabelText("Awards")
+
+	s.back = common.NewMenuButton(
+		TileSizePx, TileSizePx,
+		gogl.Vec{
+			X: s.buttonBackground.Pos.X + TileSizePx*(2+3*TileBoundryFactor),
+			Y: s.buttonBackground.Pos.Y + TileSizePx*TileBoundryFactor,
+		},
+		func() {
+			SetScreen(Title, nil)
+		},
+	).SetLabelText("Back")
+
+	s.win.RegisterKeybind(gogl.KeyEscape, gogl.KeyRelease, func() {
+		SetScreen(Title, nil)
+	})
+}
+
+// Exit deinitialises the screen.
+func (s *StatsScreen) Exit() {
+	s.win.UnregisterKeybind(gogl.KeyEscape, gogl.KeyRelease)
+}
+
+// Update updates and draws the stats screen.
+func (s *StatsScreen) Update() {
+	s.win.SetBackground(common.BackgroundColour)
+
+	for _, d := range []gogl.Drawable{
+		s.title,
+		s.labels,
+		s.values,
+		s.histogramHeading,
+		s.buttonBackground,
+	} {
+		s.win.Draw(d)
+	}
+
+	for _, b := range s.histogram {
+		s.win.Draw(b.bar)
+		s.win.Draw(b.count)
+		s.win.Draw(b.label)
+	}
+
+	for _, b := range []*gogl.Button{
+		s.leaderboard,
+		s.achievements,
+		s.back,
+	} {
+		b.Update(s.win)
+		s.win.Draw(b)
+	}
+}
+
+// statsLines returns the label and value of every stat, in the order they're
+// shown.
+func statsLines(stats *backend.Stats) (labels, values []string) {
+	winRate := 0
+	if stats.Played > 0 {
+		winRate = 100 * stats.Wins / stats.Played
+	}
+	bestTime := "-"
+	if stats.BestTime > 0 {
+		bestTime = stats.BestTime.String()
+	}
+
+	for _, line := range []struct{ label, value string }{
+		{"Games played", strconv.Itoa(stats.Played)},
+		{"Wins", fmt.Sprintf("%d (%d%%)", stats.Wins, winRate)},
+		{"Highest tile", strconv.Itoa(stats.HighestTile)},
+		{"Average score", strconv.Itoa(stats.AverageScore())},
+		{"Total moves", strconv.Itoa(stats.TotalMoves)},
+		{"Best time to 2048", bestTime},
+	} {
+		labels = append(labels, line.label)
+		values = append(values, line.value)
+	}
+	return labels, values
+}
+
+// bucketLabel returns the label below a bucket of the score histogram, e.g. "4k".
+// The last bucket has no upper limit.
+func bucketLabel(i int) string {
+	label := strconv.Itoa(backend.HistogramBuckets[i]/1000) + "k"
+	if backend.HistogramBuckets[i] == 0 {
+		label = "0"
+	}
+	if i == len(backend.HistogramBuckets)-1 {
+		label += "+"
+	}
+	return label
+}
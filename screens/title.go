@@ -14,6 +14,7 @@ type TitleScreen struct {
 	buttonBackground *gogl.CurvedRect
 	singleplayer     *gogl.Button
 	multiplayer      *gogl.Button
+	stats            *gogl.Button
 	quit             *gogl.Button
 }
 
@@ -42,7 +43,7 @@ func (s *TitleScreen) Enter(_ InitData) {
 	)
 
 	// Background for buttons
-	const w = TileSizePx * (3 + 4*TileBoundryFactor)
+	const w = TileSizePx * (4 + 5*TileBoundryFactor)
 	s.buttonBackground = gogl.NewCurvedRect(
 		w, TileSizePx*(1+2*TileBoundryFactor), TileCornerRadius,
 		gogl.Vec{X: (config.WinWidth - w) / 2, Y: 400},
@@ -91,7 +92,7 @@ func (s *TitleScreen) Enter(_ InitData) {
 		func() {
 			s.multiplayer.Label.SetColour(common.WhiteFontColour)
 			s.multiplayer.Shape.(*gogl.CurvedRect).SetStyle(common.ButtonStyleHovering)
-			s.hint.SetText("Play against a friend")
+			s.hint.SetText("Play against a friend or a bot")
 		},
 	).SetCallback(
 		gogl.ButtonTrigger{State: gogl.NoClick, Behaviour: gogl.OnRelease},
@@ -102,12 +103,38 @@ func (s *TitleScreen) Enter(_ InitData) {
 		},
 	)
 
-	s.quit = common.NewMenuButton(
+	s.stats = common.NewMenuButton(
 		TileSizePx, TileSizePx,
 		gogl.Vec{
 			X: s.buttonBackground.Pos.X + TileSizePx*(2+3*TileBoundryFactor),
 			Y: s.buttonBackground.Pos.Y + TileSizePx*TileBoundryFactor,
 		},
+		func() {
+			SetScreen(Stats, nil)
+		},
+	).SetLabelText("Stats")
+	s.stats.SetCallback(
+		gogl.ButtonTrigger{State: gogl.NoClick, Behaviour: gogl.OnHold},
+		func() {
+			s.stats.Label.SetColour(common.WhiteFontColour)
+			s.stats.Shape.(*gogl.CurvedRect).SetStyle(common.ButtonStyleHovering)
+			s.hint.SetText("See how your solo games have gone")
+		},
+	).SetCallback(
+		gogl.ButtonTrigger{State: gogl.NoClick, Behaviour: gogl.OnRelease},
+		func() {
+			s.stats.Label.SetColour(common.WhiteFontColour)
+			s.stats.Shape.(*gogl.CurvedRect).SetStyle(common.ButtonStyleUnpressed)
+			s.hint.SetText("")
+		},
+	)
+
+	s.quit = common.NewMenuButton(
+		TileSizePx, TileSizePx,
+		gogl.Vec{
+			X: s.buttonBackground.Pos.X + TileSizePx*(3+4*TileBoundryFactor),
+			Y: s.buttonBackground.Pos.Y + TileSizePx*TileBoundryFactor,
+		},
 		func() {
 			s.win.Quit()
 		},
@@ -135,7 +162,10 @@ func (s *TitleScreen) Enter(_ InitData) {
 	s.win.RegisterKeybind(gogl.Key2, gogl.KeyRelease, func() {
 		SetScreen(MultiplayerMenu, nil)
 	})
-	s.win.RegisterKeybind(gogl.Key3, gogl.KeyRelease, s.win.Quit)
+	s.win.RegisterKeybind(gogl.Key3, gogl.KeyRelease, func() {
+		SetScreen(Stats, nil)
+	})
+	s.win.RegisterKeybind(gogl.Key4, gogl.KeyRelease, s.win.Quit)
 	s.win.RegisterKeybind(gogl.KeyEscape, gogl.KeyRelease, s.win.Quit)
 }
 
@@ -144,6 +174,7 @@ func (s *TitleScreen) Exit() {
 	s.win.UnregisterKeybind(gogl.Key1, gogl.KeyRelease)
 	s.win.UnregisterKeybind(gogl.Key2, gogl.KeyRelease)
 	s.win.UnregisterKeybind(gogl.Key3, gogl.KeyRelease)
+	s.win.UnregisterKeybind(gogl.Key4, gogl.KeyRelease)
 	s.win.UnregisterKeybind(gogl.KeyEscape, gogl.KeyRelease)
 }
 
@@ -158,6 +189,7 @@ func (s *TitleScreen) Update() {
 	for _, b := range []*gogl.Button{
 		s.singleplayer,
 		s.multiplayer,
+		s.stats,
 		s.quit,
 	} {
 		b.Update(s.win)